@@ -0,0 +1,400 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ArithExpr is an arithmetic expression over the input values and literals,
+// computing a derived object field (e.g., {celsius: ($0 - 32) * 5 / 9}).
+//
+// Integer operands are promoted to int64 and integer division truncates; if
+// either operand is a float (a float value or a literal such as 9.0), both are
+// promoted to float64.
+type ArithExpr interface {
+	// Eval computes the expression, returning an int64 or a float64.
+	Eval(values []any) (any, error)
+}
+
+// ArithRef refers to an input value by index ($N).
+type ArithRef struct {
+	Index int // index into the input values
+}
+
+// Eval returns the referenced value promoted to int64 or float64.
+func (a *ArithRef) Eval(values []any) (any, error) {
+	if a.Index < 0 || a.Index >= len(values) {
+		return nil, fmt.Errorf("$%d out of range (have %d values)", a.Index, len(values))
+	}
+
+	switch v := values[a.Index].(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("$%d: %w", a.Index, err)
+		}
+		return n, nil
+	}
+}
+
+// ArithLiteral is a numeric literal, either an int64 or a float64.
+type ArithLiteral struct {
+	Value any
+}
+
+// Eval returns the literal value.
+func (a *ArithLiteral) Eval(_ []any) (any, error) {
+	return a.Value, nil
+}
+
+// ArithNeg negates its operand.
+type ArithNeg struct {
+	Operand ArithExpr
+}
+
+// Eval returns the negated operand.
+func (a *ArithNeg) Eval(values []any) (any, error) {
+	v, err := a.Operand.Eval(values)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := v.(float64); ok {
+		return -f, nil
+	}
+	return -v.(int64), nil
+}
+
+// ArithBinary applies an operator (+, -, *, /) to two operands.
+type ArithBinary struct {
+	Op    string
+	Left  ArithExpr
+	Right ArithExpr
+}
+
+// Eval computes the operator, promoting to float64 if either operand is a float.
+func (a *ArithBinary) Eval(values []any) (any, error) {
+	left, err := a.Left.Eval(values)
+	if err != nil {
+		return nil, err
+	}
+	right, err := a.Right.Eval(values)
+	if err != nil {
+		return nil, err
+	}
+
+	l, lok := left.(int64)
+	r, rok := right.(int64)
+	if lok && rok {
+		switch a.Op {
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		default:
+			if r == 0 {
+				return nil, fmt.Errorf("integer division by zero")
+			}
+			return l / r, nil
+		}
+	}
+
+	lf, rf := toFloat64(left), toFloat64(right)
+	switch a.Op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	default:
+		return lf / rf, nil
+	}
+}
+
+// BiasNode adds a constant to an integer value stored relative to a base or bias,
+// such as a year stored as an offset from 1900 or an exponent stored with a bias.
+// The value is promoted to int64 so the adjustment cannot wrap around.
+type BiasNode struct {
+	Index int   // index of the integer value
+	Bias  int64 // constant added to the value (negative to subtract)
+	Keep  bool  // append the raw value after the other values
+}
+
+// Eval replaces the indexed value with the adjusted int64, leaving the other
+// values in place. With Keep, the raw value is appended at the end so existing
+// indices are unchanged.
+func (n *BiasNode) Eval(_ io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("bias: index %d out of range (have %d values)", n.Index, len(values))
+	}
+	raw := values[n.Index]
+	v, err := toInt64(raw)
+	if err != nil {
+		return nil, fmt.Errorf("bias: %w", err)
+	}
+	if (n.Bias > 0 && v > math.MaxInt64-n.Bias) || (n.Bias < 0 && v < math.MinInt64-n.Bias) {
+		return nil, fmt.Errorf("bias: %d%+d overflows int64", v, n.Bias)
+	}
+
+	out := make([]any, len(values), len(values)+1)
+	copy(out, values)
+	out[n.Index] = v + n.Bias
+	if n.Keep {
+		out = append(out, raw)
+	}
+	return out, nil
+}
+
+// parseBiasFunc parses: 'bias' '(' NUMBER ',' '-'? NUMBER (',' 'keep')? ')'
+func (p *Parser) parseBiasFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'bias'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("bias value index")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after bias index"); err != nil {
+		return nil, err
+	}
+
+	sign := ""
+	if p.current.Type == TokenMinus {
+		sign = "-"
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.current.Type != TokenNumber {
+		return nil, fmt.Errorf("expected bias value at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+	digits, base := splitNumberBase(p.current.Value)
+	bias, err := strconv.ParseInt(sign+digits, base, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bias value %q: %w", sign+p.current.Value, err)
+	}
+	node := &BiasNode{Index: idx, Bias: bias}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.current.Type == TokenComma {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.current.Type != TokenIdent || p.current.Value != "keep" {
+			return nil, fmt.Errorf("expected 'keep' at position %d, got %q", p.current.Pos, p.current.Value)
+		}
+		node.Keep = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after bias arguments"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// SignExtNode sign-extends an N-bit two's complement value read into a wider
+// unsigned type, such as the 12-bit samples of an ADC, by replicating bit N-1.
+type SignExtNode struct {
+	Index int // index of the integer value
+	Bits  int // width of the value in bits: 1 to 64
+}
+
+// Eval replaces the indexed value with its sign-extended int64, leaving the other
+// values in place. A value with bits set above the width is an error.
+func (n *SignExtNode) Eval(_ io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("signext: index %d out of range (have %d values)", n.Index, len(values))
+	}
+	v, err := toInt64(values[n.Index])
+	if err != nil {
+		return nil, fmt.Errorf("signext: %w", err)
+	}
+	if n.Bits < 64 && uint64(v)>>n.Bits != 0 {
+		return nil, fmt.Errorf("signext: value %#x does not fit in %d bits", v, n.Bits)
+	}
+
+	out := append([]any{}, values...)
+	shift := 64 - n.Bits
+	out[n.Index] = v << shift >> shift
+	return out, nil
+}
+
+// parseSignExtFunc parses: 'signext' '(' NUMBER ',' NUMBER ')'
+func (p *Parser) parseSignExtFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'signext'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("signext value index")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after signext index"); err != nil {
+		return nil, err
+	}
+
+	pos := p.current.Pos
+	bits, err := p.parseInt("signext bit width")
+	if err != nil {
+		return nil, err
+	}
+	if bits < 1 || bits > 64 {
+		return nil, fmt.Errorf("signext bit width at position %d must be 1 to 64, got %d", pos, bits)
+	}
+
+	if err := p.expect(TokenRParen, "')' after signext arguments"); err != nil {
+		return nil, err
+	}
+	return &SignExtNode{Index: idx, Bits: bits}, nil
+}
+
+// arithRefs adds the value indices an expression refers to with $N into refs.
+func arithRefs(e ArithExpr, refs map[int]bool) {
+	switch a := e.(type) {
+	case *ArithRef:
+		refs[a.Index] = true
+	case *ArithNeg:
+		arithRefs(a.Operand, refs)
+	case *ArithBinary:
+		arithRefs(a.Left, refs)
+		arithRefs(a.Right, refs)
+	}
+}
+
+// toFloat64 converts a promoted arithmetic operand (int64 or float64) to float64.
+func toFloat64(v any) float64 {
+	if f, ok := v.(float64); ok {
+		return f
+	}
+	return float64(v.(int64))
+}
+
+// parseArith parses: Term (('+' | '-') Term)*
+func (p *Parser) parseArith() (ArithExpr, error) {
+	left, err := p.parseArithTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current.Type == TokenPlus || p.current.Type == TokenMinus {
+		op := p.current.Value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseArithTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &ArithBinary{Op: op, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseArithTerm parses: Unary (('*' | '/') Unary)*
+func (p *Parser) parseArithTerm() (ArithExpr, error) {
+	left, err := p.parseArithUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.current.Type == TokenStar || p.current.Type == TokenSlash {
+		op := p.current.Value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseArithUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &ArithBinary{Op: op, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseArithUnary parses: '-' Unary | '$' NUMBER | NUMBER | '(' Arith ')'
+// A bare NUMBER is always a literal; only '$' NUMBER refers to a value, so that
+// `0 * 2` is unambiguous.
+func (p *Parser) parseArithUnary() (ArithExpr, error) {
+	switch p.current.Type {
+	case TokenMinus:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseArithUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &ArithNeg{Operand: operand}, nil
+	case TokenDollar:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		idx, err := p.parseInt("value index after '$'")
+		if err != nil {
+			return nil, err
+		}
+		return &ArithRef{Index: idx}, nil
+	case TokenNumber:
+		lit, err := parseNumberLiteral(p.current.Value)
+		if err != nil {
+			return nil, err
+		}
+		return lit, p.advance()
+	case TokenLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseArith()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(TokenRParen, "')' to close the parenthesized expression"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	default:
+		return nil, fmt.Errorf("expected a number, '$' value or '(' at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+}
+
+// parseNumberLiteral parses an integer (decimal or hex) literal, or a float
+// literal if it has a decimal point.
+func parseNumberLiteral(literal string) (*ArithLiteral, error) {
+	if strings.Contains(literal, ".") {
+		f, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q: %w", literal, err)
+		}
+		return &ArithLiteral{Value: f}, nil
+	}
+
+	digits, base := splitNumberBase(literal)
+	n, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q: %w", literal, err)
+	}
+	return &ArithLiteral{Value: n}, nil
+}
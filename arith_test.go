@@ -0,0 +1,188 @@
+package bq
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestComputedField(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    any
+		wantErr bool
+	}{
+		{"literal", "<B | {x: 42}", []byte{0}, int64(42), false},
+		{"reference", "<bH | {x: $1}", []byte{0xff, 0x02, 0x01}, int64(0x0102), false},
+		{"precedence", "<B | {x: $0 * 2 + 1}", []byte{3}, int64(7), false},
+		{"left associative", "<B | {x: 10 - 3 - $0}", []byte{2}, int64(5), false},
+		{"parentheses", "<B | {x: ($0 - 32) * 5 / 9}", []byte{212}, int64(100), false},
+		{"integer division truncates", "<B | {x: $0 / 2}", []byte{7}, int64(3), false},
+		{"float promotion", "<B | {x: $0 / 2.0}", []byte{7}, float64(3.5), false},
+		{"negation", "<b | {x: -$0}", []byte{0xfe}, int64(2), false},
+		{"subtract without spaces", "<BB | {x: $0-$1}", []byte{5, 3}, int64(2), false},
+		{"division by zero", "<B | {x: 1 / $0}", []byte{0}, nil, true},
+		{"index out of range", "<B | {x: $1}", []byte{0}, nil, true},
+		{"non-numeric value", "s | {x: $0 + 1}", []byte{'a', 0}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			obj := result.(*Object)
+			if got := obj.Fields[0].Value; got != tt.want {
+				t.Errorf("computed value = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputedFieldMixed(t *testing.T) {
+	node, err := ParseExpression("<HB | {0 -> raw, derived: {double: $1 * 2}, sum: $0 + $1}")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+
+	result, err := node.Eval(bytes.NewReader([]byte{0x10, 0x00, 0x03}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	obj := result.(*Object)
+	if obj.Fields[0].Value != uint16(16) {
+		t.Errorf("raw = %v, want 16", obj.Fields[0].Value)
+	}
+	if nested := obj.Fields[1].Value.(*Object); nested.Fields[0].Value != int64(6) {
+		t.Errorf("derived.double = %v, want 6", nested.Fields[0].Value)
+	}
+	if obj.Fields[2].Value != int64(19) {
+		t.Errorf("sum = %v, want 19", obj.Fields[2].Value)
+	}
+}
+
+func TestParseComputedFieldErrors(t *testing.T) {
+	inputs := []string{
+		"<B | {x: }",
+		"<B | {x: $}",
+		"<B | {x: ($0 + 1}",
+		"<B | {x: $0 +}",
+		"<B | {x: * 2}",
+	}
+
+	for _, input := range inputs {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestBiasNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []any
+		wantErr bool
+	}{
+		{"add a base", "<BH | bias(0, 1900)", []byte{0x7a, 0x01, 0x00}, []any{int64(2022), uint16(1)}, false},
+		{"subtract a bias", "<HB | bias(1, -127)", []byte{0x00, 0x00, 0x80}, []any{uint16(0), int64(1)}, false},
+		{"hex bias", "B | bias(0, -0x10)", []byte{0x00}, []any{int64(-16)}, false},
+		{"keep the raw value", "<BH | bias(0, 1, keep)", []byte{0xff, 0x02, 0x00}, []any{int64(256), uint16(2), uint8(0xff)}, false},
+		{"zero bias promotes", "b | bias(0, 0)", []byte{0xff}, []any{int64(-1)}, false},
+		{"overflow", "q | bias(0, 1)", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, nil, true},
+		{"index out of range", "B | bias(1, 1)", []byte{0x00}, nil, true},
+		{"non-integer value", "s | bias(0, 1)", []byte("a\x00"), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := result.([]any); !slices.Equal(got, tt.want) {
+				t.Errorf("EvalBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBiasParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"B | bias(0)",
+		"B | bias(0, x)",
+		"B | bias(0, 1.5)",
+		"B | bias(0, 1, raw)",
+		"B | bias(0, 1",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestSignExtNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []any
+		wantErr bool
+	}{
+		{"negative 12-bit", "<H | signext(0, 12)", []byte{0xff, 0x0f}, []any{int64(-1)}, false},
+		{"most negative 12-bit", "<H | signext(0, 12)", []byte{0x00, 0x08}, []any{int64(-2048)}, false},
+		{"positive 12-bit", "<H | signext(0, 12)", []byte{0xff, 0x07}, []any{int64(2047)}, false},
+		{"other values in place", "<BH | signext(1, 4)", []byte{0x01, 0x0a, 0x00}, []any{uint8(1), int64(-6)}, false},
+		{"single bit", "B | signext(0, 1)", []byte{0x01}, []any{int64(-1)}, false},
+		{"full width", "<q | signext(0, 64)", []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, []any{int64(-2)}, false},
+		{"wider than the bits", "<H | signext(0, 12)", []byte{0x00, 0x10}, nil, true},
+		{"index out of range", "B | signext(1, 4)", []byte{0x00}, nil, true},
+		{"non-integer value", "s | signext(0, 4)", []byte("a\x00"), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := result.([]any); !slices.Equal(got, tt.want) {
+				t.Errorf("EvalBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignExtParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"B | signext(0)",
+		"B | signext(0, 0)",
+		"B | signext(0, 65)",
+		"B | signext(0, x)",
+		"B | signext(0, 4",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
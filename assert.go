@@ -0,0 +1,68 @@
+package bq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// AssertNode reads a single integer value and checks it against an expected
+// literal, as for the magic number or version of a header, e.g. assert(<I,
+// 0x464c457f). A signed code takes a signed literal, so assert(<b, -0x01) matches
+// a 0xff byte.
+type AssertNode struct {
+	Code FormatCode // fixed-size integer code of the value
+	Want any        // expected value, typed for the code (e.g., int8 for b)
+}
+
+// Eval reads the value and returns it, or an error naming both values if it
+// differs from the expected one.
+func (n *AssertNode) Eval(r io.Reader, _ []any) (any, error) {
+	got, err := readFixed(r, n.Code)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("assert: failed to read %d bytes for format %c: %w", n.Code.Size, n.Code.Code, err)
+	}
+	if got != n.Want {
+		return nil, fmt.Errorf("assert: got %v (%s), want %v (%s)", got, formatHex(got), n.Want, formatHex(n.Want))
+	}
+	return []any{got}, nil
+}
+
+// expr returns the format expression of the value, which lines up with the result.
+func (n *AssertNode) expr() *Expr {
+	return &Expr{Order: n.Code.Order, Formats: []FormatCode{n.Code}}
+}
+
+// parseAssertFunc parses: 'assert' '(' FormatExpr ',' '-'? NUMBER ')'
+func (p *Parser) parseAssertFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'assert'"); err != nil {
+		return nil, err
+	}
+
+	code, err := p.parseSingleFormat("assert code")
+	if err != nil {
+		return nil, err
+	}
+	if !isIntegerCode(code.Code) {
+		return nil, fmt.Errorf("assert code must be an integer code, got %c", code.Code)
+	}
+	if err := p.expect(TokenComma, "',' after assert code"); err != nil {
+		return nil, err
+	}
+
+	want, err := p.parseIntegerLiteral(code, "assert value")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after assert arguments"); err != nil {
+		return nil, err
+	}
+	return &AssertNode{Code: code, Want: want}, nil
+}
@@ -0,0 +1,96 @@
+package bq
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAssertNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    any
+		wantErr string
+	}{
+		{
+			name:  "signed hex matches a byte read as int8",
+			input: "assert(<b, -0x01)",
+			data:  []byte{0xff},
+			want:  []any{int8(-1)},
+		},
+		{
+			name:  "decimal",
+			input: "assert(<b, -1)",
+			data:  []byte{0xff},
+			want:  []any{int8(-1)},
+		},
+		{
+			name:  "magic number",
+			input: "assert(<I, 0x464c457f) | {0 -> magic}",
+			data:  []byte("\x7fELF"),
+			want:  &Object{Fields: []ObjectField{{Name: "magic", Value: uint32(0x464c457f)}}},
+		},
+		{
+			name:  "big-endian",
+			input: "assert(>h, -0x0102)",
+			data:  []byte{0xfe, 0xfe},
+			want:  []any{int16(-0x0102)},
+		},
+		{
+			name:    "mismatch names both values",
+			input:   "assert(<b, -0x01)",
+			data:    []byte{0x02},
+			wantErr: "assert: got 2 (0x02), want -1 (0xff)",
+		},
+		{
+			name:    "short input",
+			input:   "assert(<H, 1)",
+			data:    []byte{0x01},
+			wantErr: "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvalBytes(tt.input, tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EvalBytes() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalBytes() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAssertFunc(t *testing.T) {
+	for input, wantErr := range map[string]string{
+		"assert(<b, 0xff)":  "invalid assert value",
+		"assert(<B, -1)":    "invalid assert value",
+		"assert(<f, 1)":     "integer code",
+		"assert(<2B, 1)":    "single format code",
+		"assert(<b)":        "',' after assert code",
+		"assert(<b, x)":     "expected assert value",
+		"assert(<b, 1, 2)":  "')' after assert arguments",
+		"assert(<b, -0x80)": "",
+	} {
+		_, err := ParseExpression(input)
+		if wantErr == "" {
+			if err != nil {
+				t.Errorf("ParseExpression(%q) error = %v", input, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("ParseExpression(%q) error = %v, want %q", input, err, wantErr)
+		}
+	}
+}
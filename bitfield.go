@@ -0,0 +1,136 @@
+package bq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// isBitFieldCode returns true if the code can be split into bit-fields: the
+// unsigned integer codes, whose bit-fields are unsigned values of the same type.
+func isBitFieldCode(code rune) bool {
+	switch code {
+	case 'B', 'H', 'I', 'Q':
+		return true
+	default:
+		return false
+	}
+}
+
+// parseBitWidth parses the '/' NUMBER bit width of a bit-field code, e.g. B/3.
+func (p *Parser) parseBitWidth(code rune, size int) (int, error) {
+	pos := p.current.Pos
+	if err := p.advance(); err != nil {
+		return 0, err
+	}
+	if !isBitFieldCode(code) {
+		return 0, fmt.Errorf("bit-field at position %d needs an unsigned integer code B, H, I or Q, got %c", pos, code)
+	}
+
+	bits, err := p.parseInt("bit width")
+	if err != nil {
+		return 0, err
+	}
+	if bits < 1 || bits > size*8 {
+		return 0, fmt.Errorf("bit width of %c at position %d must be between 1 and %d, got %d", code, pos, size*8, bits)
+	}
+	return bits, nil
+}
+
+// nextBitOffset returns the offset from the most significant bit at which a new
+// bit-field of the code starts: right after the previous bit-field when it has the
+// same code and byte order and the new one still fits in their unit, or 0 to
+// start a new unit. A bit-field never spans two units.
+func nextBitOffset(formats []FormatCode, code rune, order ByteOrder, bits int) int {
+	if len(formats) == 0 {
+		return 0
+	}
+	last := formats[len(formats)-1]
+	if last.Bits == 0 || last.Code != code || last.Order != order {
+		return 0
+	}
+	if offset := last.BitOffset + last.Bits; offset+bits <= last.Size*8 {
+		return offset
+	}
+	return 0
+}
+
+// bitMask returns a mask of the low bits.
+func bitMask(bits int) uint64 {
+	return ^uint64(0) >> (64 - bits)
+}
+
+// readBits reads the value of a bit-field. The first bit-field of a unit reads
+// the unit, whose bits the following bit-fields of the unit share.
+func (fc *FormatCode) readBits(r io.Reader, unit *uint64) (any, error) {
+	if fc.BitOffset == 0 {
+		buf := make([]byte, fc.Size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("failed to read %d bytes for bit-field %c/%d: %w", fc.Size, fc.Code, fc.Bits, err)
+		}
+		*unit = decodeBitUnit(buf, fc.binaryOrder())
+	}
+
+	v := *unit >> (fc.Size*8 - fc.BitOffset - fc.Bits) & bitMask(fc.Bits)
+	switch fc.Code {
+	case 'B':
+		return uint8(v), nil
+	case 'H':
+		return uint16(v), nil
+	case 'I':
+		return uint32(v), nil
+	default:
+		return v, nil
+	}
+}
+
+// decodeBitUnit decodes the bytes of a bit-field unit as an unsigned integer.
+func decodeBitUnit(buf []byte, order binary.ByteOrder) uint64 {
+	switch len(buf) {
+	case 1:
+		return uint64(buf[0])
+	case 2:
+		return uint64(order.Uint16(buf))
+	case 4:
+		return uint64(order.Uint32(buf))
+	default:
+		return order.Uint64(buf)
+	}
+}
+
+// encodeBitUnit encodes the bits of a bit-field unit in size bytes.
+func encodeBitUnit(w io.Writer, unit uint64, size int, order binary.ByteOrder) error {
+	buf := make([]byte, 8)
+	switch size {
+	case 1:
+		buf[0] = uint8(unit)
+	case 2:
+		order.PutUint16(buf, uint16(unit))
+	case 4:
+		order.PutUint32(buf, uint32(unit))
+	default:
+		order.PutUint64(buf, unit)
+	}
+	_, err := w.Write(buf[:size])
+	return err
+}
+
+// closesBitUnit returns true if the bit-field formats[j] is the last one packed
+// into its unit: the last format, one followed by a format starting a new unit,
+// or the bit-field of the last value.
+func closesBitUnit(formats []FormatCode, j int, lastValue bool) bool {
+	return j+1 == len(formats) || formats[j+1].BitOffset == 0 || lastValue
+}
+
+// packBits places the value of a bit-field into its unit. A value wider than the
+// bit-field is an error.
+func (fc *FormatCode) packBits(unit uint64, val any) (uint64, error) {
+	v, _, err := integerBits(val)
+	if err != nil {
+		return 0, err
+	}
+	if v&^bitMask(fc.Bits) != 0 {
+		return 0, fmt.Errorf("value %v does not fit in %d bits", val, fc.Bits)
+	}
+	return unit | v<<(fc.Size*8-fc.BitOffset-fc.Bits), nil
+}
@@ -0,0 +1,108 @@
+package bq
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBitFieldRead(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		want   []any
+	}{
+		{"nibbles", "B/4 B/4", []byte{0xAB}, []any{uint8(0x0A), uint8(0x0B)}},
+		{"3 and 5 bits", "B/3 B/5", []byte{0xAB}, []any{uint8(0x05), uint8(0x0B)}},
+		{"named", "B/1:flag B/7:value", []byte{0x81}, []any{uint8(1), uint8(1)}},
+		{"big-endian unit", ">H/4 H/12", []byte{0x12, 0x34}, []any{uint16(0x1), uint16(0x234)}},
+		{"little-endian unit", "<H/4 H/12", []byte{0x34, 0x12}, []any{uint16(0x1), uint16(0x234)}},
+		{"full unit starts another", "B/5 B/5", []byte{0xF8, 0xA8}, []any{uint8(0x1F), uint8(0x15)}},
+		{"other code starts another", ">B/4 H/4", []byte{0xA0, 0xB0, 0x00}, []any{uint8(0x0A), uint16(0x0B)}},
+		{"whole values around", "B B/2 B/6 B", []byte{0x01, 0xC1, 0x02}, []any{uint8(1), uint8(3), uint8(1), uint8(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.format, err)
+			}
+			got, err := expr.Read(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+
+			// The bit-fields pack back into the bytes they were read from
+			var buf bytes.Buffer
+			if err := encodeFormatted(&buf, expr.Formats, got, toBinaryOrder(expr.Order)); err != nil {
+				t.Fatalf("encodeFormatted() error = %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.data) {
+				t.Errorf("encodeFormatted() = % x, want % x", buf.Bytes(), tt.data)
+			}
+		})
+	}
+}
+
+func TestClosesBitUnit(t *testing.T) {
+	expr, err := Parse("B/2 B/3 B/3 B/4 H")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		j         int
+		lastValue bool
+		want      bool
+	}{
+		{"more bit-fields in the unit", 0, false, false},
+		{"last bit-field of a full unit", 2, false, true},
+		{"followed by another code", 3, false, true},
+		{"bit-field of the last value", 1, true, true},
+		{"last format", 4, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := closesBitUnit(expr.Formats, tt.j, tt.lastValue); got != tt.want {
+				t.Errorf("closesBitUnit(%d, %v) = %v, want %v", tt.j, tt.lastValue, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBitFieldErrors(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr string
+	}{
+		{"b/3", "needs an unsigned integer code"},
+		{"B/9", "must be between 1 and 8"},
+		{"B/0", "must be between 1 and 8"},
+		{"2B/4", "cannot have a count"},
+		{"B/", "expected bit width"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.format); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q) error = %v, want it to contain %q", tt.format, err, tt.wantErr)
+		}
+	}
+
+	// A value wider than its bit-field cannot be packed
+	expr, err := Parse("B/4 B/4")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	err = encodeFormatted(&bytes.Buffer{}, expr.Formats, []any{uint8(0x10), uint8(0)}, toBinaryOrder(expr.Order))
+	if err == nil || !strings.Contains(err.Error(), "does not fit in 4 bits") {
+		t.Errorf("encodeFormatted() error = %v, want a width error", err)
+	}
+}
@@ -0,0 +1,117 @@
+package bq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Pixel characters of the bitmap preview.
+const (
+	bitmapSet   = "█"
+	bitmapClear = " "
+)
+
+// maxBitmapPixels caps the pixels of a bitmap preview, far beyond any glyph or
+// icon, so its size cannot overflow nor exhaust the memory.
+const maxBitmapPixels = 1 << 24
+
+// BitmapNode reads a packed monochrome (1 bit per pixel) image, such as a glyph
+// or icon in a ROM, as width*height bits, most significant bit first with rows
+// following each other without padding.
+type BitmapNode struct {
+	Width  int // pixels per row
+	Height int // number of rows
+}
+
+// Bitmap is the ASCII-art preview of a monochrome image, one string per row with
+// set pixels drawn as a block and clear pixels as a space.
+type Bitmap struct {
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Rows   []string `json:"rows"`
+}
+
+// Eval reads the bits of the image and returns its *Bitmap preview.
+func (n *BitmapNode) Eval(r io.Reader, _ []any) (any, error) {
+	size := (n.Width*n.Height + 7) / 8
+	buf, err := readN(r, int64(size))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("bitmap: %dx%d image needs %d bytes: %w", n.Width, n.Height, size, err)
+	}
+
+	bm := &Bitmap{Width: n.Width, Height: n.Height, Rows: make([]string, 0, n.Height)}
+	for y := 0; y < n.Height; y++ {
+		var row strings.Builder
+		for x := 0; x < n.Width; x++ {
+			bit := y*n.Width + x
+			if buf[bit/8]&(0x80>>(bit%8)) != 0 {
+				row.WriteString(bitmapSet)
+			} else {
+				row.WriteString(bitmapClear)
+			}
+		}
+		bm.Rows = append(bm.Rows, row.String())
+	}
+	return bm, nil
+}
+
+// WriteBitmap writes the rows of the preview, one per line.
+func WriteBitmap(w io.Writer, bm *Bitmap) error {
+	for _, row := range bm.Rows {
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBitmapFunc parses: 'bitmap' '(' NUMBER ',' NUMBER ',' NUMBER ')'
+func (p *Parser) parseBitmapFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'bitmap'"); err != nil {
+		return nil, err
+	}
+
+	pos := p.current.Pos
+	width, err := p.parseInt("bitmap width")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after bitmap width"); err != nil {
+		return nil, err
+	}
+	height, err := p.parseInt("bitmap height")
+	if err != nil {
+		return nil, err
+	}
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("bitmap size at position %d must be at least 1x1, got %dx%d", pos, width, height)
+	}
+	if width > maxBitmapPixels/height {
+		return nil, fmt.Errorf("bitmap size at position %d must be at most %d pixels, got %dx%d", pos, maxBitmapPixels, width, height)
+	}
+	if err := p.expect(TokenComma, "',' after bitmap height"); err != nil {
+		return nil, err
+	}
+
+	pos = p.current.Pos
+	depth, err := p.parseInt("bitmap bit depth")
+	if err != nil {
+		return nil, err
+	}
+	if depth != 1 {
+		return nil, fmt.Errorf("bitmap bit depth at position %d must be 1, got %d", pos, depth)
+	}
+
+	if err := p.expect(TokenRParen, "')' after bitmap bit depth"); err != nil {
+		return nil, err
+	}
+	return &BitmapNode{Width: width, Height: height}, nil
+}
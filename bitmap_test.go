@@ -0,0 +1,96 @@
+package bq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBitmapNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "8x3 glyph",
+			input: "bitmap(8, 3, 1)",
+			data:  []byte{0x18, 0x24, 0x81},
+			want:  []string{"   ██   ", "  █  █  ", "█      █"},
+		},
+		{
+			name:  "rows spanning bytes",
+			input: "bitmap(3, 3, 1)",
+			data:  []byte{0xAA, 0x80},
+			want:  []string{"█ █", " █ ", "█ █"},
+		},
+		{
+			name:    "insufficient data",
+			input:   "bitmap(8, 8, 1)",
+			data:    []byte{0x18, 0x24},
+			wantErr: true,
+		},
+		{
+			name:    "largest image on a short input",
+			input:   "bitmap(4096, 4096, 1)",
+			data:    []byte{0x18},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			bm := result.(*Bitmap)
+			if strings.Join(bm.Rows, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("EvalBytes() rows =\n%s\nwant:\n%s", strings.Join(bm.Rows, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestBitmapParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"bitmap(8, 8, 2)",
+		"bitmap(0, 8, 1)",
+		"bitmap(4611686018427387904, 4, 1)",
+		"bitmap(4294967296, 4294967296, 1)",
+		"bitmap(8, 8)",
+		"bitmap(8, 8, 1",
+		"B | bitmap(8, 8, 1)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestExecuteBitmap(t *testing.T) {
+	// The preview is printed with or without a table output
+	for _, opts := range []Options{{}, {Output: OutputTable}, {Output: OutputRaw}} {
+		var buf bytes.Buffer
+		if err := Execute("bitmap(4, 2, 1)", bytes.NewReader([]byte{0x9F}), &buf, opts); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if want := "█  █\n████\n"; buf.String() != want {
+			t.Errorf("Execute(%+v) = %q, want %q", opts, buf.String(), want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Execute("bitmap(4, 1, 1)", bytes.NewReader([]byte{0x90}), &buf, Options{Output: OutputJSON}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if want := `{"width":4,"height":1,"rows":["█  █"]}` + "\n"; buf.String() != want {
+		t.Errorf("Execute() JSON = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,307 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cTypeNames maps fixed-size format codes to their C fixed-width integer types,
+// or the C floating point types.
+var cTypeNames = map[rune]string{
+	'b': "int8_t",
+	'B': "uint8_t",
+	'h': "int16_t",
+	'H': "uint16_t",
+	'i': "int32_t",
+	'I': "uint32_t",
+	'q': "int64_t",
+	'Q': "uint64_t",
+	'o': "__int128",
+	'O': "unsigned __int128",
+	'f': "float",
+	'd': "double",
+	'x': "uint8_t", // padding
+}
+
+// byteOrderName returns the human-readable name of the byte order.
+func byteOrderName(order ByteOrder) string {
+	switch order {
+	case LittleEndian:
+		return "little-endian"
+	case BigEndian:
+		return "big-endian"
+	default:
+		return "native"
+	}
+}
+
+// GenerateC writes a packed C struct definition equivalent to the format expression.
+// Each member is named by its value index (f0, f1, ...), or pad0, pad1, ... for the
+// padding, and annotated with its byte offset. Null-terminated strings become a
+// comment, after which offsets are unknown, fixed-length strings (e.g., 16s) become
+// a char array, an array read until EOF (e.g., *B) a flexible array member, and a
+// bit-field (e.g., B/3) a C bit-field annotated with its bits, counted from the most
+// significant bit.
+func GenerateC(w io.Writer, expr *Expr, name string) error {
+	var sb strings.Builder
+
+	sb.WriteString("#include <stdint.h>\n\n")
+	fmt.Fprintf(&sb, "/* byte order: %s */\n", byteOrderName(expr.Order))
+	sb.WriteString("#pragma pack(push, 1)\n")
+	fmt.Fprintf(&sb, "struct %s {\n", name)
+
+	offset, unitOffset, known := 0, 0, true
+	values, pads := 0, 0
+	for _, fc := range expr.Formats {
+		count := fc.Count
+		if count == 0 {
+			count = 1
+		}
+
+		cType, ok := cTypeNames[fc.Code]
+		if fc.fixedString() {
+			cType, ok = "char", true
+		}
+		if !ok {
+			// Variable-length code: no fixed C representation
+			fmt.Fprintf(&sb, "    /* f%d: %s (variable length) */\n", values, formatCodeRegistry[fc.Code].typeName)
+			known = false
+			values++
+			continue
+		}
+
+		var member string
+		if fc.isPad() {
+			member = fmt.Sprintf("%s pad%d", cType, pads)
+			pads++
+		} else {
+			member = fmt.Sprintf("%s f%d", cType, values)
+			values++
+		}
+		if count > 1 {
+			member = fmt.Sprintf("%s[%d]", member, count)
+		}
+		if fc.Rest {
+			// The last member, so a flexible array member
+			fmt.Fprintf(&sb, "    %-24s /* offset %d, rest of the input */\n", member+"[];", offset)
+			continue
+		}
+		size, _ := fc.byteSize()
+		if fc.Bits > 0 {
+			// C leaves the bit order of a bit-field to the compiler, so name the bits
+			if fc.BitOffset == 0 {
+				unitOffset = offset
+			}
+			high := fc.Size*8 - 1 - fc.BitOffset
+			where := "offset unknown"
+			if known {
+				where = fmt.Sprintf("offset %d", unitOffset)
+			}
+			fmt.Fprintf(&sb, "    %-24s /* %s, bits %d-%d */\n", fmt.Sprintf("%s : %d;", member, fc.Bits), where, high, high-fc.Bits+1)
+			offset += size
+			continue
+		}
+		if known {
+			fmt.Fprintf(&sb, "    %-24s /* offset %d, %d bytes */\n", member+";", offset, size)
+		} else {
+			fmt.Fprintf(&sb, "    %-24s /* offset unknown, %d bytes */\n", member+";", size)
+		}
+		offset += size
+	}
+
+	sb.WriteString("};\n")
+	sb.WriteString("#pragma pack(pop)\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// pythonOrderPrefixes maps byte orders to the equivalent Python struct prefix.
+// Native order maps to '=' rather than '@', since bq never inserts the alignment
+// padding of Python's '@'.
+var pythonOrderPrefixes = map[ByteOrder]string{
+	NativeOrder:  "=",
+	LittleEndian: "<",
+	BigEndian:    ">",
+}
+
+// Explain writes a table annotating each format code of the expression with its
+// Go type, Python struct equivalent, C type and size, easing the sharing of
+// expressions with Python's struct and C code. Codes without a Python struct
+// equivalent (null-terminated strings and * counts) show '-', and variable sizes
+// show "var".
+// Padding, which has no value, shows '-' as its name and Go type.
+func Explain(w io.Writer, expr *Expr) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%-10s %-6s %-10s %-8s %-14s %s\n", "Name", "Code", "Go Type", "Python", "C Type", "Size")
+	values := 0
+	for _, fc := range expr.Formats {
+		count := fc.Count
+		if count == 0 {
+			count = 1
+		}
+
+		name, goType := fc.Name, formatCodeRegistry[fc.Code].typeName
+		if fc.isPad() {
+			name, goType = "-", "-"
+		} else {
+			if name == "" {
+				name = strconv.Itoa(values)
+			}
+			values++
+		}
+		counted := string(fc.Code)
+		if fc.Rest {
+			// Python struct has no count reading until the end
+			counted = "*" + counted
+			fmt.Fprintf(&sb, "%-10s %-6s %-10s %-8s %-14s %s\n", name, counted, "[]"+goType, "-", cTypeNames[fc.Code]+"[]", "var")
+			continue
+		}
+		if count > 1 && !fc.isPad() {
+			if !fc.fixedString() {
+				goType = "[]" + goType
+			}
+		}
+		if count > 1 {
+			counted = strconv.Itoa(count) + counted
+		}
+		if fc.Bits > 0 {
+			counted += "/" + strconv.Itoa(fc.Bits)
+		}
+
+		python, cType, size := "-", "char[]", "var"
+		t, ok := cTypeNames[fc.Code]
+		if fc.fixedString() {
+			t, ok = "char", true
+		}
+		if ok {
+			python = pythonOrderPrefixes[fc.Order] + counted
+			if fc.Code == 'o' || fc.Code == 'O' || fc.Bits > 0 {
+				// Python struct has no 128-bit code, nor bit-fields
+				python = "-"
+			}
+			cType = t
+			if count > 1 {
+				cType = fmt.Sprintf("%s[%d]", t, count)
+			}
+			if fc.Bits > 0 {
+				cType = fmt.Sprintf("%s:%d", t, fc.Bits)
+			}
+			n, _ := fc.byteSize()
+			size = strconv.Itoa(n)
+		}
+
+		fmt.Fprintf(&sb, "%-10s %-6s %-10s %-8s %-14s %s\n", name, counted, goType, python, cType, size)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// cTypeCodes maps C fixed-width integer typedefs and floating point types to
+// their format codes.
+var cTypeCodes = map[string]rune{
+	"int8_t":   'b',
+	"uint8_t":  'B',
+	"int16_t":  'h',
+	"uint16_t": 'H',
+	"int32_t":  'i',
+	"uint32_t": 'I',
+	"int64_t":  'q',
+	"uint64_t": 'Q',
+	// BSD-style and kernel-style aliases
+	"u_int8_t":  'B',
+	"u_int16_t": 'H',
+	"u_int32_t": 'I',
+	"u_int64_t": 'Q',
+	"s8":        'b',
+	"u8":        'B',
+	"s16":       'h',
+	"u16":       'H',
+	"s32":       'i',
+	"u32":       'I',
+	"s64":       'q',
+	"u64":       'Q',
+	// Plain character types
+	"char":          'b',
+	"signed char":   'b',
+	"unsigned char": 'B',
+	// Floating point types
+	"float":  'f',
+	"double": 'd',
+}
+
+var (
+	// cCommentPattern matches C block and line comments.
+	cCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	// cFieldPattern matches a field declaration such as `uint16_t x;` or `int8_t y[4];`.
+	cFieldPattern = regexp.MustCompile(`^((?:signed |unsigned )?[A-Za-z_][A-Za-z0-9_]*)\s+([A-Za-z_][A-Za-z0-9_]*)\s*(?:\[\s*(\d+)\s*\])?$`)
+)
+
+// FromC converts the field declarations of a simple C struct into the equivalent
+// bq expression: the format codes (with counts for arrays) in the given byte order,
+// piped into an object which names each value after its C member.
+func FromC(src string, order ByteOrder) (string, error) {
+	var codes strings.Builder
+	var fields []string
+
+	switch order {
+	case LittleEndian:
+		codes.WriteRune('<')
+	case BigEndian:
+		codes.WriteRune('>')
+	default:
+		codes.WriteRune('@')
+	}
+
+	// Drop comments and preprocessor directives, then keep only the struct body
+	var body strings.Builder
+	for _, line := range strings.Split(cCommentPattern.ReplaceAllString(src, ""), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			body.WriteString(line)
+			body.WriteString("\n")
+		}
+	}
+	decls := body.String()
+	if start, end := strings.Index(decls, "{"), strings.LastIndex(decls, "}"); start >= 0 && end > start {
+		decls = decls[start+1 : end]
+	}
+
+	for _, decl := range strings.Split(decls, ";") {
+		decl = strings.Join(strings.Fields(decl), " ")
+		if decl == "" {
+			continue
+		}
+
+		m := cFieldPattern.FindStringSubmatch(decl)
+		if m == nil {
+			return "", fmt.Errorf("unsupported declaration %q", decl)
+		}
+		code, ok := cTypeCodes[m[1]]
+		if !ok {
+			return "", fmt.Errorf("unsupported C type %q in declaration %q", m[1], decl)
+		}
+
+		if m[3] != "" {
+			count, err := strconv.Atoi(m[3])
+			if err != nil || count < 1 {
+				return "", fmt.Errorf("invalid array size in declaration %q", decl)
+			}
+			if count > 1 {
+				codes.WriteString(m[3])
+			}
+		}
+		codes.WriteRune(code)
+		fields = append(fields, fmt.Sprintf("%d -> %s", len(fields), m[2]))
+	}
+
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no field declarations found")
+	}
+
+	return fmt.Sprintf("%s | {%s}", codes.String(), strings.Join(fields, ", ")), nil
+}
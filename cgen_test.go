@@ -0,0 +1,216 @@
+package bq
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateC(t *testing.T) {
+	expr, err := Parse("<b4BHs")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateC(&buf, expr, "record"); err != nil {
+		t.Fatalf("GenerateC() error = %v", err)
+	}
+
+	want := `#include <stdint.h>
+
+/* byte order: little-endian */
+#pragma pack(push, 1)
+struct record {
+    int8_t f0;               /* offset 0, 1 bytes */
+    uint8_t f1[4];           /* offset 1, 4 bytes */
+    uint16_t f2;             /* offset 5, 2 bytes */
+    /* f3: string (variable length) */
+};
+#pragma pack(pop)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("GenerateC() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateCAfterString(t *testing.T) {
+	expr, err := Parse(">sI")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateC(&buf, expr, "record"); err != nil {
+		t.Fatalf("GenerateC() error = %v", err)
+	}
+
+	for _, want := range []string{"big-endian", "uint32_t f1;", "offset unknown, 4 bytes"} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("GenerateC() output missing %q\nGot:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestGenerateCPadding(t *testing.T) {
+	expr, err := Parse("<b3x8sH")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateC(&buf, expr, "record"); err != nil {
+		t.Fatalf("GenerateC() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"int8_t f0;               /* offset 0, 1 bytes */",
+		"uint8_t pad0[3];         /* offset 1, 3 bytes */",
+		"char f1[8];              /* offset 4, 8 bytes */",
+		"uint16_t f2;             /* offset 12, 2 bytes */",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("GenerateC() output missing %q\nGot:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestGenerateCRestArray(t *testing.T) {
+	expr, err := Parse("<H*I")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateC(&buf, expr, "record"); err != nil {
+		t.Fatalf("GenerateC() error = %v", err)
+	}
+
+	want := "uint32_t f1[];           /* offset 2, rest of the input */"
+	if !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("GenerateC() output missing %q\nGot:\n%s", want, buf.String())
+	}
+}
+
+func TestGenerateCBitFields(t *testing.T) {
+	expr, err := Parse("<B/3 B/5 >H/4")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateC(&buf, expr, "record"); err != nil {
+		t.Fatalf("GenerateC() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"uint8_t f0 : 3;          /* offset 0, bits 7-5 */",
+		"uint8_t f1 : 5;          /* offset 0, bits 4-0 */",
+		"uint16_t f2 : 4;         /* offset 1, bits 15-12 */",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("GenerateC() output missing %q\nGot:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	expr, err := Parse("<b4B>H:len@Is")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Explain(&buf, expr); err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	want := `Name       Code   Go Type    Python   C Type         Size
+0          b      int8       <b       int8_t         1
+1          4B     []uint8    <4B      uint8_t[4]     4
+len        H      uint16     >H       uint16_t       2
+3          I      uint32     =I       uint32_t       4
+4          s      string     -        char[]         var
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Explain() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFromC(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		order   ByteOrder
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "struct with arrays and comments",
+			src: `#include <stdint.h>
+
+/* file header */
+struct header {
+    uint16_t x;      // the width
+    int8_t y[4];
+    uint64_t size;
+};`,
+			order: LittleEndian,
+			want:  "<H4bQ | {0 -> x, 1 -> y, 2 -> size}",
+		},
+		{
+			name:  "multiple declarations per line",
+			src:   "struct p { u8 a; u32 b; unsigned char c[2]; };",
+			order: BigEndian,
+			want:  ">BI2B | {0 -> a, 1 -> b, 2 -> c}",
+		},
+		{
+			name:  "native order default",
+			src:   "int32_t v;",
+			order: NativeOrder,
+			want:  "@i | {0 -> v}",
+		},
+		{
+			name:  "floating point types",
+			src:   "struct s { float x; double y[2]; };",
+			order: LittleEndian,
+			want:  "<f2d | {0 -> x, 1 -> y}",
+		},
+		{
+			name:    "unsupported type",
+			src:     "bool b;",
+			wantErr: true,
+		},
+		{
+			name:    "no fields",
+			src:     "struct empty {};",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromC(tt.src, tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FromC() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromCRoundTrip(t *testing.T) {
+	got, err := FromC("uint8_t magic[4]; int16_t n;", LittleEndian)
+	if err != nil {
+		t.Fatalf("FromC() error = %v", err)
+	}
+
+	node, err := ParseExpression(got)
+	if err != nil {
+		t.Fatalf("ParseExpression(%q) error = %v", got, err)
+	}
+	if _, err := node.Eval(bytes.NewReader([]byte{1, 2, 3, 4, 5, 0}), nil); err != nil {
+		t.Errorf("Eval() error = %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package bq
+
+import (
+	"fmt"
+	"hash/adler32"
+	"hash/crc32"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// checksumAlgorithms maps the algorithms of checksum() to their 32-bit function.
+var checksumAlgorithms = map[string]func([]byte) uint32{
+	"crc32":   crc32.ChecksumIEEE,
+	"crc32c":  func(b []byte) uint32 { return crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)) },
+	"adler32": adler32.Checksum,
+}
+
+// ChecksumNode verifies a checksum stored in one field against the input bytes
+// covered by a range of fields, e.g. <H8BI | checksum(crc32, 2, 0, 1) checks the
+// CRC-32 in value 2 against the bytes of values 0 and 1.
+type ChecksumNode struct {
+	Algorithm string // algorithm name, a key of checksumAlgorithms
+	Stored    int    // index of the stored checksum value
+	First     int    // index of the first covered value
+	Last      int    // index of the last covered value
+}
+
+// Eval fails, as the covered bytes are only known to the pipe evaluating it.
+func (n *ChecksumNode) Eval(_ io.Reader, _ []any) (any, error) {
+	return nil, fmt.Errorf("checksum: must follow a format expression in a pipe, e.g. '<H8BI | checksum(crc32, 2, 0, 1)'")
+}
+
+// EvalConsumed computes the checksum of the bytes of the covered values, and
+// returns the values unchanged if it matches the stored value. A mismatch is an
+// error holding the covered byte range of the input and both checksums in hex.
+func (n *ChecksumNode) EvalConsumed(left Node, values []any, consumed []byte, start int64) (any, error) {
+	spans := fieldSpans(left, values)
+	if spans == nil {
+		return nil, fmt.Errorf("checksum: the byte ranges of the fields are only known after a format expression")
+	}
+	for _, idx := range []int{n.Stored, n.Last} {
+		if idx >= len(spans) {
+			return nil, fmt.Errorf("checksum: index %d out of range (have %d values)", idx, len(spans))
+		}
+	}
+
+	stored, err := toInt64(values[n.Stored])
+	if err != nil {
+		return nil, fmt.Errorf("checksum: stored value: %w", err)
+	}
+
+	from, to := spans[n.First].Offset, spans[n.Last].Offset+spans[n.Last].Size
+	if to > int64(len(consumed)) {
+		return nil, fmt.Errorf("checksum: covered bytes end at byte %d past the %d consumed bytes", to, len(consumed))
+	}
+	sum := checksumAlgorithms[n.Algorithm](consumed[from:to])
+	if sum != uint32(stored) {
+		return nil, fmt.Errorf("checksum: %s mismatch over bytes %#x-%#x (%d bytes): computed %#08x, stored %#08x",
+			n.Algorithm, start+from, start+to-1, to-from, sum, uint32(stored))
+	}
+	return values, nil
+}
+
+// parseChecksumFunc parses: 'checksum' '(' ChecksumAlgo ',' NUMBER ',' NUMBER ',' NUMBER ')'
+func (p *Parser) parseChecksumFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'checksum'"); err != nil {
+		return nil, err
+	}
+
+	p.rescanName()
+	algorithm := p.current.Value
+	if _, ok := checksumAlgorithms[algorithm]; p.current.Type != TokenIdent || !ok {
+		names := slices.Sorted(maps.Keys(checksumAlgorithms))
+		return nil, fmt.Errorf("expected checksum algorithm (%s) at position %d, got %q",
+			strings.Join(names, ", "), p.current.Pos, algorithm)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	node := &ChecksumNode{Algorithm: algorithm}
+	for _, arg := range []struct {
+		dst  *int
+		what string
+	}{
+		{&node.Stored, "stored checksum index"},
+		{&node.First, "first covered index"},
+		{&node.Last, "last covered index"},
+	} {
+		if err := p.expect(TokenComma, "',' before the "+arg.what); err != nil {
+			return nil, err
+		}
+		idx, err := p.parseInt(arg.what)
+		if err != nil {
+			return nil, err
+		}
+		*arg.dst = idx
+	}
+	if node.First > node.Last {
+		return nil, fmt.Errorf("checksum covered range %d-%d is reversed", node.First, node.Last)
+	}
+
+	if err := p.expect(TokenRParen, "')' after checksum arguments"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
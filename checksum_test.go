@@ -0,0 +1,107 @@
+package bq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/adler32"
+	"hash/crc32"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChecksumNodeEval(t *testing.T) {
+	// A length, an 8-byte body and the checksum of both
+	body := []byte{0x08, 0x00, 'h', 'e', 'l', 'l', 'o', '!', '!', '!'}
+	record := func(sum uint32) []byte {
+		return binary.LittleEndian.AppendUint32(slices.Clone(body), sum)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		wantErr string
+	}{
+		{
+			name:  "crc32 match",
+			input: "<H8BI | checksum(crc32, 2, 0, 1)",
+			data:  record(crc32.ChecksumIEEE(body)),
+		},
+		{
+			name:  "crc32c match",
+			input: "<H8BI | checksum(crc32c, 2, 0, 1)",
+			data:  record(crc32.Checksum(body, crc32.MakeTable(crc32.Castagnoli))),
+		},
+		{
+			name:  "adler32 over the body only",
+			input: "<H8BI | checksum(adler32, 2, 1, 1)",
+			data:  record(adler32.Checksum(body[2:])),
+		},
+		{
+			name:  "after a pass-through pipe",
+			input: `<H8BI | mark("end") | checksum(crc32, 2, 0, 1)`,
+			data:  record(crc32.ChecksumIEEE(body)),
+		},
+		{
+			name:    "mismatch reports the range and both sums",
+			input:   "<H8BI | checksum(crc32, 2, 1, 1)",
+			data:    record(0xDEADBEEF),
+			wantErr: "crc32 mismatch over bytes 0x2-0x9 (8 bytes): computed 0x066a9c77, stored 0xdeadbeef",
+		},
+		{
+			name:    "index out of range",
+			input:   "<H8BI | checksum(crc32, 3, 0, 1)",
+			data:    record(0),
+			wantErr: "index 3 out of range",
+		},
+		{
+			name:    "non-integer stored value",
+			input:   "<H8BI | checksum(crc32, 1, 0, 0)",
+			data:    record(0),
+			wantErr: "stored value",
+		},
+		{
+			name:    "no format expression",
+			input:   "<H8BI | {0 -> len, 1 -> body, 2 -> crc} | checksum(crc32, 2, 0, 1)",
+			data:    record(0),
+			wantErr: "only known after a format expression",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.input, err)
+			}
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Eval() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if values, ok := result.([]any); !ok || len(values) < 3 {
+				t.Errorf("Eval() = %v, want the values passed through", result)
+			}
+		})
+	}
+}
+
+func TestChecksumParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"<HI | checksum(md5, 1, 0, 0)",
+		"<HI | checksum(crc32, 1, 0)",
+		"<HI | checksum(crc32, 1, 0, x)",
+		"<HI | checksum(crc32, 1, 2, 0)",
+		"<HI | checksum(crc32, 1, 0, 0",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package bq
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/rs/zerolog"
@@ -12,14 +19,26 @@ import (
 func ParseAndRun() error {
 	var args Args
 
+	parser, err := newParser(&args)
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.Parse(os.Args[1:])
+	parser.FatalIfErrorf(err)
+	return args.Run()
+}
+
+// newParser creates the command-line parser filling args. As usual, a "--"
+// argument ends the flags, so an expression starting with '-' is taken literally.
+func newParser(args *Args) (*kong.Kong, error) {
 	options := []kong.Option{
 		kong.Name("bq"),
 		kong.Description("The binary query and modification tool."),
 		kong.UsageOnError(),
 	}
 
-	kong.Parse(&args, options...)
-	return args.Run()
+	return kong.New(args, options...)
 }
 
 // The command-line interface of the `bq` that setup and runs the
@@ -31,6 +50,115 @@ type Args struct {
 	// Pretty print the output in human-readable format.
 	Pretty bool `help:"Pretty print the output." short:"p"`
 
+	// The output format, overriding the pretty-print flag when given.
+	Output string `help:"Output format (${enum})." short:"o" enum:",table,raw,json,env,sql,tmpl,csv" default:"" placeholder:"FORMAT"`
+
+	// Print the result as JSON, a shorthand of the json output format.
+	JSON bool `help:"Print the result as a single line of JSON, same as --output json." name:"json"`
+
+	// Print the result as CSV, a shorthand of the csv output format.
+	CSV bool `help:"Print the result as CSV with a row per record, same as --output csv." name:"csv"`
+
+	// The table name of the SQL INSERT statements.
+	Table string `help:"Table name of the INSERT statements of the sql output." default:"records" placeholder:"NAME"`
+
+	// The Go template rendering the result of the tmpl output.
+	Template string `help:"Go text/template rendering the result of the tmpl output." placeholder:"TEMPLATE"`
+
+	// Warn about the input values an object leaves unmapped.
+	WarnUnmapped bool `help:"Warn about the input values of an object which no field refers to."`
+
+	// Render the value and hex in a single column.
+	CombinedHex bool `help:"Render the value column as '<decimal> (<hex>)' instead of a separate Hex column."`
+
+	// Omit the table header and the record count footer.
+	NoHeader bool `help:"Omit the table header and the record count footer of record streams."`
+
+	// Select and order the columns of the pretty-print table.
+	Columns []string `help:"Comma-separated columns of the table, in order (name,code,type,value,hex)." placeholder:"COLUMNS"`
+
+	// The color theme of the table output.
+	ColorTheme string `help:"Color theme of the table output (${enum})." enum:",dark,light,mono" default:"" placeholder:"THEME"`
+
+	// Render array types with their length in the Type column.
+	ArrayLen bool `help:"Render array types with their length in the Type column (e.g. [4]uint8)."`
+
+	// Print a hexdump of the consumed bytes after the result.
+	WithHex bool `help:"Print a hexdump of the consumed bytes and each field's byte range after the result."`
+
+	// Re-encode the result and compare it against the consumed bytes.
+	Verify bool `help:"Re-encode the result and report any bytes differing from the consumed input."`
+
+	// Validate the input without printing the result.
+	Check bool `help:"Validate the input without printing the result, exiting non-zero with a concise error on failure."`
+
+	// Print the total number of bytes consumed as the final line.
+	PrintConsumed bool `help:"Print the total number of bytes consumed as the final line."`
+
+	// Open the input file for reading and writing, as required by patch().
+	InPlace bool `help:"Open the input file read-write so patch() can modify it in place." name:"in-place"`
+
+	// Read the input as a hex string rather than binary.
+	InputHex bool `help:"Read the input as a hex string, such as ff0102, ignoring whitespace." name:"input-hex"`
+
+	// Values encoded as the input instead of reading a file.
+	Data string `help:"Comma-separated integer values encoded by the format codes of the expression as the input instead of a file, such as --data=-0x01,513." placeholder:"VALUES"`
+
+	// The number of bytes skipped from the start of the input before parsing. The
+	// short flag is -O, since -o selects the output format.
+	Offset int64 `help:"Skip this many bytes of the input before parsing, also on stdin (-o is --output, so the short flag is -O)." short:"O" placeholder:"BYTES"`
+
+	// The maximum total bytes read from the input.
+	MaxBytes int64 `help:"Maximum total bytes read from the input (0 for no limit)." placeholder:"BYTES"`
+
+	// Flush the output after each record of repeat(), for a live input.
+	Follow bool `help:"Flush the output after each record of repeat(), so a live input such as a socket is printed as it arrives (always on for a terminal)."`
+
+	// Abort when a read from a pipe or socket stalls for longer than this.
+	Timeout time.Duration `help:"Abort when a read from a pipe or socket gets no data for this long (0 for no timeout)." placeholder:"DURATION"`
+
+	// The maximum bytes read for a null-terminated string before giving up.
+	MaxStringLen int `help:"Maximum bytes read for a null-terminated string." default:"65536"`
+
+	// Keep the trailing NULs of fixed-length strings, showing their padding.
+	NoTrim bool `help:"Keep the trailing NULs of fixed-length strings instead of trimming them." name:"no-trim"`
+
+	// Trim more padding characters than the NULs from fixed-length strings.
+	TrimSet string `help:"Characters trimmed from the end of fixed-length strings along with the NULs, such as a space." placeholder:"CHARS"`
+
+	// Resolve the 'l'/'L' aliases as the 64-bit LP64 C long.
+	CLong bool `help:"Treat the 'l'/'L' aliases as 64-bit (LP64 C long) instead of 32-bit." name:"c-long"`
+
+	// The number of digits after the decimal point when printing floats.
+	FloatPrecision int `help:"Digits after the decimal point for floats (0 for shortest round-trip form)." placeholder:"N"`
+
+	// Print the grammar of the expression language and exit.
+	ShowGrammar bool `help:"Print the expression grammar with examples and exit." name:"grammar"`
+
+	// Print a reference table of the format codes and exit.
+	ShowCodes bool `help:"Print each format code with its size, signedness and type name, and exit." name:"help-codes"`
+
+	// Print the native byte order of the current platform and exit.
+	ShowNativeOrder bool `help:"Print the byte order '@' resolves to on this platform and exit." name:"native-order"`
+
+	// Print the format expression as a packed C struct definition instead of running it.
+	GenC bool `help:"Print the format expression as a C struct definition." name:"gen-c"`
+
+	// Print each format code with its Python struct and C equivalents instead of running it.
+	Explain bool `help:"Print each format code with its Go type, Python struct code, C type and size."`
+
+	// Print the parse tree of the expression as a GraphViz DOT graph instead of running it.
+	Dot bool `help:"Print the parse tree of the expression as a GraphViz DOT graph."`
+
+	// Convert a C struct definition into the equivalent bq expression.
+	FromC string `help:"Print the bq expression equivalent to the C struct in the given header." name:"from-c" type:"existingfile" placeholder:"HEADER"`
+
+	// Read the expression from a file, expanding its @include directives.
+	ExprFile string `help:"Read the expression from the given file, taking the only argument as the input file." name:"expr-file" type:"existingfile" placeholder:"FILE"`
+
+	// Use a bundled expression, reading the section table of an executable.
+	Preset string `help:"Read the section table of an executable with a bundled expression (${enum}), taking the only argument as the input file." enum:",elf-sections,pe-sections" default:"" placeholder:"NAME"`
+
 	// The expression to be applied on the file content, omitted means reading and
 	// printing the content as is.
 	Expr *string `help:"The expression to be applied on the file content." arg:"" optional:""`
@@ -62,7 +190,17 @@ func (a *Args) prologue() {
 		zerolog.SetGlobalLevel(zerolog.TraceLevel)
 	}
 
+	// The unmapped value warnings are shown without raising the verbosity
+	if a.WarnUnmapped && zerolog.GlobalLevel() > zerolog.WarnLevel {
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	}
+
 	writer := zerolog.ConsoleWriter{Out: os.Stderr}
+	if a.Check {
+		// A check in a pipeline reports a bare error line
+		writer.NoColor = true
+		writer.PartsExclude = []string{zerolog.TimestampFieldName}
+	}
 	log.Logger = zerolog.New(writer).With().Timestamp().Logger()
 
 	log.Debug().Int("verbosity", a.Verbose).Msg("completed prologue ...")
@@ -77,10 +215,322 @@ func (a *Args) epilogue() {
 func (a *Args) run() error {
 	log.Debug().Any("args", a).Msg("running ...")
 
+	if a.ShowGrammar {
+		return WriteGrammar(os.Stdout)
+	}
+
+	if a.ShowCodes {
+		return WriteFormatCodes(os.Stdout)
+	}
+
+	if a.ShowNativeOrder {
+		_, err := fmt.Fprintln(os.Stdout, nativeOrderName())
+		return err
+	}
+
+	if a.FromC != "" {
+		src, err := os.ReadFile(a.FromC)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to read C header")
+			return err
+		}
+		expr, err := FromC(string(src), NativeOrder)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to convert C struct")
+			return err
+		}
+		_, err = fmt.Fprintln(os.Stdout, expr)
+		return err
+	}
+
+	if a.ExprFile != "" {
+		if err := a.loadExprFile(); err != nil {
+			log.Error().Err(err).Msg("failed to load expression file")
+			return err
+		}
+		if a.File != os.Stdin {
+			defer func() { _ = a.File.Close() }()
+		}
+	}
+
+	if a.Preset != "" {
+		if err := a.loadPreset(); err != nil {
+			log.Error().Err(err).Msg("failed to load preset")
+			return err
+		}
+		if a.File != os.Stdin {
+			defer func() { _ = a.File.Close() }()
+		}
+	}
+
 	if a.Expr == nil {
 		log.Info().Msg("no expression provided, nothing to do")
 		return nil
 	}
 
-	return Execute(*a.Expr, a.File, a.Pretty)
+	if a.GenC || a.Explain || a.Dot {
+		return a.describe(os.Stdout, a.options(""))
+	}
+
+	output, err := a.outputFormat()
+	if err != nil {
+		log.Error().Err(err).Msg("invalid output format")
+		return err
+	}
+	opts := a.options(output)
+
+	input := a.File
+	if a.InPlace {
+		if input == os.Stdin {
+			return fmt.Errorf("--in-place requires an input file, not stdin")
+		}
+		f, err := os.OpenFile(input.Name(), os.O_RDWR, 0)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to open input file for writing")
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		input = f
+	}
+
+	var r io.Reader = input
+	if a.Data != "" {
+		if a.InPlace || a.InputHex || input != os.Stdin {
+			return fmt.Errorf("--data cannot be combined with an input file, --in-place or --input-hex")
+		}
+		data, err := dataInput(*a.Expr, a.Data, opts)
+		if err != nil {
+			log.Error().Err(err).Msg("invalid data values")
+			return err
+		}
+		r = data
+	}
+	if a.InputHex {
+		if a.InPlace {
+			return fmt.Errorf("--input-hex cannot be combined with --in-place")
+		}
+		decoded, err := hexInput(input)
+		if err != nil {
+			log.Error().Err(err).Msg("invalid hex input")
+			return err
+		}
+		r = decoded
+	}
+	if err := skipInput(r, a.Offset); err != nil {
+		log.Error().Err(err).Msg("invalid input offset")
+		return err
+	}
+	return Execute(*a.Expr, r, os.Stdout, opts)
+}
+
+// options returns the options of the expression evaluation in the output format.
+func (a *Args) options(output string) Options {
+	return Options{
+		Pretty:         a.Pretty,
+		Output:         output,
+		Table:          a.Table,
+		Template:       a.Template,
+		WarnUnmapped:   a.WarnUnmapped,
+		WithHex:        a.WithHex,
+		Offset:         a.Offset,
+		Verify:         a.Verify,
+		MaxStringLen:   a.MaxStringLen,
+		NoTrim:         a.NoTrim,
+		TrimSet:        a.TrimSet,
+		MaxBytes:       a.MaxBytes,
+		Timeout:        a.Timeout,
+		CLong:          a.CLong,
+		FloatPrecision: a.FloatPrecision,
+		CombinedHex:    a.CombinedHex,
+		ArrayLen:       a.ArrayLen,
+		ColorTheme:     a.ColorTheme,
+		Columns:        a.Columns,
+		NoHeader:       a.NoHeader,
+		PrintConsumed:  a.PrintConsumed,
+		Check:          a.Check,
+		Follow:         a.Follow,
+	}
+}
+
+// describe prints the expression as a C struct (--gen-c), a table of its format
+// codes (--explain) or its parse tree (--dot) instead of running it. It parses
+// with the same options as a run, so an alias such as 'l' under --c-long is
+// described as the code it decodes to.
+func (a *Args) describe(w io.Writer, opts Options) error {
+	if a.GenC || a.Explain {
+		expr, err := ParseWithOptions(*a.Expr, opts)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to parse expression")
+			return err
+		}
+		if a.GenC {
+			return GenerateC(w, expr, "record")
+		}
+		return Explain(w, expr)
+	}
+
+	node, err := ParseExpressionWithOptions(*a.Expr, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse expression")
+		return err
+	}
+	return WriteDOT(w, node)
+}
+
+// hexInput reads the whole input as a hex string and returns the decoded bytes,
+// ignoring any whitespace such as line breaks and the spaces between bytes.
+func hexInput(r io.Reader) (io.Reader, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	digits := strings.Join(strings.Fields(string(text)), "")
+
+	data, err := hex.DecodeString(digits)
+	switch {
+	case errors.Is(err, hex.ErrLength):
+		return nil, fmt.Errorf("--input-hex: odd number of hex digits (%d)", len(digits))
+	case err != nil:
+		return nil, fmt.Errorf("--input-hex: %w", err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// dataInput encodes the comma-separated integer literals of --data, decimal or
+// hex and optionally signed (e.g., -0x01), by the format codes of the expression
+// as the input, so an expression can be tried without writing a file.
+func dataInput(expr, data string, opts Options) (io.Reader, error) {
+	node, err := ParseExpressionWithOptions(expr, opts)
+	if err != nil {
+		return nil, err
+	}
+	format, ok := extractFormatNode(node)
+	if !ok {
+		return nil, fmt.Errorf("--data needs an expression reading format codes, such as <bH")
+	}
+
+	var codes []FormatCode
+	for _, fc := range format.Formats {
+		if fc.isPad() {
+			continue
+		}
+		if !isIntegerCode(fc.Code) || fc.Count != 1 || fc.Rest || fc.Bits > 0 {
+			return nil, fmt.Errorf("--data encodes single integer codes, got %c", fc.Code)
+		}
+		codes = append(codes, fc)
+	}
+	literals := strings.Split(data, ",")
+	if len(literals) != len(codes) {
+		return nil, fmt.Errorf("--data: got %d values, the expression reads %d", len(literals), len(codes))
+	}
+
+	values := make([]any, len(codes))
+	for i, fc := range codes {
+		if values[i], err = parseIntegerValue(fc, strings.TrimSpace(literals[i])); err != nil {
+			return nil, fmt.Errorf("--data: value %d: %w", i, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encodeFormatted(&buf, format.Formats, values, toBinaryOrder(format.Order)); err != nil {
+		return nil, fmt.Errorf("--data: %w", err)
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// skipInput discards the first offset bytes of the input. The bytes are read
+// rather than seeked over, so a non-seekable input such as stdin works too.
+func skipInput(r io.Reader, offset int64) error {
+	if offset < 0 {
+		return fmt.Errorf("--offset must not be negative, got %d", offset)
+	}
+	n, err := io.CopyN(io.Discard, r, offset)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("--offset %d is past the end of the %d-byte input", offset, n)
+	}
+	return err
+}
+
+// outputFormat returns the output format selected by --output or its --json and
+// --csv shorthands, which cannot ask for different formats.
+func (a *Args) outputFormat() (string, error) {
+	output, from := a.Output, "--output "+a.Output
+	for _, shorthand := range []struct {
+		set    bool
+		output string
+	}{
+		{a.JSON, OutputJSON},
+		{a.CSV, OutputCSV},
+	} {
+		if !shorthand.set {
+			continue
+		}
+		if output != "" && output != shorthand.output {
+			return "", fmt.Errorf("--%s conflicts with %s", shorthand.output, from)
+		}
+		output, from = shorthand.output, "--"+shorthand.output
+	}
+	return output, nil
+}
+
+// presets are the bundled expressions of --preset, reading the section table of
+// an executable by following the offset and count fields of its header:
+//
+//   - elf-sections: the section headers of a 64-bit little-endian ELF file, at
+//     e_shoff (offset 40) with e_shnum (offset 60) entries.
+//   - pe-sections: the section headers of a PE file, after the optional header of
+//     the PE header found at e_lfanew (offset 0x3c).
+var presets = map[string]string{
+	"elf-sections": "<40x Q:shoff 12x H:shnum | table(shoff, shnum, <I:name I:type Q:flags Q:addr " +
+		"Q:offset Q:size I:link I:info Q:align Q:entsize)",
+	"pe-sections": "seek(0x3c) | <I:pe | at(pe, <4x H:machine H:sections 12x H:optional_size 2x | " +
+		"table(+optional_size, sections, <8s:name I:vsize I:vaddr I:size I:offset I:reloc_at I:line_at " +
+		"H:nreloc H:nline I:flags))",
+}
+
+// loadPreset replaces the expression with the preset of --preset, taking the
+// only argument as the input file as --expr-file does.
+func (a *Args) loadPreset() error {
+	if a.ExprFile != "" {
+		return fmt.Errorf("--preset cannot be combined with --expr-file")
+	}
+	expr, ok := presets[a.Preset]
+	if !ok {
+		return fmt.Errorf("unknown preset %q", a.Preset)
+	}
+
+	if a.Expr != nil {
+		if a.File != os.Stdin {
+			return fmt.Errorf("--preset takes the input file as the only argument")
+		}
+		f, err := os.Open(*a.Expr)
+		if err != nil {
+			return err
+		}
+		a.File = f
+	}
+	a.Expr = &expr
+	return nil
+}
+
+// loadExprFile reads the expression from the expression file. The positional
+// argument which would hold the expression names the input file instead.
+func (a *Args) loadExprFile() error {
+	if a.Expr != nil {
+		if a.File != os.Stdin {
+			return fmt.Errorf("--expr-file takes the input file as the only argument")
+		}
+		f, err := os.Open(*a.Expr)
+		if err != nil {
+			return err
+		}
+		a.File = f
+	}
+
+	expr, err := LoadExpression(a.ExprFile)
+	if err != nil {
+		return err
+	}
+	a.Expr = &expr
+	return nil
 }
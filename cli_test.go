@@ -0,0 +1,547 @@
+package bq
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseArgsDoubleDash(t *testing.T) {
+	tests := []struct {
+		name    string
+		argv    []string
+		want    string
+		wantErr bool
+	}{
+		{"plain expression", []string{"-v", "<bH"}, "<bH", false},
+		{"dash prefixed expression after --", []string{"-v", "--", "-bias"}, "-bias", false},
+		{"dash prefixed expression without --", []string{"-v", "-bias"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args Args
+			parser, err := newParser(&args)
+			if err != nil {
+				t.Fatalf("newParser() error = %v", err)
+			}
+
+			_, err = parser.Parse(tt.argv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.argv, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if args.Expr == nil || *args.Expr != tt.want {
+				t.Errorf("Parse(%q) expression = %v, want %q", tt.argv, args.Expr, tt.want)
+			}
+			if args.Verbose != 1 {
+				t.Errorf("Parse(%q) verbose = %d, want 1", tt.argv, args.Verbose)
+			}
+		})
+	}
+}
+
+func TestParseArgsExprFile(t *testing.T) {
+	dir := t.TempDir()
+	exprPath := filepath.Join(dir, "main.bq")
+	dataPath := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(exprPath, []byte("<H\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.WriteFile(dataPath, []byte{0x01, 0x00}, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	var args Args
+	parser, err := newParser(&args)
+	if err != nil {
+		t.Fatalf("newParser() error = %v", err)
+	}
+	if _, err := parser.Parse([]string{"--expr-file", exprPath, dataPath}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// The only argument is the input file rather than the expression
+	if err := args.loadExprFile(); err != nil {
+		t.Fatalf("loadExprFile() error = %v", err)
+	}
+	defer func() { _ = args.File.Close() }()
+	if *args.Expr != "<H\n" {
+		t.Errorf("loadExprFile() expression = %q, want %q", *args.Expr, "<H\n")
+	}
+	if args.File.Name() != dataPath {
+		t.Errorf("loadExprFile() input = %q, want %q", args.File.Name(), dataPath)
+	}
+}
+
+func TestArgsOutputFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		argv    []string
+		want    string
+		wantErr bool
+	}{
+		{"no format", []string{"<bH"}, "", false},
+		{"output flag", []string{"-o", "sql", "<bH"}, OutputSQL, false},
+		{"json shorthand", []string{"--json", "<bH"}, OutputJSON, false},
+		{"json shorthand with json output", []string{"--json", "-o", "json", "<bH"}, OutputJSON, false},
+		{"json shorthand with another output", []string{"--json", "-o", "raw", "<bH"}, "", true},
+		{"csv shorthand", []string{"--csv", "<bH"}, OutputCSV, false},
+		{"json and csv shorthands", []string{"--json", "--csv", "<bH"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args Args
+			parser, err := newParser(&args)
+			if err != nil {
+				t.Fatalf("newParser() error = %v", err)
+			}
+			if _, err := parser.Parse(tt.argv); err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.argv, err)
+			}
+
+			got, err := args.outputFormat()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("outputFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("outputFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipInput(t *testing.T) {
+	// A 4-byte header before the record
+	record := []byte{0xff, 0x01, 0x02}
+	data := append([]byte{0xde, 0xad, 0xbe, 0xef}, record...)
+	expr := "<bH | {0 -> key, 1 -> value}"
+
+	want, err := EvalBytes(expr, record)
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+
+	// A multi-reader hides the Seek method, as for stdin
+	r := io.MultiReader(bytes.NewReader(data))
+	if err := skipInput(r, 4); err != nil {
+		t.Fatalf("skipInput() error = %v", err)
+	}
+	node, err := ParseExpression(expr)
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	got, err := node.Eval(newCountingReader(r), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parse after skipInput() = %v, want %v", got, want)
+	}
+
+	for _, offset := range []int64{-1, 8} {
+		if err := skipInput(bytes.NewReader(data), offset); err == nil {
+			t.Errorf("skipInput(%d) expected error, got nil", offset)
+		}
+	}
+}
+
+func TestSkipInputOffsets(t *testing.T) {
+	data := []byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x00}
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	run := func(expr string, r io.Reader, opts Options) string {
+		t.Helper()
+		var buf bytes.Buffer
+		opts.Output = OutputJSON
+		opts.PrintConsumed = true
+		if err := Execute(expr, r, &buf, opts); err != nil {
+			t.Fatalf("Execute(%q) error = %v", expr, err)
+		}
+		return buf.String()
+	}
+
+	// seek() on the file is the reference: the mark at offset 6, 2 bytes read
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	want := run(`seek(4) | <H | mark("m")`, f, Options{})
+	if want != "[1,6]\n2\n" {
+		t.Fatalf("Execute() with seek = %q, want %q", want, "[1,6]\n2\n")
+	}
+
+	// --offset on the seekable file
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+	if err := skipInput(f, 4); err != nil {
+		t.Fatalf("skipInput() error = %v", err)
+	}
+	if got := run(`<H | mark("m")`, f, Options{Offset: 4}); got != want {
+		t.Errorf("Execute() after --offset on a file = %q, want %q", got, want)
+	}
+
+	// --offset on a non-seekable input such as stdin
+	r := io.MultiReader(bytes.NewReader(data))
+	if err := skipInput(r, 4); err != nil {
+		t.Fatalf("skipInput() error = %v", err)
+	}
+	if got := run(`<H | mark("m")`, r, Options{Offset: 4}); got != want {
+		t.Errorf("Execute() after --offset on stdin = %q, want %q", got, want)
+	}
+}
+
+func TestHexInput(t *testing.T) {
+	r, err := hexInput(strings.NewReader("ff0102\n"))
+	if err != nil {
+		t.Fatalf("hexInput() error = %v", err)
+	}
+	node, err := ParseExpression("<bH")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	got, err := node.Eval(newCountingReader(r), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if want := []any{int8(-1), uint16(0x0201)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Eval() over the hex input = %v, want %v", got, want)
+	}
+
+	for input, wantErr := range map[string]string{
+		"ff010":  "odd number of hex digits",
+		"ff01zz": "invalid byte",
+	} {
+		if _, err := hexInput(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("hexInput(%q) error = %v, want it to contain %q", input, err, wantErr)
+		}
+	}
+}
+
+func TestArgsDescribeCLong(t *testing.T) {
+	tests := []struct {
+		name string
+		argv []string
+		want string
+	}{
+		{"gen-c", []string{"--gen-c", "<l"}, "int32_t"},
+		{"gen-c with c-long", []string{"--gen-c", "--c-long", "<l"}, "int64_t"},
+		{"explain", []string{"--explain", "<L"}, "uint32"},
+		{"explain with c-long", []string{"--explain", "--c-long", "<L"}, "uint64"},
+		{"dot with c-long", []string{"--dot", "--c-long", "<l"}, `FormatNode\n<q`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args Args
+			parser, err := newParser(&args)
+			if err != nil {
+				t.Fatalf("newParser() error = %v", err)
+			}
+			if _, err := parser.Parse(tt.argv); err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.argv, err)
+			}
+
+			var buf bytes.Buffer
+			if err := args.describe(&buf, args.options("")); err != nil {
+				t.Fatalf("describe() error = %v", err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("describe() output missing %q\nGot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestDataInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		data    string
+		want    any
+		wantErr string
+	}{
+		{"signed hex", "<bH", "-0x01, 0x0201", []any{int8(-1), uint16(0x0201)}, ""},
+		{"padding and byte order", ">Bx>h", "1,-2", []any{uint8(1), int16(-2)}, ""},
+		{"assert", "assert(<b, -0x01)", "-0x01", []any{int8(-1)}, ""},
+		{"too few values", "<bH", "1", nil, "got 1 values, the expression reads 2"},
+		{"value out of range", "<b", "0x80", nil, "value 0"},
+		{"not an integer code", "<f", "1", nil, "single integer codes"},
+		{"array", "<4B", "1", nil, "single integer codes"},
+		{"no format codes", "pb()", "1", nil, "format codes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := dataInput(tt.expr, tt.data, Options{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("dataInput() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dataInput() error = %v", err)
+			}
+
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			got, err := node.Eval(newCountingReader(r), nil)
+			if err != nil {
+				t.Fatalf("Eval() over the data input error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval() over the data input = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgsPreset(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "a.out")
+	if err := os.WriteFile(dataPath, []byte{0x7f, 'E', 'L', 'F'}, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	var args Args
+	parser, err := newParser(&args)
+	if err != nil {
+		t.Fatalf("newParser() error = %v", err)
+	}
+	if _, err := parser.Parse([]string{"--preset", "elf-sections", dataPath}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// The only argument is the input file rather than the expression
+	if err := args.loadPreset(); err != nil {
+		t.Fatalf("loadPreset() error = %v", err)
+	}
+	defer func() { _ = args.File.Close() }()
+	if *args.Expr != presets["elf-sections"] {
+		t.Errorf("loadPreset() expression = %q, want %q", *args.Expr, presets["elf-sections"])
+	}
+	if args.File.Name() != dataPath {
+		t.Errorf("loadPreset() input = %q, want %q", args.File.Name(), dataPath)
+	}
+
+	args = Args{Preset: "elf-sections", ExprFile: "main.bq"}
+	if err := args.loadPreset(); err == nil {
+		t.Error("loadPreset() with --expr-file succeeded, want an error")
+	}
+}
+
+func TestPresetsInSync(t *testing.T) {
+	field, _ := reflect.TypeOf(Args{}).FieldByName("Preset")
+	names := strings.Split(field.Tag.Get("enum"), ",")[1:]
+	if len(names) != len(presets) {
+		t.Errorf("--preset lists %d presets, want %d", len(names), len(presets))
+	}
+	for _, name := range names {
+		expr, ok := presets[name]
+		if !ok {
+			t.Errorf("--preset lists %q, which is not a preset", name)
+			continue
+		}
+		if _, err := ParseExpression(expr); err != nil {
+			t.Errorf("preset %q: ParseExpression() error = %v", name, err)
+		}
+	}
+}
+
+// elfSection is a section header as read by the elf-sections preset.
+type elfSection struct {
+	Name    uint32 `json:"name"`
+	Type    uint32 `json:"type"`
+	Flags   uint64 `json:"flags"`
+	Addr    uint64 `json:"addr"`
+	Offset  uint64 `json:"offset"`
+	Size    uint64 `json:"size"`
+	Link    uint32 `json:"link"`
+	Info    uint32 `json:"info"`
+	Align   uint64 `json:"align"`
+	Entsize uint64 `json:"entsize"`
+}
+
+// runPreset runs a preset over the input and decodes its JSON output.
+func runPreset(t *testing.T, name string, input io.Reader, out any) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Execute(presets[name], input, &buf, Options{Output: OutputJSON}); err != nil {
+		t.Fatalf("Execute(%s) error = %v", name, err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		t.Fatalf("Execute(%s) output %s: %v", name, buf.String(), err)
+	}
+}
+
+// checkELFSections compares the sections read by the elf-sections preset with
+// the ones read by debug/elf, names included.
+func checkELFSections(t *testing.T, data io.ReaderAt, got []elfSection) {
+	t.Helper()
+	f, err := elf.NewFile(data)
+	if err != nil {
+		t.Fatalf("elf.NewFile() error = %v", err)
+	}
+	if len(got) != len(f.Sections) {
+		t.Fatalf("elf-sections read %d sections, want %d", len(got), len(f.Sections))
+	}
+	shstrtab := f.Section(".shstrtab")
+	if shstrtab == nil {
+		t.Fatal("no .shstrtab section")
+	}
+	strtab, err := shstrtab.Data()
+	if err != nil {
+		t.Fatalf("Data() error = %v", err)
+	}
+
+	for i, s := range f.Sections {
+		want := elfSection{
+			Name: got[i].Name, Type: uint32(s.Type), Flags: uint64(s.Flags), Addr: s.Addr, Offset: s.Offset,
+			Size: s.Size, Link: s.Link, Info: s.Info, Align: s.Addralign, Entsize: s.Entsize,
+		}
+		if got[i] != want {
+			t.Errorf("section %d = %+v, want %+v", i, got[i], want)
+		}
+		if name, _, _ := bytes.Cut(strtab[got[i].Name:], []byte{0}); string(name) != s.Name {
+			t.Errorf("section %d name = %q, want %q", i, name, s.Name)
+		}
+	}
+}
+
+func TestPresetELFSections(t *testing.T) {
+	// An ELF file of a null section, .text and the section name table
+	strtab := []byte("\x00.text\x00.shstrtab\x00")
+	text := []byte{0xc3}
+	header := elf.Header64{
+		Type: uint16(elf.ET_EXEC), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT),
+		Shoff: 64, Ehsize: 64, Shentsize: 64, Shnum: 3, Shstrndx: 2,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	dataOffset := uint64(64 + 3*64)
+	sections := []elf.Section64{
+		{},
+		{
+			Name: 1, Type: uint32(elf.SHT_PROGBITS), Flags: uint64(elf.SHF_ALLOC | elf.SHF_EXECINSTR),
+			Addr: 0x401000, Off: dataOffset, Size: uint64(len(text)), Addralign: 16,
+		},
+		{Name: 7, Type: uint32(elf.SHT_STRTAB), Off: dataOffset + 1, Size: uint64(len(strtab)), Addralign: 1},
+	}
+
+	var buf bytes.Buffer
+	for _, v := range []any{header, sections, text, strtab} {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatalf("binary.Write() error = %v", err)
+		}
+	}
+
+	var got []elfSection
+	runPreset(t, "elf-sections", bytes.NewReader(buf.Bytes()), &got)
+	checkELFSections(t, bytes.NewReader(buf.Bytes()), got)
+}
+
+func TestPresetELFSectionsExecutable(t *testing.T) {
+	// The test binary itself is a real ELF file on little-endian 64-bit Linux
+	if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+		t.Skipf("the test binary is not a 64-bit little-endian ELF file on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable() error = %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var got []elfSection
+	runPreset(t, "elf-sections", f, &got)
+	checkELFSections(t, f, got)
+}
+
+// peSection is a section header as read by the pe-sections preset.
+type peSection struct {
+	Name                 string `json:"name"`
+	VirtualSize          uint32 `json:"vsize"`
+	VirtualAddress       uint32 `json:"vaddr"`
+	Size                 uint32 `json:"size"`
+	Offset               uint32 `json:"offset"`
+	PointerToRelocations uint32 `json:"reloc_at"`
+	PointerToLineNumbers uint32 `json:"line_at"`
+	NumberOfRelocations  uint16 `json:"nreloc"`
+	NumberOfLineNumbers  uint16 `json:"nline"`
+	Characteristics      uint32 `json:"flags"`
+}
+
+func TestPresetPESections(t *testing.T) {
+	// A PE file of an MS-DOS header, the PE header at e_lfanew 0x80, a 64-bit
+	// optional header and the .text and .data sections after it
+	const lfanew = 0x80
+	dos := make([]byte, lfanew)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], lfanew)
+
+	optional := pe.OptionalHeader64{Magic: 0x20b, SectionAlignment: 0x1000, FileAlignment: 0x200, NumberOfRvaAndSizes: 16}
+	header := pe.FileHeader{
+		Machine: pe.IMAGE_FILE_MACHINE_AMD64, NumberOfSections: 2,
+		SizeOfOptionalHeader: uint16(binary.Size(optional)), Characteristics: pe.IMAGE_FILE_EXECUTABLE_IMAGE,
+	}
+	sections := []pe.SectionHeader32{
+		{VirtualSize: 0x10, VirtualAddress: 0x1000, SizeOfRawData: 0x200, PointerToRawData: 0x400, Characteristics: 0x60000020},
+		{VirtualSize: 0x8, VirtualAddress: 0x2000, SizeOfRawData: 0x200, PointerToRawData: 0x600, Characteristics: 0xc0000040},
+	}
+	copy(sections[0].Name[:], ".text")
+	copy(sections[1].Name[:], ".data")
+
+	var buf bytes.Buffer
+	for _, v := range []any{dos, []byte("PE\x00\x00"), header, optional, sections} {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatalf("binary.Write() error = %v", err)
+		}
+	}
+
+	var got []peSection
+	runPreset(t, "pe-sections", bytes.NewReader(buf.Bytes()), &got)
+
+	f, err := pe.NewFile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("pe.NewFile() error = %v", err)
+	}
+	if len(got) != len(f.Sections) {
+		t.Fatalf("pe-sections read %d sections, want %d", len(got), len(f.Sections))
+	}
+	for i, s := range f.Sections {
+		want := peSection{
+			Name: s.Name, VirtualSize: s.VirtualSize, VirtualAddress: s.VirtualAddress, Size: s.Size,
+			Offset: s.Offset, PointerToRelocations: s.PointerToRelocations, PointerToLineNumbers: s.PointerToLineNumbers,
+			NumberOfRelocations: s.NumberOfRelocations, NumberOfLineNumbers: s.NumberOfLineNumbers,
+			Characteristics: s.Characteristics,
+		}
+		if got[i] != want {
+			t.Errorf("section %d = %+v, want %+v", i, got[i], want)
+		}
+	}
+}
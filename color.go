@@ -0,0 +1,69 @@
+package bq
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Color themes of the pretty-print table selectable via Options.ColorTheme.
+const (
+	ColorThemeDark  = "dark"  // bright colors for a dark terminal background
+	ColorThemeLight = "light" // deep colors for a light terminal background
+	ColorThemeMono  = "mono"  // no colors, as when no theme is given
+)
+
+// colorThemes maps each theme to the ANSI SGR parameters of the parts of the
+// table: the header and the cells of each column.
+var colorThemes = map[string]map[string]string{
+	ColorThemeDark: {
+		"header": "1",
+		"name":   "96",
+		"code":   "90",
+		"type":   "93",
+		"value":  "97",
+		"hex":    "92",
+	},
+	ColorThemeLight: {
+		"header": "1",
+		"name":   "34",
+		"code":   "90",
+		"type":   "35",
+		"value":  "30",
+		"hex":    "32",
+	},
+	ColorThemeMono: nil,
+}
+
+// resolveColorTheme returns the colors of the named theme, or nil for no colors:
+// an empty name, the mono theme, or a NO_COLOR environment variable (see
+// https://no-color.org) which overrides the theme.
+func resolveColorTheme(name string) (map[string]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	colors, ok := colorThemes[name]
+	if !ok {
+		names := make([]string, 0, len(colorThemes))
+		for theme := range colorThemes {
+			names = append(names, theme)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown color theme %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return nil, nil
+	}
+	return colors, nil
+}
+
+// paint wraps the text in the color of the part of the table, or returns it as is
+// without a color for it.
+func paint(colors map[string]string, part, text string) string {
+	code, ok := colors[part]
+	if !ok {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
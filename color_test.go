@@ -0,0 +1,72 @@
+package bq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestResolveColorTheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		theme   string
+		noColor string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "no theme", theme: "", wantNil: true},
+		{name: "dark", theme: ColorThemeDark},
+		{name: "light", theme: ColorThemeLight},
+		{name: "mono", theme: ColorThemeMono, wantNil: true},
+		{name: "NO_COLOR overrides the theme", theme: ColorThemeDark, noColor: "1", wantNil: true},
+		{name: "unknown theme", theme: "blue", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			colors, err := resolveColorTheme(tt.theme)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveColorTheme(%q) error = %v, wantErr %v", tt.theme, err, tt.wantErr)
+			}
+			if !tt.wantErr && (colors == nil) != tt.wantNil {
+				t.Errorf("resolveColorTheme(%q) = %v, want nil %v", tt.theme, colors, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestPrettyPrintColorTheme(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+
+	node, err := ParseExpression("<H")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result := []any{uint16(0x0201)}
+
+	var plain, colored bytes.Buffer
+	if err := PrettyPrintResultWithOptions(&plain, node, result, Options{}); err != nil {
+		t.Fatalf("PrettyPrintResultWithOptions() error = %v", err)
+	}
+	if err := PrettyPrintResultWithOptions(&colored, node, result, Options{ColorTheme: ColorThemeDark}); err != nil {
+		t.Fatalf("PrettyPrintResultWithOptions() error = %v", err)
+	}
+
+	got := colored.String()
+	for _, want := range []string{"\x1b[1mName      \x1b[0m", "\x1b[93muint16  \x1b[0m", "\x1b[92m              0x0201\x1b[0m"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("colored table missing %q\nGot:\n%q", want, got)
+		}
+	}
+
+	// Without the escape codes, the columns line up as in the plain table
+	stripped := strings.NewReplacer("\x1b[0m", "", "\x1b[1m", "", "\x1b[96m", "", "\x1b[90m", "", "\x1b[93m", "", "\x1b[97m", "", "\x1b[92m", "").Replace(got)
+	if stripped != plain.String() {
+		t.Errorf("colored table without escapes = %q, want %q", stripped, plain.String())
+	}
+
+	if err := PrettyPrintResultWithOptions(&colored, node, result, Options{ColorTheme: "blue"}); err == nil {
+		t.Error("PrettyPrintResultWithOptions() with an unknown theme succeeded, want an error")
+	}
+}
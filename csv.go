@@ -0,0 +1,130 @@
+package bq
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// ResultToCSV outputs the result as CSV: a header row of column names, then a row
+// per record of a record stream (e.g., repeat(<bH) | {0 -> a, 1 -> b}), or a
+// single row for any other result. Columns are named by the object fields, or by
+// the value indices, with nested objects and arrays flattened by . joiners (e.g.,
+// header.length, data.0). Every record must have the same columns.
+func ResultToCSV(w io.Writer, node Node, result any) error {
+	rows := []any{result}
+	if records, ok := result.([]any); ok && recordNode(node) != nil {
+		rows = records
+	}
+
+	out := newCSVRowWriter(w)
+	for _, row := range rows {
+		if err := out.write(row); err != nil {
+			return err
+		}
+	}
+	return out.flush()
+}
+
+// csvRowWriter writes the rows of the CSV output, the header row of the columns
+// of the first record before it.
+type csvRowWriter struct {
+	out    *csv.Writer
+	header []string // columns of record 0
+	rows   int      // records written so far
+}
+
+// newCSVRowWriter returns a row writer to w.
+func newCSVRowWriter(w io.Writer) *csvRowWriter {
+	return &csvRowWriter{out: csv.NewWriter(w)}
+}
+
+// write writes the row of a record, which must have the columns of record 0.
+func (c *csvRowWriter) write(row any) error {
+	var columns, values []string
+	if err := csvColumns(&columns, &values, "", row); err != nil {
+		return fmt.Errorf("csv: record %d: %w", c.rows, err)
+	}
+
+	if c.rows == 0 {
+		c.header = columns
+		if err := c.out.Write(c.header); err != nil {
+			return err
+		}
+	} else if !slices.Equal(columns, c.header) {
+		return fmt.Errorf("csv: record %d has the columns %v, want %v as in record 0", c.rows, columns, c.header)
+	}
+	c.rows++
+	return c.out.Write(values)
+}
+
+// flush writes the buffered rows to the underlying writer.
+func (c *csvRowWriter) flush() error {
+	c.out.Flush()
+	return c.out.Error()
+}
+
+// csvColumns appends the column names and values of a value, flattening objects,
+// positional values and arrays into a column per element.
+func csvColumns(columns, values *[]string, name string, val any) error {
+	join := func(child string) string {
+		if name == "" {
+			return child
+		}
+		return name + "." + child
+	}
+
+	switch v := val.(type) {
+	case *Object:
+		for _, field := range v.Fields {
+			if err := csvColumns(columns, values, join(field.Name), field.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		for i, elem := range v {
+			child := strconv.Itoa(i)
+			if m, ok := elem.(Mark); ok {
+				child = m.Name
+			}
+			if err := csvColumns(columns, values, join(child), elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case string, nil, Mark:
+	default:
+		if rv := reflect.ValueOf(val); rv.Kind() == reflect.Slice {
+			for i := range rv.Len() {
+				if err := csvColumns(columns, values, join(strconv.Itoa(i)), rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	if name == "" {
+		return fmt.Errorf("cannot render a %T result as CSV columns", val)
+	}
+	*columns = append(*columns, name)
+	*values = append(*values, csvValue(val))
+	return nil
+}
+
+// csvValue renders a single value of a CSV cell. A missing optional field is an
+// empty cell, and floats use their shortest round-trip form.
+func csvValue(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case Mark:
+		return strconv.FormatInt(v.Offset, 10)
+	default:
+		return formatRawValue(v, Options{})
+	}
+}
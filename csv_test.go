@@ -0,0 +1,87 @@
+package bq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestResultToCSV(t *testing.T) {
+	records := []byte{0xFF, 0x01, 0x02, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01}
+
+	tests := []struct {
+		name  string
+		input string
+		data  []byte
+		want  string
+	}{
+		{
+			name:  "records named by an object",
+			input: "repeat(<bH) | {0 -> key, 1 -> value}",
+			data:  records,
+			want:  "key,value\n-1,513\n1,3\n2,256\n",
+		},
+		{
+			name:  "records named by index",
+			input: "repeat(<bH)",
+			data:  records,
+			want:  "0,1\n-1,513\n1,3\n2,256\n",
+		},
+		{
+			name:  "nested objects and arrays flattened",
+			input: "<H2B | {0 -> id, nested: {1 -> data}}",
+			data:  []byte{0x07, 0x00, 0x0A, 0x0B},
+			want:  "id,nested.data.0,nested.data.1\n7,10,11\n",
+		},
+		{
+			name:  "strings quoted",
+			input: "split(0x0A, s | {0 -> text})",
+			data:  []byte("a,b\x00\nsay \"hi\"\x00\nplain\x00"),
+			want:  "text\n\"a,b\"\n\"say \"\"hi\"\"\"\nplain\n",
+		},
+		{
+			name:  "single positional result",
+			input: "<bH",
+			data:  []byte{0xFF, 0x01, 0x02},
+			want:  "0,1\n-1,513\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.input, err)
+			}
+			result, err := node.Eval(newCountingReader(bytes.NewReader(tt.data)), nil)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := ResultToCSV(&buf, node, result); err != nil {
+				t.Fatalf("ResultToCSV() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("ResultToCSV() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultToCSVMismatchedColumns(t *testing.T) {
+	// The records differ in their number of fields
+	node, err := ParseExpression("split(0x0A, *B)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(newCountingReader(bytes.NewReader([]byte("ab\nc"))), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	err = ResultToCSV(&bytes.Buffer{}, node, result)
+	if err == nil || !strings.Contains(err.Error(), "record 1 has the columns") {
+		t.Errorf("ResultToCSV() error = %v, want a column mismatch", err)
+	}
+}
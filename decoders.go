@@ -0,0 +1,1081 @@
+package bq
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxArrayLen caps the elements of an array whose length comes from the input or
+// a count larger than any real table, so its size cannot overflow nor exhaust the
+// memory.
+const maxArrayLen = 1 << 24
+
+// RleNode reads run-length encoded data as (count, value) pairs and expands
+// them into a flat typed slice (e.g., []uint8 for a B value code).
+//
+// With a Total, pairs are read until exactly Total elements are expanded (a run
+// overshooting the total is an error). Without a Total, pairs are read until a
+// zero count (the sentinel) or a clean EOF at a pair boundary.
+type RleNode struct {
+	CountCode FormatCode // integer code for the run length
+	ValueCode FormatCode // fixed-size code for the repeated value
+	Total     int        // expanded length to stop at (0 uses the zero-count sentinel)
+}
+
+// Eval reads and expands the run-length encoded section.
+func (n *RleNode) Eval(r io.Reader, _ []any) (any, error) {
+	var expanded bytes.Buffer
+	countBuf := make([]byte, n.CountCode.Size)
+	valueBuf := make([]byte, n.ValueCode.Size)
+	length := 0
+
+	for n.Total == 0 || length < n.Total {
+		if _, err := io.ReadFull(r, countBuf); err != nil {
+			if err == io.EOF && n.Total == 0 {
+				break
+			}
+			return nil, fmt.Errorf("rle: failed to read run count after %d elements: %w", length, err)
+		}
+		countVal, err := n.CountCode.decode(countBuf)
+		if err != nil {
+			return nil, err
+		}
+		count, err := toInt64(countVal)
+		if err != nil {
+			return nil, fmt.Errorf("rle: run count: %w", err)
+		}
+		if count < 0 {
+			return nil, fmt.Errorf("rle: negative run count %d", count)
+		}
+		if count == 0 && n.Total == 0 {
+			break // sentinel
+		}
+		if n.Total > 0 && count > int64(n.Total-length) {
+			return nil, fmt.Errorf("rle: run of %d overshoots total length %d (have %d)", count, n.Total, length)
+		}
+		if count > int64(maxArrayLen-length) {
+			return nil, fmt.Errorf("rle: run of %d exceeds the limit of %d elements (have %d)", count, maxArrayLen, length)
+		}
+
+		if _, err := io.ReadFull(r, valueBuf); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("rle: failed to read run value: %w", err)
+		}
+		for i := int64(0); i < count; i++ {
+			expanded.Write(valueBuf)
+		}
+		length += int(count)
+	}
+
+	// Decode the expanded raw bytes as a typed slice in one pass
+	arr, err := n.ValueCode.decodeArray(&expanded, length)
+	if err != nil {
+		return nil, err
+	}
+	return []any{arr}, nil
+}
+
+// parseRleFunc parses: 'rle' '(' FormatExpr ',' FormatExpr (',' NUMBER)? ')'
+func (p *Parser) parseRleFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'rle'"); err != nil {
+		return nil, err
+	}
+
+	countCode, err := p.parseSingleFormat("rle count code")
+	if err != nil {
+		return nil, err
+	}
+	if !isIntegerCode(countCode.Code) {
+		return nil, fmt.Errorf("rle count code must be an integer code, got %c", countCode.Code)
+	}
+	if err := p.expect(TokenComma, "',' after rle count code"); err != nil {
+		return nil, err
+	}
+
+	valueCode, err := p.parseSingleFormat("rle value code")
+	if err != nil {
+		return nil, err
+	}
+	if valueCode.Size == 0 {
+		return nil, fmt.Errorf("rle value code must have a fixed size, got %c", valueCode.Code)
+	}
+
+	node := &RleNode{CountCode: countCode, ValueCode: valueCode}
+	if p.current.Type == TokenComma {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if node.Total, err = p.parseInt("rle total length"); err != nil {
+			return nil, err
+		}
+		if node.Total < 1 || node.Total > maxArrayLen {
+			return nil, fmt.Errorf("rle total length must be between 1 and %d, got %d", maxArrayLen, node.Total)
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after rle arguments"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// isIntegerCode returns true if the format code reads a fixed-size integer.
+func isIntegerCode(code rune) bool {
+	switch code {
+	case 'b', 'B', 'h', 'H', 'i', 'I', 'q', 'Q':
+		return true
+	default:
+		return false
+	}
+}
+
+// SetBitsNode expands an integer value into the ascending positions of its set
+// bits, numbered from the least significant bit (LSB = 0). Signed values use
+// their two's complement bits at the value's width.
+type SetBitsNode struct {
+	Index int // index of the integer value to expand
+}
+
+// Eval returns the set bit positions of the indexed value as a []uint8.
+func (n *SetBitsNode) Eval(_ io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("setbits: index %d out of range (have %d values)", n.Index, len(values))
+	}
+
+	bits, width, err := integerBits(values[n.Index])
+	if err != nil {
+		return nil, fmt.Errorf("setbits: %w", err)
+	}
+
+	positions := make([]uint8, 0)
+	for i := 0; i < width; i++ {
+		if bits&(1<<i) != 0 {
+			positions = append(positions, uint8(i))
+		}
+	}
+	return []any{positions}, nil
+}
+
+// integerBits returns the raw bits of an integer value and its width in bits.
+func integerBits(val any) (uint64, int, error) {
+	switch v := val.(type) {
+	case int8:
+		return uint64(uint8(v)), 8, nil
+	case uint8:
+		return uint64(v), 8, nil
+	case int16:
+		return uint64(uint16(v)), 16, nil
+	case uint16:
+		return uint64(v), 16, nil
+	case int32:
+		return uint64(uint32(v)), 32, nil
+	case uint32:
+		return uint64(v), 32, nil
+	case int64:
+		return uint64(v), 64, nil
+	case uint64:
+		return v, 64, nil
+	default:
+		return 0, 0, fmt.Errorf("expected an integer value, got %T", val)
+	}
+}
+
+// parseSetBitsFunc parses: 'setbits' '(' NUMBER ')'
+func (p *Parser) parseSetBitsFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'setbits'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("setbits value index")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after setbits index"); err != nil {
+		return nil, err
+	}
+	return &SetBitsNode{Index: idx}, nil
+}
+
+// FlagSetNode decomposes an integer of OR-ed bit flags into the names of the set
+// flags, e.g. flagset(0, {1: READ, 2: WRITE, 4: EXEC}) names 5 as [READ EXEC].
+type FlagSetNode struct {
+	Index int    // index of the integer value
+	Flags []Flag // named flags, in ascending order of their mask
+}
+
+// Flag names the bits of a mask within a FlagSetNode.
+type Flag struct {
+	Mask uint64 // bits of the flag, all set for the flag to match
+	Name string // flag name
+}
+
+// Eval replaces the indexed value with a []string of the names of its set flags,
+// in ascending order of their masks, leaving the other values in place. Set bits
+// no flag names are appended in hex (e.g., 0x8), so no bit goes unreported.
+func (n *FlagSetNode) Eval(_ io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("flagset: index %d out of range (have %d values)", n.Index, len(values))
+	}
+	bits, _, err := integerBits(values[n.Index])
+	if err != nil {
+		return nil, fmt.Errorf("flagset: %w", err)
+	}
+
+	names := make([]string, 0)
+	unknown := bits
+	for _, flag := range n.Flags {
+		if bits&flag.Mask == flag.Mask {
+			names = append(names, flag.Name)
+			unknown &^= flag.Mask
+		}
+	}
+	if unknown != 0 {
+		names = append(names, fmt.Sprintf("%#x", unknown))
+	}
+
+	out := append([]any{}, values...)
+	out[n.Index] = names
+	return out, nil
+}
+
+// parseFlagSetFunc parses: 'flagset' '(' NUMBER ',' '{' NUMBER ':' IDENTIFIER
+// (',' NUMBER ':' IDENTIFIER)* '}' ')'
+func (p *Parser) parseFlagSetFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'flagset'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("flagset value index")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after flagset index"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLBrace, "'{' to start the flags"); err != nil {
+		return nil, err
+	}
+
+	node := &FlagSetNode{Index: idx}
+	seen := make(map[string]bool)
+	for {
+		pos := p.current.Pos
+		if p.current.Type != TokenNumber {
+			return nil, fmt.Errorf("expected a flag mask at position %d, got %q", pos, p.current.Value)
+		}
+		digits, base := splitNumberBase(p.current.Value)
+		mask, err := strconv.ParseUint(digits, base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flag mask %q: %w", p.current.Value, err)
+		}
+		if mask == 0 {
+			return nil, fmt.Errorf("flag mask at position %d must not be zero", pos)
+		}
+		if slices.ContainsFunc(node.Flags, func(f Flag) bool { return f.Mask == mask }) {
+			return nil, fmt.Errorf("duplicate flag mask %#x at position %d", mask, pos)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(TokenColon, "':' after the flag mask"); err != nil {
+			return nil, err
+		}
+
+		p.rescanName()
+		if p.current.Type != TokenIdent {
+			return nil, fmt.Errorf("expected a flag name at position %d, got %q", p.current.Pos, p.current.Value)
+		}
+		if seen[p.current.Value] {
+			return nil, fmt.Errorf("duplicate flag name %q at position %d", p.current.Value, p.current.Pos)
+		}
+		seen[p.current.Value] = true
+		node.Flags = append(node.Flags, Flag{Mask: mask, Name: p.current.Value})
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		if p.current.Type != TokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRBrace, "'}' after the flags"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenRParen, "')' after flagset flags"); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(node.Flags, func(a, b Flag) int { return cmp.Compare(a.Mask, b.Mask) })
+	return node, nil
+}
+
+// ByteSwapNode reverses the byte order of the value at an index, each element on
+// its own for an array, as a quick fix for a value read with the wrong byte order.
+// Single-byte values are unchanged.
+type ByteSwapNode struct {
+	Index int // index of the integer value to swap
+}
+
+// Eval returns the input values with the indexed value byte-swapped, leaving the
+// other values in place.
+func (n *ByteSwapNode) Eval(_ io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("byteswap: index %d out of range (have %d values)", n.Index, len(values))
+	}
+
+	swapped, err := byteSwap(values[n.Index])
+	if err != nil {
+		return nil, fmt.Errorf("byteswap: %w", err)
+	}
+	out := append([]any{}, values...)
+	out[n.Index] = swapped
+	return out, nil
+}
+
+// byteSwap reverses the bytes of an integer, or of each element of an integer
+// array, returning a new array rather than changing it in place.
+func byteSwap(val any) (any, error) {
+	swap16 := func(v uint16) uint16 { return bits.ReverseBytes16(v) }
+	swap32 := func(v uint32) uint32 { return bits.ReverseBytes32(v) }
+	swap64 := func(v uint64) uint64 { return bits.ReverseBytes64(v) }
+	swapI16 := func(v int16) int16 { return int16(swap16(uint16(v))) }
+	swapI32 := func(v int32) int32 { return int32(swap32(uint32(v))) }
+	swapI64 := func(v int64) int64 { return int64(swap64(uint64(v))) }
+
+	switch v := val.(type) {
+	case int8, uint8, []int8, []uint8:
+		return v, nil
+	case uint16:
+		return swap16(v), nil
+	case int16:
+		return swapI16(v), nil
+	case uint32:
+		return swap32(v), nil
+	case int32:
+		return swapI32(v), nil
+	case uint64:
+		return swap64(v), nil
+	case int64:
+		return swapI64(v), nil
+	case []uint16:
+		return mapSlice(v, swap16), nil
+	case []int16:
+		return mapSlice(v, swapI16), nil
+	case []uint32:
+		return mapSlice(v, swap32), nil
+	case []int32:
+		return mapSlice(v, swapI32), nil
+	case []uint64:
+		return mapSlice(v, swap64), nil
+	case []int64:
+		return mapSlice(v, swapI64), nil
+	default:
+		return nil, fmt.Errorf("expected an integer value, got %T", val)
+	}
+}
+
+// mapSlice returns a new slice holding fn applied to each element of s.
+func mapSlice[T any](s []T, fn func(T) T) []T {
+	out := make([]T, len(s))
+	for i, v := range s {
+		out[i] = fn(v)
+	}
+	return out
+}
+
+// parseByteSwapFunc parses: 'byteswap' '(' NUMBER ')'
+func (p *Parser) parseByteSwapFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'byteswap'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("byteswap value index")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after byteswap index"); err != nil {
+		return nil, err
+	}
+	return &ByteSwapNode{Index: idx}, nil
+}
+
+// SampleNode interprets an integer PCM sample as a float64 normalized to [-1, 1)
+// for its bit depth (e.g., an int16 divided by 32768). Signed values are two's
+// complement samples, while unsigned values are offset binary, as in 8-bit WAV.
+type SampleNode struct {
+	Index int // index of the integer sample value
+	Bits  int // bit depth: 8, 16, 24 or 32
+}
+
+// Eval returns the normalized sample of the indexed value as a float64.
+func (n *SampleNode) Eval(_ io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("sample: index %d out of range (have %d values)", n.Index, len(values))
+	}
+	val := values[n.Index]
+	v, err := toInt64(val)
+	if err != nil {
+		return nil, fmt.Errorf("sample: %w", err)
+	}
+
+	half := int64(1) << (n.Bits - 1)
+	switch val.(type) {
+	case int8, int16, int32, int64:
+		if v < -half || v >= half {
+			return nil, fmt.Errorf("sample: value %d out of range for a signed %d-bit sample", v, n.Bits)
+		}
+	default:
+		if v >= 2*half {
+			return nil, fmt.Errorf("sample: value %d out of range for an unsigned %d-bit sample", v, n.Bits)
+		}
+		v -= half
+	}
+	return []any{float64(v) / float64(half)}, nil
+}
+
+// parseSampleFunc parses: 'sample' '(' NUMBER ',' NUMBER ')'
+func (p *Parser) parseSampleFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'sample'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("sample value index")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after sample index"); err != nil {
+		return nil, err
+	}
+
+	pos := p.current.Pos
+	bits, err := p.parseInt("sample bit depth")
+	if err != nil {
+		return nil, err
+	}
+	switch bits {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("sample bit depth at position %d must be 8, 16, 24 or 32, got %d", pos, bits)
+	}
+
+	if err := p.expect(TokenRParen, "')' after sample bit depth"); err != nil {
+		return nil, err
+	}
+	return &SampleNode{Index: idx, Bits: bits}, nil
+}
+
+// durationUnits maps the units accepted by duration() to their length.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// DurationNode renders an integer count of a time unit (e.g., a timeout in
+// milliseconds) as a human-readable duration string such as "1m23s".
+type DurationNode struct {
+	Index int           // index of the integer value
+	Unit  time.Duration // length of one unit of the value
+	Keep  bool          // keep the raw value after the duration string
+}
+
+// Eval returns the duration string of the indexed value, followed by the value
+// itself when Keep is set.
+func (n *DurationNode) Eval(_ io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("duration: index %d out of range (have %d values)", n.Index, len(values))
+	}
+	val := values[n.Index]
+	v, err := toInt64(val)
+	if err != nil {
+		return nil, fmt.Errorf("duration: %w", err)
+	}
+
+	limit := int64(math.MaxInt64 / n.Unit)
+	if v > limit || v < -limit {
+		return nil, fmt.Errorf("duration: value %d overflows a duration in units of %v", v, n.Unit)
+	}
+	str := (time.Duration(v) * n.Unit).String()
+	if n.Keep {
+		return []any{str, val}, nil
+	}
+	return []any{str}, nil
+}
+
+// parseDurationFunc parses: 'duration' '(' NUMBER ',' IDENTIFIER (',' 'keep')? ')'
+func (p *Parser) parseDurationFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'duration'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("duration value index")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after duration index"); err != nil {
+		return nil, err
+	}
+
+	p.rescanName()
+	unit, ok := durationUnits[p.current.Value]
+	if p.current.Type != TokenIdent || !ok {
+		return nil, fmt.Errorf("duration unit at position %d must be ns, us, ms or s, got %q", p.current.Pos, p.current.Value)
+	}
+	node := &DurationNode{Index: idx, Unit: unit}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.current.Type == TokenComma {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.current.Type != TokenIdent || p.current.Value != "keep" {
+			return nil, fmt.Errorf("expected 'keep' at position %d, got %q", p.current.Pos, p.current.Value)
+		}
+		node.Keep = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after duration arguments"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// StringArrayNode reads null-terminated strings, as many as given by a count
+// read earlier (e.g., the count field of a string table).
+type StringArrayNode struct {
+	Index        int // index of the integer value holding the count
+	MaxStringLen int // cap on the bytes read per string (0 uses DefaultMaxStringLen)
+}
+
+// Eval reads the strings and appends them as a []string to the input values, so
+// existing indices are unchanged and the strings take the next index.
+func (n *StringArrayNode) Eval(r io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("string_array: index %d out of range (have %d values)", n.Index, len(values))
+	}
+	count, err := toInt64(values[n.Index])
+	if err != nil {
+		return nil, fmt.Errorf("string_array: count: %w", err)
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("string_array: negative count %d", count)
+	}
+
+	limit := n.MaxStringLen
+	if limit <= 0 {
+		limit = DefaultMaxStringLen
+	}
+
+	// The count comes from the input, so the slice grows as strings are read
+	strs := make([]string, 0)
+	for i := int64(0); i < count; i++ {
+		// Every string needs its terminator, the last one included
+		str, err := readCString(r, limit, true)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("string_array: string %d of %d: %w", i, count, err)
+		}
+		strs = append(strs, str)
+	}
+
+	result := make([]any, len(values), len(values)+1)
+	copy(result, values)
+	return append(result, strs), nil
+}
+
+// parseStringArrayFunc parses: 'string_array' '(' (NUMBER | '[' NUMBER ']') ')'
+func (p *Parser) parseStringArrayFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'string_array'"); err != nil {
+		return nil, err
+	}
+
+	// The index may be bracketed, as in string_array([0])
+	bracketed := p.current.Type == TokenLBracket
+	if bracketed {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	idx, err := p.parseInt("string_array count index")
+	if err != nil {
+		return nil, err
+	}
+	if bracketed {
+		if err := p.expect(TokenRBracket, "']' after string_array index"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after string_array index"); err != nil {
+		return nil, err
+	}
+	return &StringArrayNode{Index: idx, MaxStringLen: p.opts.MaxStringLen}, nil
+}
+
+// Protobuf wire types.
+const (
+	pbWireVarint  = 0 // int32, int64, uint32, uint64, sint32, sint64, bool, enum
+	pbWireFixed64 = 1 // fixed64, sfixed64, double
+	pbWireBytes   = 2 // string, bytes, embedded messages, packed repeated fields
+	pbWireFixed32 = 5 // fixed32, sfixed32, float
+)
+
+// PbNode reads a single Protobuf-style field: a varint tag (the field number and
+// wire type) followed by a value whose encoding depends on the wire type.
+type PbNode struct{}
+
+// Eval reads the field and returns it as an object {field, wiretype, value}, where
+// the value is a uint64 for varint and fixed64 fields, a uint32 for fixed32 fields,
+// and a []uint8 for length-delimited fields.
+func (n *PbNode) Eval(r io.Reader, _ []any) (any, error) {
+	tag, err := readUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("pb: failed to read tag: %w", err)
+	}
+	field, wireType := tag>>3, uint8(tag&0x07)
+
+	var value any
+	switch wireType {
+	case pbWireVarint:
+		value, err = readUvarint(r)
+	case pbWireFixed64:
+		fc := FormatCode{Code: 'Q', Size: 8, Order: LittleEndian}
+		value, err = readFixed(r, fc)
+	case pbWireFixed32:
+		fc := FormatCode{Code: 'I', Size: 4, Order: LittleEndian}
+		value, err = readFixed(r, fc)
+	case pbWireBytes:
+		var length uint64
+		if length, err = readUvarint(r); err != nil {
+			break
+		}
+		// Read through a limit rather than allocating an untrusted length upfront
+		var blob []uint8
+		if blob, err = io.ReadAll(io.LimitReader(r, int64(length))); err == nil && uint64(len(blob)) < length {
+			err = io.ErrUnexpectedEOF
+		}
+		value = blob
+	default:
+		return nil, fmt.Errorf("pb: unsupported wire type %d for field %d", wireType, field)
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("pb: failed to read field %d value: %w", field, err)
+	}
+
+	return &Object{Fields: []ObjectField{
+		{Name: "field", Value: field},
+		{Name: "wiretype", Value: wireType},
+		{Name: "value", Value: value},
+	}}, nil
+}
+
+// readFixed reads and decodes a single fixed-size value.
+func readFixed(r io.Reader, fc FormatCode) (any, error) {
+	buf := make([]byte, fc.Size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return fc.decode(buf)
+}
+
+// readUvarint reads a base-128 varint (least significant group first), one byte
+// at a time so no bytes past the varint are consumed.
+func readUvarint(r io.Reader) (uint64, error) {
+	var value uint64
+	b := make([]byte, 1)
+	for i := 0; ; i++ {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if i > 0 && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if i == 9 && b[0] > 1 {
+			return 0, fmt.Errorf("varint overflows uint64")
+		}
+		value |= uint64(b[0]&0x7f) << (7 * i)
+		if b[0] < 0x80 {
+			return value, nil
+		}
+	}
+}
+
+// maxVlqBytes is the longest MIDI variable-length quantity, holding 28 bits.
+const maxVlqBytes = 4
+
+// VlqNode reads a MIDI variable-length quantity: 7-bit groups, most significant
+// group first, with the high bit set on every byte but the last. Unlike a
+// varint, the first byte read holds the highest bits.
+type VlqNode struct{}
+
+// Eval reads the quantity and returns it as a uint32.
+func (n *VlqNode) Eval(r io.Reader, _ []any) (any, error) {
+	var value uint32
+	b := make([]byte, 1)
+	for i := range maxVlqBytes {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if i > 0 && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("vlq: %w", err)
+		}
+		value = value<<7 | uint32(b[0]&0x7f)
+		if b[0] < 0x80 {
+			return []any{value}, nil
+		}
+	}
+	return nil, fmt.Errorf("vlq: longer than %d bytes", maxVlqBytes)
+}
+
+// AppendVlq appends the minimal MIDI variable-length quantity encoding of v to
+// dst. Values over 0x0FFFFFFF do not fit in the 4 bytes MIDI allows and return
+// an error.
+func AppendVlq(dst []byte, v uint32) ([]byte, error) {
+	if v >= 1<<(7*maxVlqBytes) {
+		return dst, fmt.Errorf("vlq: value %#x exceeds %#x", v, uint32(1<<(7*maxVlqBytes)-1))
+	}
+
+	// Fill the groups from the end, marking all but the last byte
+	var buf [maxVlqBytes]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7f) | 0x80
+	}
+	return append(dst, buf[i:]...), nil
+}
+
+// maxAtoiDigits caps the digits atoi() reads, the length of the largest int64.
+const maxAtoiDigits = 19
+
+// AtoiNode reads an ASCII decimal integer with an optional leading sign up to a
+// terminator byte, for formats embedding text numbers between binary fields.
+type AtoiNode struct {
+	Term byte // terminator byte, consumed but not part of the number
+}
+
+// Eval reads the digits and the terminator and returns the number as an int64.
+// No digits, a byte other than a digit before the terminator, or a number out of
+// the int64 range is an error.
+func (n *AtoiNode) Eval(r io.Reader, _ []any) (any, error) {
+	var digits []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if len(digits) > 0 && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("atoi: missing terminator %q: %w", n.Term, err)
+		}
+		if b[0] == n.Term {
+			break
+		}
+
+		switch c := b[0]; {
+		case (c == '-' || c == '+') && len(digits) == 0:
+		case c >= '0' && c <= '9':
+		default:
+			return nil, fmt.Errorf("atoi: invalid character %q after %q", c, digits)
+		}
+		digits = append(digits, b[0])
+		if len(digits) > maxAtoiDigits+1 {
+			return nil, fmt.Errorf("atoi: more than %d digits", maxAtoiDigits)
+		}
+	}
+
+	if len(digits) == 0 || digits[len(digits)-1] < '0' || digits[len(digits)-1] > '9' {
+		return nil, fmt.Errorf("atoi: no digits before terminator %q", n.Term)
+	}
+	v, err := strconv.ParseInt(string(digits), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("atoi: %q out of the int64 range", digits)
+	}
+	return []any{v}, nil
+}
+
+// parseAtoiFunc parses: 'atoi' '(' STRING ')' where STRING is the terminator byte.
+func (p *Parser) parseAtoiFunc() (Node, error) {
+	term, err := p.parseStringArgFunc("atoi terminator")
+	if err != nil {
+		return nil, err
+	}
+	if len(term) != 1 || term[0] == '-' || term[0] == '+' || (term[0] >= '0' && term[0] <= '9') {
+		return nil, fmt.Errorf("atoi terminator must be a single byte other than a digit or sign, got %q", term)
+	}
+	return &AtoiNode{Term: term[0]}, nil
+}
+
+// Utf8LenNode reads a count-prefixed UTF-8 string, as in Protobuf and MessagePack:
+// a length read with an integer code (or a varint) followed by that many bytes,
+// without a null terminator.
+type Utf8LenNode struct {
+	LengthCode   FormatCode // integer code for the length (unused for a varint)
+	Varint       bool       // read the length as a base-128 varint
+	Lenient      bool       // replace invalid UTF-8 with U+FFFD instead of erroring
+	MaxStringLen int        // cap on the string length (0 uses DefaultMaxStringLen)
+}
+
+// Eval reads the length and the string bytes and returns the string.
+func (n *Utf8LenNode) Eval(r io.Reader, _ []any) (any, error) {
+	var length uint64
+	if n.Varint {
+		v, err := readUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("utf8len: failed to read length: %w", err)
+		}
+		length = v
+	} else {
+		v, err := readFixed(r, n.LengthCode)
+		if err != nil {
+			return nil, fmt.Errorf("utf8len: failed to read length: %w", err)
+		}
+		signed, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("utf8len: length: %w", err)
+		}
+		if signed < 0 {
+			return nil, fmt.Errorf("utf8len: negative length %d", signed)
+		}
+		length = uint64(signed)
+	}
+
+	limit := n.MaxStringLen
+	if limit <= 0 {
+		limit = DefaultMaxStringLen
+	}
+	if length > uint64(limit) {
+		return nil, fmt.Errorf("utf8len: length %d exceeds limit of %d bytes", length, limit)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("utf8len: failed to read %d string bytes: %w", length, err)
+	}
+	if !utf8.Valid(buf) {
+		if !n.Lenient {
+			return nil, fmt.Errorf("utf8len: invalid UTF-8 in %d string bytes", length)
+		}
+		return []any{strings.ToValidUTF8(string(buf), "\uFFFD")}, nil
+	}
+	return []any{string(buf)}, nil
+}
+
+// parseUtf8LenFunc parses: 'utf8len' '(' (FormatExpr | 'varint') (',' 'lenient')? ')'
+func (p *Parser) parseUtf8LenFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'utf8len'"); err != nil {
+		return nil, err
+	}
+
+	node := &Utf8LenNode{MaxStringLen: p.opts.MaxStringLen}
+	if p.current.Type == TokenIdent && p.current.Value == "varint" {
+		node.Varint = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	} else {
+		lengthCode, err := p.parseSingleFormat("utf8len length code")
+		if err != nil {
+			return nil, err
+		}
+		if !isIntegerCode(lengthCode.Code) {
+			return nil, fmt.Errorf("utf8len length code must be an integer code or varint, got %c", lengthCode.Code)
+		}
+		node.LengthCode = lengthCode
+	}
+
+	if p.current.Type == TokenComma {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		p.rescanName()
+		if p.current.Type != TokenIdent || p.current.Value != "lenient" {
+			return nil, fmt.Errorf("expected 'lenient' at position %d, got %q", p.current.Pos, p.current.Value)
+		}
+		node.Lenient = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after utf8len arguments"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Utf16Node reads a UTF-16 string of exactly Units 16-bit code units (not
+// null-terminated), as in Windows string tables with known lengths. With BOM set,
+// a leading byte order mark picks the byte order and is stripped from the string.
+type Utf16Node struct {
+	Units int       // number of code units, including a byte order mark
+	Order ByteOrder // byte order of the code units, unless a BOM says otherwise
+	BOM   bool      // detect the byte order from a leading 0xFEFF or 0xFFFE
+}
+
+// Eval reads and decodes the code units, returning the string. Unpaired
+// surrogates decode as U+FFFD.
+func (n *Utf16Node) Eval(r io.Reader, _ []any) (any, error) {
+	buf := make([]byte, 2*n.Units)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("utf16: failed to read %d code units: %w", n.Units, err)
+	}
+
+	order := toBinaryOrder(n.Order)
+	units := make([]uint16, n.Units)
+	for i := range units {
+		units[i] = order.Uint16(buf[2*i:])
+	}
+
+	if n.BOM && len(units) > 0 {
+		switch units[0] {
+		case 0xFEFF:
+			units = units[1:]
+		case 0xFFFE:
+			// A swapped mark means the other byte order
+			units = units[1:]
+			for i, u := range units {
+				units[i] = u<<8 | u>>8
+			}
+		}
+	}
+	return []any{string(utf16.Decode(units))}, nil
+}
+
+// parseUtf16Func parses: 'utf16' '(' ByteOrder? NUMBER (',' 'bom')? ')'
+func (p *Parser) parseUtf16Func() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'utf16'"); err != nil {
+		return nil, err
+	}
+
+	node := &Utf16Node{Order: NativeOrder}
+	if p.current.Type == TokenOrder {
+		node.Order = parseByteOrder(p.current.Value)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	pos := p.current.Pos
+	units, err := p.parseInt("utf16 code unit count")
+	if err != nil {
+		return nil, err
+	}
+	if units < 1 {
+		return nil, fmt.Errorf("utf16 code unit count at position %d must be at least 1, got %d", pos, units)
+	}
+	limit := p.maxStringLen()
+	if units > limit/2 {
+		return nil, fmt.Errorf("utf16 code unit count at position %d exceeds limit of %d bytes, got %d", pos, limit, units)
+	}
+	node.Units = units
+
+	if p.current.Type == TokenComma {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		p.rescanName()
+		if p.current.Type != TokenIdent || p.current.Value != "bom" {
+			return nil, fmt.Errorf("expected 'bom' at position %d, got %q", p.current.Pos, p.current.Value)
+		}
+		node.BOM = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after utf16 arguments"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseVlqFunc parses: 'vlq' '(' ')'
+func (p *Parser) parseVlqFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'vlq'"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenRParen, "')' after 'vlq('"); err != nil {
+		return nil, err
+	}
+	return &VlqNode{}, nil
+}
+
+// parsePbFunc parses: 'pb' '(' ')'
+func (p *Parser) parsePbFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'pb'"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenRParen, "')' after 'pb('"); err != nil {
+		return nil, err
+	}
+	return &PbNode{}, nil
+}
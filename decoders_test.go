@@ -0,0 +1,736 @@
+package bq
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRleNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    any
+		wantErr bool
+	}{
+		{
+			name:  "sentinel terminated",
+			input: "rle(B, B)",
+			data:  []byte{3, 0xAA, 2, 0xBB, 0, 0xFF},
+			want:  []uint8{0xAA, 0xAA, 0xAA, 0xBB, 0xBB},
+		},
+		{
+			name:  "clean EOF at pair boundary",
+			input: "rle(B, B)",
+			data:  []byte{1, 0x01, 2, 0x02},
+			want:  []uint8{0x01, 0x02, 0x02},
+		},
+		{
+			name:  "fixed total with wider codes",
+			input: "rle(<H, <H, 4)",
+			data:  []byte{0x03, 0x00, 0x34, 0x12, 0x01, 0x00, 0x01, 0x00, 0xFF},
+			want:  []uint16{0x1234, 0x1234, 0x1234, 0x0001},
+		},
+		{
+			name:  "empty input with sentinel mode",
+			input: "rle(B, b)",
+			data:  []byte{},
+			want:  []int8{},
+		},
+		{
+			name:    "run overshoots total",
+			input:   "rle(B, B, 2)",
+			data:    []byte{3, 0xAA},
+			wantErr: true,
+		},
+		{
+			name:    "huge run after a run overshoots total",
+			input:   "rle(<Q, B, 4)",
+			data:    []byte{1, 0, 0, 0, 0, 0, 0, 0, 0xAA, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F, 0xBB},
+			wantErr: true,
+		},
+		{
+			name:    "run exceeds the limit",
+			input:   "rle(<Q, B)",
+			data:    []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F, 0xBB},
+			wantErr: true,
+		},
+		{
+			name:    "EOF before total",
+			input:   "rle(B, B, 4)",
+			data:    []byte{2, 0xAA},
+			wantErr: true,
+		},
+		{
+			name:    "missing value after count",
+			input:   "rle(B, B)",
+			data:    []byte{2},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			values, ok := result.([]any)
+			if !ok || len(values) != 1 {
+				t.Fatalf("Eval() = %v, want a single expanded slice", result)
+			}
+			if !compareValues(values[0], tt.want) {
+				t.Errorf("Eval() = %v, want %v", values[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestRleParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"rle(B)",
+		"rle(s, B)",
+		"rle(B, s)",
+		"rle(2B, B)",
+		"rle(B, B, 0)",
+		"rle(B, B, 16777217)",
+		"rle(B, B",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestSetBitsNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []uint8
+		wantErr bool
+	}{
+		{"sparse flags", "<I | setbits(0)", []byte{0x89, 0x00, 0x00, 0x00}, []uint8{0, 3, 7}, false},
+		{"no bits set", "<H | setbits(0)", []byte{0x00, 0x00}, []uint8{}, false},
+		{"highest bit", ">Q | setbits(0)", []byte{0x80, 0, 0, 0, 0, 0, 0, 0x01}, []uint8{0, 63}, false},
+		{"signed uses two's complement", "<bh | setbits(0)", []byte{0xFF, 0x00, 0x00}, []uint8{0, 1, 2, 3, 4, 5, 6, 7}, false},
+		{"selected index", "<BH | setbits(1)", []byte{0xFF, 0x00, 0x01}, []uint8{8}, false},
+		{"index out of range", "<B | setbits(1)", []byte{0x01}, nil, true},
+		{"non-integer value", "s | setbits(0)", []byte{'a', 0}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			values := result.([]any)
+			if len(values) != 1 || !bytes.Equal(values[0].([]uint8), tt.want) {
+				t.Errorf("Eval() = %v, want [%v]", values, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"<B | setbits()", "<B | setbits(0, 1)", "setbits(0)"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestPbNodeEval(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		field    uint64
+		wireType uint8
+		value    any
+		wantErr  bool
+	}{
+		{"varint", []byte{0x08, 0x96, 0x01}, 1, 0, uint64(150), false},
+		{"fixed64", []byte{0x11, 0x01, 0, 0, 0, 0, 0, 0, 0x80}, 2, 1, uint64(0x8000000000000001), false},
+		{"length-delimited", []byte{0x1a, 0x02, 'h', 'i'}, 3, 2, []uint8("hi"), false},
+		{"fixed32", []byte{0x25, 0x01, 0x02, 0x00, 0x00}, 4, 5, uint32(0x0201), false},
+		{"multi-byte tag", []byte{0x80, 0x01, 0x00}, 16, 0, uint64(0), false},
+		{"unsupported group", []byte{0x0b}, 0, 0, nil, true},
+		{"truncated varint", []byte{0x08, 0x96}, 0, 0, nil, true},
+		{"truncated blob", []byte{0x1a, 0x05, 'h', 'i'}, 0, 0, nil, true},
+		{"empty input", []byte{}, 0, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes("pb()", tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			obj := result.(*Object)
+			if obj.Fields[0].Value != tt.field || obj.Fields[1].Value != tt.wireType {
+				t.Errorf("Eval() field = %v, wiretype = %v, want %d and %d",
+					obj.Fields[0].Value, obj.Fields[1].Value, tt.field, tt.wireType)
+			}
+			if blob, ok := tt.value.([]uint8); ok {
+				if !bytes.Equal(obj.Fields[2].Value.([]uint8), blob) {
+					t.Errorf("Eval() value = %v, want %v", obj.Fields[2].Value, blob)
+				}
+			} else if obj.Fields[2].Value != tt.value {
+				t.Errorf("Eval() value = %v (%T), want %v (%T)", obj.Fields[2].Value, obj.Fields[2].Value, tt.value, tt.value)
+			}
+		})
+	}
+
+	// Only the field itself is consumed
+	node, _ := ParseExpression("pb()")
+	r := bytes.NewReader([]byte{0x08, 0x01, 0xff})
+	if _, err := node.Eval(r, nil); err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if r.Len() != 1 {
+		t.Errorf("Eval() left %d bytes, want 1", r.Len())
+	}
+
+	// A length-delimited value can be parsed further
+	result, err := EvalBytes("pb() | reparse(<H)", []byte{0x12, 0x02, 0x01, 0x02})
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	if values := result.([]any); len(values) != 1 || values[0] != uint16(0x0201) {
+		t.Errorf("EvalBytes() = %v, want [513]", values)
+	}
+}
+
+func TestReadUvarintOverflow(t *testing.T) {
+	data := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}
+	if _, err := readUvarint(bytes.NewReader(data)); err == nil {
+		t.Error("readUvarint() expected overflow error, got nil")
+	}
+
+	data[9] = 0x01
+	if got, err := readUvarint(bytes.NewReader(data)); err != nil || got != ^uint64(0) {
+		t.Errorf("readUvarint() = %d, %v, want max uint64", got, err)
+	}
+}
+
+func TestUtf8LenNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"varint length", "utf8len(varint)", []byte("\x05hello"), "hello", false},
+		{"multi-byte varint length", "utf8len(varint)", append([]byte{0x80, 0x01}, bytes.Repeat([]byte("a"), 128)...), strings.Repeat("a", 128), false},
+		{"big-endian length", "utf8len(>H)", []byte("\x00\x03\xe6\x97\xa5"), "日", false},
+		{"no null terminator", "utf8len(B)", []byte("\x02a\x00"), "a\x00", false},
+		{"empty string", "utf8len(<I)", []byte{0, 0, 0, 0}, "", false},
+		{"lenient", "utf8len(B, lenient)", []byte("\x02\xffA"), "\uFFFDA", false},
+		{"invalid UTF-8", "utf8len(B)", []byte("\x02\xffA"), "", true},
+		{"negative length", "utf8len(b)", []byte("\xffa"), "", true},
+		{"truncated string", "utf8len(varint)", []byte("\x05hi"), "", true},
+		{"missing length", "utf8len(<H)", []byte{0x01}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if values := result.([]any); len(values) != 1 || values[0] != tt.want {
+				t.Errorf("EvalBytes() = %q, want [%q]", values, tt.want)
+			}
+		})
+	}
+
+	// Lengths are capped like null-terminated strings
+	node, err := ParseExpressionWithOptions("utf8len(B)", Options{MaxStringLen: 2})
+	if err != nil {
+		t.Fatalf("ParseExpressionWithOptions() error = %v", err)
+	}
+	if _, err := node.Eval(bytes.NewReader([]byte("\x03abc")), nil); err == nil {
+		t.Error("Eval() expected error for a length over the limit, got nil")
+	}
+}
+
+func TestUtf8LenParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"utf8len()",
+		"utf8len(s)",
+		"utf8len(2B)",
+		"utf8len(B, strict)",
+		"utf8len(varint",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestUtf16NodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"little-endian", "utf16(<2)", []byte("h\x00i\x00"), "hi", false},
+		{"big-endian", "utf16(>2)", []byte("\x00h\x00i"), "hi", false},
+		{"surrogate pair", "utf16(<2)", []byte{0x3d, 0xd8, 0x00, 0xde}, "\U0001F600", false},
+		{"unpaired surrogate", "utf16(<1)", []byte{0x3d, 0xd8}, "\uFFFD", false},
+		{"no null terminator", "utf16(<2)", []byte("a\x00\x00\x00"), "a\x00", false},
+		{"matching BOM", "utf16(<3, bom)", []byte("\xff\xfeh\x00i\x00"), "hi", false},
+		{"swapped BOM", "utf16(<3, bom)", []byte("\xfe\xff\x00h\x00i"), "hi", false},
+		{"BOM detected from big-endian", "utf16(>2, bom)", []byte("\xff\xfeh\x00"), "h", false},
+		{"no BOM present", "utf16(<1, bom)", []byte("h\x00"), "h", false},
+		{"BOM kept without bom", "utf16(<2)", []byte("\xff\xfeh\x00"), "\uFEFFh", false},
+		{"truncated", "utf16(<2)", []byte("h\x00i"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if values := result.([]any); len(values) != 1 || values[0] != tt.want {
+				t.Errorf("EvalBytes() = %q, want [%q]", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestUtf16ParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"utf16()",
+		"utf16(<0)",
+		"utf16(<H)",
+		"utf16(<2, le)",
+		"utf16(<2",
+		"utf16(4611686018427387904)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+
+	// The string is bound by the maximum string length, in bytes
+	if _, err := ParseExpressionWithOptions("utf16(<3)", Options{MaxStringLen: 6}); err != nil {
+		t.Errorf("ParseExpressionWithOptions() at the limit error = %v", err)
+	}
+	if _, err := ParseExpressionWithOptions("utf16(<4)", Options{MaxStringLen: 6}); err == nil {
+		t.Error("ParseExpressionWithOptions() past the limit expected error, got nil")
+	}
+}
+
+func TestVlqNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    uint32
+		wantErr bool
+	}{
+		{"zero", []byte{0x00}, 0x00, false},
+		{"one byte max", []byte{0x7F}, 0x7F, false},
+		{"two bytes", []byte{0x81, 0x00}, 0x80, false},
+		{"three bytes", []byte{0xC0, 0x80, 0x00}, 0x100000, false},
+		{"four bytes max", []byte{0xFF, 0xFF, 0xFF, 0x7F}, 0x0FFFFFFF, false},
+		{"trailing bytes left", []byte{0x40, 0x81}, 0x40, false},
+		{"longer than four bytes", []byte{0x81, 0x80, 0x80, 0x80, 0x00}, 0, true},
+		{"truncated", []byte{0x81}, 0, true},
+		{"empty input", []byte{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes("vlq()", tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if values := result.([]any); len(values) != 1 || values[0] != tt.want {
+				t.Errorf("EvalBytes() = %v, want [%#x]", values, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseExpression("vlq(1)"); err == nil {
+		t.Error("ParseExpression(\"vlq(1)\") expected error, got nil")
+	}
+}
+
+func TestAtoiNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    int64
+		wantErr bool
+	}{
+		{"digits", `atoi(",")`, []byte("1234,"), 1234, false},
+		{"negative", `atoi(",")`, []byte("-42,"), -42, false},
+		{"explicit plus", `atoi(" ")`, []byte("+7 "), 7, false},
+		{"binary terminator", `atoi("\x00")`, []byte("99\x00\xff"), 99, false},
+		{"int64 min", `atoi(",")`, []byte("-9223372036854775808,"), math.MinInt64, false},
+		{"out of range", `atoi(",")`, []byte("9223372036854775808,"), 0, true},
+		{"too many digits", `atoi(",")`, []byte("000000000000000000001,"), 0, true},
+		{"no digits", `atoi(",")`, []byte(","), 0, true},
+		{"sign only", `atoi(",")`, []byte("-,"), 0, true},
+		{"invalid character", `atoi(",")`, []byte("12a4,"), 0, true},
+		{"sign after digits", `atoi(",")`, []byte("1-2,"), 0, true},
+		{"missing terminator", `atoi(",")`, []byte("12"), 0, true},
+		{"empty input", `atoi(",")`, []byte{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if values := result.([]any); len(values) != 1 || values[0] != tt.want {
+				t.Errorf("EvalBytes() = %v, want [%d]", values, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{`atoi()`, `atoi(",;")`, `atoi("5")`, `atoi("-")`, `atoi(44)`} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestAppendVlq(t *testing.T) {
+	tests := []struct {
+		value   uint32
+		want    []byte
+		wantErr bool
+	}{
+		{0x00, []byte{0x00}, false},
+		{0x7F, []byte{0x7F}, false},
+		{0x80, []byte{0x81, 0x00}, false},
+		{0x2000, []byte{0xC0, 0x00}, false},
+		{0x100000, []byte{0xC0, 0x80, 0x00}, false},
+		{0x0FFFFFFF, []byte{0xFF, 0xFF, 0xFF, 0x7F}, false},
+		{0x10000000, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := AppendVlq([]byte{0xAA}, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("AppendVlq(%#x) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if want := append([]byte{0xAA}, tt.want...); !bytes.Equal(got, want) {
+			t.Errorf("AppendVlq(%#x) = %x, want %x", tt.value, got, want)
+		}
+
+		// The encoding reads back to the same value
+		result, err := EvalBytes("vlq()", got[1:])
+		if err != nil || result.([]any)[0] != tt.value {
+			t.Errorf("vlq() of %x = %v (%v), want %#x", got[1:], result, err, tt.value)
+		}
+	}
+}
+
+func TestStringArrayNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []string
+		wantErr bool
+	}{
+		{"two strings", "<H | string_array(0)", []byte("\x02\x00foo\x00bar\x00"), []string{"foo", "bar"}, false},
+		{"zero count", "<H | string_array(0)", []byte("\x00\x00foo\x00"), []string{}, false},
+		{"count after other fields", "<BB | string_array(1)", []byte("\xff\x01a\x00"), []string{"a"}, false},
+		{"bracketed index", "<H | string_array([0])", []byte("\x02\x00foo\x00bar\x00"), []string{"foo", "bar"}, false},
+		{"without a pipe", "<H string_array([0])", []byte("\x01\x00foo\x00"), []string{"foo"}, false},
+		{"missing terminator at EOF", "B | string_array(0)", []byte("\x01abc"), nil, true},
+		{"fewer strings than count", "B | string_array(0)", []byte("\x03foo\x00bar\x00"), nil, true},
+		{"index out of range", "B | string_array(1)", []byte("\x01a\x00"), nil, true},
+		{"negative count", "b | string_array(0)", []byte("\xffa\x00"), nil, true},
+		{"non-integer count", "s | string_array(0)", []byte("a\x00"), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// The strings are appended after the existing values
+			values := result.([]any)
+			got, ok := values[len(values)-1].([]string)
+			if !ok {
+				t.Fatalf("Eval() last value = %T, want []string", values[len(values)-1])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") || len(got) != len(tt.want) {
+				t.Errorf("Eval() strings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringArrayNodeUnterminated(t *testing.T) {
+	// The final string cut short by the end of input is a truncation
+	_, err := EvalBytes("B | string_array(0)", []byte("\x02foo\x00bar"))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Eval() error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestFlagSetNodeEval(t *testing.T) {
+	perms := "{1: READ, 2: WRITE, 4: EXEC}"
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []any
+		wantErr bool
+	}{
+		{"some flags", "B | flagset(0, " + perms + ")", []byte{0x05}, []any{[]string{"READ", "EXEC"}}, false},
+		{"no flags", "B | flagset(0, " + perms + ")", []byte{0x00}, []any{[]string{}}, false},
+		{"unknown bits", "B | flagset(0, " + perms + ")", []byte{0x19}, []any{[]string{"READ", "0x18"}}, false},
+		{"other values in place", "<BH | flagset(1, {0x8000: HIDDEN, 0x01: BUSY})", []byte{0x07, 0x01, 0x80}, []any{uint8(7), []string{"BUSY", "HIDDEN"}}, false},
+		{"multi-bit mask", "B | flagset(0, {3: RW, 4: EXEC})", []byte{0x06}, []any{[]string{"EXEC", "0x2"}}, false},
+		{"signed value", "b | flagset(0, {0x80: SIGN})", []byte{0x80}, []any{[]string{"SIGN"}}, false},
+		{"index out of range", "B | flagset(1, " + perms + ")", []byte{0x00}, nil, true},
+		{"non-integer value", "s | flagset(0, " + perms + ")", []byte("a\x00"), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := result.([]any); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvalBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{
+		"B | flagset(0)",
+		"B | flagset(0, {})",
+		"B | flagset(0, {0: NONE})",
+		"B | flagset(0, {1: A, 1: B})",
+		"B | flagset(0, {1: A, 2: A})",
+		"B | flagset(0, {1 READ})",
+		"B | flagset(0, {1: READ}",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestByteSwapNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    any
+		wantErr bool
+	}{
+		{"uint16 array", "<2H | byteswap(0)", []byte{0x01, 0x02, 0x03, 0x04}, []uint16{0x0102, 0x0304}, false},
+		{"int32 array", "<2i | byteswap(0)", []byte{0xff, 0xff, 0xff, 0xfe, 0x00, 0x00, 0x00, 0x01}, []int32{-2, 1}, false},
+		{"uint64 scalar", "<Q | byteswap(0)", []byte{0, 0, 0, 0, 0, 0, 0, 0x2a}, uint64(42), false},
+		{"int16 scalar", "<h | byteswap(0)", []byte{0xff, 0xfe}, int16(-2), false},
+		{"single bytes unchanged", "4B | byteswap(0)", []byte{0x01, 0x02, 0x03, 0x04}, []uint8{1, 2, 3, 4}, false},
+		{"string", "s | byteswap(0)", []byte("ab\x00"), nil, true},
+		{"index out of range", "<H | byteswap(1)", []byte{0x01, 0x02}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			values := result.([]any)
+			if len(values) != 1 || !reflect.DeepEqual(values[0], tt.want) {
+				t.Errorf("EvalBytes() = %v, want [%v]", values, tt.want)
+			}
+		})
+	}
+
+	// The other values keep their index, and the input array is not modified
+	node, err := ParseExpression("<H | byteswap(1)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	words := []uint16{0x0102}
+	result, err := node.(*PipeNode).Right.Eval(nil, []any{uint8(7), words})
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if got := result.([]any); got[0] != uint8(7) || got[1].([]uint16)[0] != 0x0201 || words[0] != 0x0102 {
+		t.Errorf("Eval() = %v (input %v), want [7 [513]] with the input unchanged", got, words)
+	}
+}
+
+func TestSampleNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    float64
+		wantErr bool
+	}{
+		{"int16 minimum", "<h | sample(0, 16)", []byte{0x00, 0x80}, -1, false},
+		{"int16 half", "<h | sample(0, 16)", []byte{0x00, 0x40}, 0.5, false},
+		{"int16 maximum", "<h | sample(0, 16)", []byte{0xFF, 0x7F}, 32767.0 / 32768, false},
+		{"uint8 offset binary", "B | sample(0, 8)", []byte{0x80}, 0, false},
+		{"uint8 minimum", "B | sample(0, 8)", []byte{0x00}, -1, false},
+		{"int8", "b | sample(0, 8)", []byte{0xC0}, -0.5, false},
+		{"24-bit in int32", "<i | sample(0, 24)", []byte{0x00, 0x00, 0xC0, 0xFF}, -0.5, false},
+		{"int32", "<i | sample(0, 32)", []byte{0x00, 0x00, 0x00, 0x40}, 0.5, false},
+		{"second value", "<Bh | sample(1, 16)", []byte{0x01, 0x00, 0xC0}, -0.5, false},
+		{"value too wide for the depth", "<h | sample(0, 8)", []byte{0x00, 0x01}, 0, true},
+		{"unsigned too wide for the depth", "<H | sample(0, 8)", []byte{0x00, 0x01}, 0, true},
+		{"index out of range", "<h | sample(1, 16)", []byte{0x00, 0x00}, 0, true},
+		{"non-integer value", "s | sample(0, 8)", []byte{'a', 0x00}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			values := result.([]any)
+			if len(values) != 1 || values[0] != tt.want {
+				t.Errorf("EvalBytes() = %v, want [%v]", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampleParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"<h | sample(0, 12)",
+		"<h | sample(0)",
+		"<h | sample(0, 16",
+		"sample(0, 16)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestDurationNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []any
+		wantErr bool
+	}{
+		{"milliseconds", "<I | duration(0, ms)", []byte{0x3C, 0x44, 0x01, 0x00}, []any{"1m23.004s"}, false},
+		{"seconds", "<H | duration(0, s)", []byte{0x10, 0x0E}, []any{"1h0m0s"}, false},
+		{"microseconds", "B | duration(0, us)", []byte{0x05}, []any{"5µs"}, false},
+		{"nanoseconds", "B | duration(0, ns)", []byte{0x00}, []any{"0s"}, false},
+		{"negative", "b | duration(0, ms)", []byte{0xFE}, []any{"-2ms"}, false},
+		{"keep the raw value", "<BH | duration(1, ms, keep)", []byte{0x01, 0xE8, 0x03}, []any{"1s", uint16(1000)}, false},
+		{"overflow", "<q | duration(0, s)", []byte{0, 0, 0, 0, 0, 0, 0, 0x7F}, nil, true},
+		{"uint64 beyond int64", "<Q | duration(0, ns)", []byte{0, 0, 0, 0, 0, 0, 0, 0x80}, nil, true},
+		{"index out of range", "B | duration(1, ms)", []byte{0x01}, nil, true},
+		{"non-integer value", "s | duration(0, ms)", []byte{'a', 0x00}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			values := result.([]any)
+			if len(values) != len(tt.want) {
+				t.Fatalf("EvalBytes() = %v, want %v", values, tt.want)
+			}
+			for i := range values {
+				if values[i] != tt.want[i] {
+					t.Errorf("EvalBytes()[%d] = %v (%T), want %v (%T)", i, values[i], values[i], tt.want[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDurationParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"<I | duration(0, h)",
+		"<I | duration(0)",
+		"<I | duration(0, ms, raw)",
+		"<I | duration(0, ms",
+		"duration(0, ms)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
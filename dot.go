@@ -0,0 +1,201 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WriteDOT writes the node tree of a parsed expression as a GraphViz DOT graph,
+// with a box per node labeled by its type and arguments (e.g., the format codes
+// of a FormatNode) and an edge per child. Render it with `dot -Tsvg`.
+func WriteDOT(w io.Writer, node Node) error {
+	g := &dotGraph{}
+	g.sb.WriteString("digraph bq {\n\tnode [shape=box, fontname=monospace];\n")
+	g.addNode(node)
+	g.sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, g.sb.String())
+	return err
+}
+
+// dotGraph accumulates the statements of a DOT graph, numbering the nodes in
+// the order they are visited.
+type dotGraph struct {
+	sb    strings.Builder
+	count int
+}
+
+// add writes a graph node with the given label and returns its identifier.
+func (g *dotGraph) add(label string) string {
+	id := fmt.Sprintf("n%d", g.count)
+	g.count++
+	fmt.Fprintf(&g.sb, "\t%s [label=\"%s\"];\n", id, dotEscape(label))
+	return id
+}
+
+// edge writes an edge between two graph nodes, labeled unless the label is empty.
+func (g *dotGraph) edge(from, to, label string) {
+	if label == "" {
+		fmt.Fprintf(&g.sb, "\t%s -> %s;\n", from, to)
+		return
+	}
+	fmt.Fprintf(&g.sb, "\t%s -> %s [label=\"%s\"];\n", from, to, dotEscape(label))
+}
+
+// addNode writes a node and its children, returning the node's identifier.
+func (g *dotGraph) addNode(node Node) string {
+	switch n := node.(type) {
+	case *FormatNode:
+		return g.add("FormatNode\n" + formatExprString(n.Expr))
+	case *PipeNode:
+		id := g.add("PipeNode")
+		g.edge(id, g.addNode(n.Left), "left")
+		g.edge(id, g.addNode(n.Right), "right")
+		return id
+	case *ObjectNode:
+		return g.addObject(n)
+	case *NamedNode:
+		id := g.add(fmt.Sprintf("NamedNode\n%q", n.Title))
+		g.edge(id, g.addNode(n.Inner), "")
+		return id
+	case *SplitNode:
+		id := g.add(fmt.Sprintf("SplitNode\ndelim 0x%02x", n.Delim))
+		g.edge(id, g.addNode(n.Inner), "record")
+		return id
+	case *UntilZeroNode:
+		id := g.add(fmt.Sprintf("UntilZeroNode\n%d bytes", n.Size))
+		g.edge(id, g.addNode(n.Inner), "record")
+		return id
+	case *StrideNode:
+		label := fmt.Sprintf("StrideNode\n%d bytes", n.Stride)
+		if n.Pad {
+			label += ", pad"
+		}
+		id := g.add(label)
+		g.edge(id, g.addNode(n.Inner), "record")
+		return id
+	case *RepeatPrevNode:
+		id := g.add("RepeatPrevNode")
+		g.edge(id, g.addNode(n.Inner), "record")
+		return id
+	case *TableNode:
+		id := g.add("TableNode")
+		g.edge(id, g.addNode(n.Inner), "record")
+		return id
+	case *AtNode:
+		id := g.add("AtNode")
+		g.edge(id, g.addNode(n.Inner), "")
+		return id
+	case *ReparseNode:
+		id := g.add("ReparseNode")
+		g.edge(id, g.addNode(n.Inner), "")
+		return id
+	case *SeekNode:
+		label := fmt.Sprintf("offset %d", n.Offset)
+		if n.Whence == io.SeekCurrent {
+			label = fmt.Sprintf("offset %+d from current", n.Offset)
+		}
+		id := g.add("SeekNode\n" + label)
+		g.edge(id, g.addNode(n.Inner), "at offset")
+		return id
+	case *FollowNode:
+		id := g.add("FollowNode")
+		g.edge(id, g.addNode(n.Inner), "each offset")
+		return id
+	case *VersionNode:
+		id := g.add(fmt.Sprintf("VersionNode\nindex %d", n.Index))
+		for _, v := range slices.Sorted(maps.Keys(n.Branches)) {
+			g.edge(id, g.addNode(n.Branches[v]), fmt.Sprint(v))
+		}
+		return id
+	case *UnionNode:
+		id := g.add(fmt.Sprintf("UnionNode\n%d bytes", n.Size))
+		for _, alt := range n.Alternatives {
+			g.edge(id, g.addNode(alt), "")
+		}
+		return id
+	case *FanoutNode:
+		id := g.add("FanoutNode")
+		for i, child := range n.Children {
+			g.edge(id, g.addNode(child), fmt.Sprint(i))
+		}
+		return id
+	case *WriteNode:
+		return g.add(fmt.Sprintf("WriteNode\n%q", n.Path))
+	case *ExtractNode:
+		return g.add(fmt.Sprintf("ExtractNode\n%q", n.Path))
+	case *MarkNode:
+		return g.add(fmt.Sprintf("MarkNode\n%q", n.Name))
+	case *SearchNode:
+		return g.add(fmt.Sprintf("SearchNode\n%q", n.Pattern))
+	default:
+		return g.add(strings.TrimPrefix(fmt.Sprintf("%T", node), "*bq."))
+	}
+}
+
+// addObject writes an object node with a child per field, nesting the nested
+// objects below their field.
+func (g *dotGraph) addObject(n *ObjectNode) string {
+	id := g.add("ObjectNode")
+	for _, fd := range n.Fields {
+		switch {
+		case fd.Nested != nil:
+			g.edge(id, g.addObject(fd.Nested), fd.Name)
+		case fd.Compute != nil:
+			g.edge(id, g.add(fd.Name+": computed"), "")
+		default:
+			optional := ""
+			if fd.Optional {
+				optional = "?"
+			}
+			g.edge(id, g.add(fmt.Sprintf("%d%s -> %s", fd.Index, optional, fd.Name)), "")
+		}
+	}
+	return id
+}
+
+// formatExprString renders the format codes of an expression in the expression
+// syntax, e.g. "<bH>I" or "4B:magic".
+func formatExprString(e *Expr) string {
+	var sb strings.Builder
+	sb.WriteString(orderPrefixes[e.Order])
+
+	order := e.Order
+	for _, fc := range e.Formats {
+		if fc.Order != order {
+			sb.WriteString(orderPrefixes[fc.Order])
+			order = fc.Order
+		}
+		if fc.Rest {
+			sb.WriteByte('*')
+		} else if fc.Count > 1 {
+			fmt.Fprint(&sb, fc.Count)
+		}
+		sb.WriteRune(fc.Code)
+		if fc.Bits > 0 {
+			fmt.Fprintf(&sb, "/%d", fc.Bits)
+		}
+		if fc.Name != "" {
+			sb.WriteString(":" + fc.Name + " ")
+		} else if fc.Bits > 0 {
+			// A space ends the bit width before a following count
+			sb.WriteByte(' ')
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// orderPrefixes maps byte orders to their prefix in the expression syntax.
+var orderPrefixes = map[ByteOrder]string{
+	NativeOrder:  "@",
+	LittleEndian: "<",
+	BigEndian:    ">",
+}
+
+// dotEscape escapes a label for a DOT quoted string, keeping line breaks.
+func dotEscape(label string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(label)
+}
@@ -0,0 +1,88 @@
+package bq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{
+			name: "nested object",
+			expr: "<bHB | {0 -> h, nested: {1 -> l, 2? -> f}, c: $0 * 2}",
+			want: []string{
+				"\tn0 [label=\"PipeNode\"];\n",
+				"\tn1 [label=\"FormatNode\\n<bHB\"];\n",
+				"\tn0 -> n1 [label=\"left\"];\n",
+				"\tn0 -> n2 [label=\"right\"];\n",
+				"\tn3 [label=\"0 -> h\"];\n",
+				"\tn2 -> n4 [label=\"nested\"];\n",
+				"\tn6 [label=\"2? -> f\"];\n",
+				"\tn7 [label=\"c: computed\"];\n",
+			},
+		},
+		{
+			name: "records and sinks",
+			expr: `name("log", split(0x0A, <b:a >2I | write("o\"ut")))`,
+			want: []string{
+				"\tn0 [label=\"NamedNode\\n\\\"log\\\"\"];\n",
+				"\tn1 [label=\"SplitNode\\ndelim 0x0a\"];\n",
+				"\tn1 -> n2 [label=\"record\"];\n",
+				"\tn3 [label=\"FormatNode\\n<b:a >2I\"];\n",
+				"\tn4 [label=\"WriteNode\\n\\\"o\\\\\\\"ut\\\"\"];\n",
+			},
+		},
+		{
+			name: "union alternatives",
+			expr: "union(<I, >2H)",
+			want: []string{
+				"\tn0 [label=\"UnionNode\\n4 bytes\"];\n",
+				"\tn2 [label=\"FormatNode\\n>2H\"];\n",
+				"\tn0 -> n2;\n",
+			},
+		},
+		{
+			name: "version branches",
+			expr: "<H | version(0, {2: >I, 1: B})",
+			want: []string{
+				"\tn2 [label=\"VersionNode\\nindex 0\"];\n",
+				"\tn2 -> n3 [label=\"1\"];\n",
+				"\tn4 [label=\"FormatNode\\n>I\"];\n",
+				"\tn2 -> n4 [label=\"2\"];\n",
+			},
+		},
+		{
+			name: "other nodes",
+			expr: "B | setbits(0)",
+			want: []string{"\tn2 [label=\"SetBitsNode\"];\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := WriteDOT(&buf, node); err != nil {
+				t.Fatalf("WriteDOT() error = %v", err)
+			}
+			got := buf.String()
+			if !strings.HasPrefix(got, "digraph bq {\n") || !strings.HasSuffix(got, "}\n") {
+				t.Errorf("WriteDOT() is not a digraph:\n%s", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("WriteDOT() missing %q\nGot:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package bq
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unsafe"
 
@@ -28,58 +34,342 @@ type FormatNode struct {
 }
 
 // Eval reads binary data from the reader according to the format codes.
+// If any format code has an inline name, the values are returned as an *Object
+// (unnamed codes use their index as the field name).
 func (n *FormatNode) Eval(r io.Reader, _ []any) (any, error) {
-	return n.Read(r)
+	values, err := n.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return n.record(values), nil
+}
+
+// record returns the values read by the format codes as they are, or as an
+// *Object if any format code has an inline name.
+func (n *FormatNode) record(values []any) any {
+	if !n.hasNames() {
+		return values
+	}
+
+	obj := &Object{
+		Fields: make([]ObjectField, 0, len(values)),
+	}
+	formats := n.valueFormats()
+	for i, val := range values {
+		name := formats[i].Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		obj.Fields = append(obj.Fields, ObjectField{Name: name, Value: val})
+	}
+	return obj
+}
+
+// hasNames returns true if any format code has an inline field name.
+func (e *Expr) hasNames() bool {
+	for _, fc := range e.Formats {
+		if fc.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldsNode keeps only the listed value indices, renumbered in the given order.
+type FieldsNode struct {
+	Indices []int // indices into the input values to keep
+}
+
+// Eval selects the listed values from the input.
+func (n *FieldsNode) Eval(_ io.Reader, values []any) (any, error) {
+	result := make([]any, 0, len(n.Indices))
+	for _, idx := range n.Indices {
+		if idx < 0 || idx >= len(values) {
+			return nil, fmt.Errorf("fields: index %d out of range (have %d values)", idx, len(values))
+		}
+		result = append(result, values[idx])
+	}
+	return result, nil
+}
+
+// SliceNode keeps the contiguous values from Start up to, but not including, End,
+// e.g. slice(1, 3). Negative indices count from the end, as in Python, so
+// slice(-2, -1) keeps the second to last value.
+type SliceNode struct {
+	Start int // index of the first value kept
+	End   int // index past the last value kept
+}
+
+// Eval selects the range of values from the input. A range outside the values,
+// or ending before it starts, is an error.
+func (n *SliceNode) Eval(_ io.Reader, values []any) (any, error) {
+	start, end := n.Start, n.End
+	if start < 0 {
+		start += len(values)
+	}
+	if end < 0 {
+		end += len(values)
+	}
+	if start < 0 || end > len(values) || start > end {
+		return nil, fmt.Errorf("slice: range %d:%d out of range (have %d values)", n.Start, n.End, len(values))
+	}
+	return values[start:end:end], nil
+}
+
+// RenameNode renames a field of the incoming object, keeping its position.
+type RenameNode struct {
+	Old string // current field name
+	New string // new field name
 }
 
-// PipeNode chains two nodes together, passing output from left to right.
+// Eval rejects plain values, which have no field names to rename.
+func (n *RenameNode) Eval(_ io.Reader, _ []any) (any, error) {
+	return nil, fmt.Errorf("rename(%s, %s) expects an object", n.Old, n.New)
+}
+
+// EvalObject returns a copy of the object with the field renamed.
+func (n *RenameNode) EvalObject(_ io.Reader, obj *Object) (any, error) {
+	idx := -1
+	for i, f := range obj.Fields {
+		switch f.Name {
+		case n.Old:
+			idx = i
+		case n.New:
+			return nil, fmt.Errorf("rename: field %q already exists", n.New)
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("rename: no field %q", n.Old)
+	}
+
+	fields := make([]ObjectField, len(obj.Fields))
+	copy(fields, obj.Fields)
+	fields[idx].Name = n.New
+	return &Object{Fields: fields}, nil
+}
+
+// DropNode removes the named fields from the incoming object. Dropping a field
+// the object does not have is an error, which catches misspelled names.
+type DropNode struct {
+	Names []string // names of the fields to drop
+}
+
+// Eval rejects plain values, which have no field names to drop.
+func (n *DropNode) Eval(_ io.Reader, _ []any) (any, error) {
+	return nil, fmt.Errorf("drop(%s) expects an object", strings.Join(n.Names, ", "))
+}
+
+// EvalObject returns a copy of the object without the dropped fields.
+func (n *DropNode) EvalObject(_ io.Reader, obj *Object) (any, error) {
+	drop := make(map[string]bool, len(n.Names))
+	for _, name := range n.Names {
+		drop[name] = true
+	}
+
+	fields := make([]ObjectField, 0, len(obj.Fields))
+	dropped := make(map[string]bool, len(n.Names))
+	for _, f := range obj.Fields {
+		if drop[f.Name] {
+			dropped[f.Name] = true
+			continue
+		}
+		fields = append(fields, f)
+	}
+	for _, name := range n.Names {
+		if !dropped[name] {
+			return nil, fmt.Errorf("drop: no field %q", name)
+		}
+	}
+	return &Object{Fields: fields}, nil
+}
+
+// PipeNode chains two nodes together, passing output from left to right. Pipes
+// chain left to right, so each stage of `a | b | c` receives the result of the
+// stages before it.
 type PipeNode struct {
-	Left  Node // produces []any
-	Right Node // consumes []any
+	Left  Node // produces []any or *Object
+	Right Node // consumes []any, or the *Object for an ObjectTransform
+}
+
+// ObjectTransform is implemented by nodes which transform an incoming object as a
+// whole, keeping its field names (e.g., renaming a field), instead of consuming
+// its values by index.
+type ObjectTransform interface {
+	Node
+	// EvalObject transforms the object produced by the left side of a pipe.
+	EvalObject(r io.Reader, obj *Object) (any, error)
+}
+
+// ConsumedSink is implemented by nodes which work on the input bytes consumed by
+// the left side of a pipe (e.g., extracting the bytes of a field), located by the
+// byte ranges of the fields of its format expression.
+type ConsumedSink interface {
+	Node
+	// EvalConsumed works on the values and the consumed bytes of the left side,
+	// which started at the start offset of the input (0 if unknown).
+	EvalConsumed(left Node, values []any, consumed []byte, start int64) (any, error)
+}
+
+// ResultSink is implemented by nodes which work on the result of the left side of
+// a pipe as is, an object keeping its field names or the values (e.g., printing
+// it as JSON).
+type ResultSink interface {
+	Node
+	// EvalResult works on the result produced by the left side of a pipe.
+	EvalResult(r io.Reader, result any) (any, error)
 }
 
 // Eval evaluates the left node, then passes its result to the right node.
 func (n *PipeNode) Eval(r io.Reader, values []any) (any, error) {
+	// A consumed sink works on the input bytes, so capture what the left side reads
+	var consumed bytes.Buffer
+	var start int64
+	sink, isSink := n.Right.(ConsumedSink)
+	var tee *teeReader
+	if isSink {
+		offset, err := currentOffset(r)
+		known := err == nil
+		tee = newTeeReader(r, &consumed, offset)
+		r = tee
+		if known {
+			// Keep the input offset available to mark() on the left side
+			r = &countingReader{r: tee, offset: offset}
+		}
+	}
+
+	// The records of repeat() go through the stages after it as they are read
+	if !isSink && streamsRecords(n) {
+		results := make([]any, 0)
+		err := eachRecord(r, n, func(rec any) error {
+			results = append(results, rec)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+
 	leftResult, err := n.Left.Eval(r, values)
 	if err != nil {
 		return nil, err
 	}
+	if tee != nil {
+		// A seek on the left side moves the start of the consumed bytes
+		start = tee.start
+	}
+	n.inherit()
+
+	if isSink {
+		leftValues, err := pipeValues(leftResult)
+		if err != nil {
+			return nil, err
+		}
+		return sink.EvalConsumed(n.Left, leftValues, consumed.Bytes(), start)
+	}
+	// The records of repeat() go through each stage after it one at a time,
+	// except a record filter such as where(), which takes the whole list
+	if repeatsRecords(n.Left) && !filtersRecords(n.Right) {
+		return n.evalRecords(r, leftResult.([]any))
+	}
+	return pipeResult(r, leftResult, n.Right)
+}
+
+// inherit passes the byte order of the left FormatNode on to a write on the right,
+// and its format codes when the values (or the fields of an object naming them in
+// order) line up with them.
+func (n *PipeNode) inherit() {
+	if formatExpr, ok := extractFormatNode(n.Left); ok {
+		inheritByteOrder(n.Right, formatExpr.Order)
+	}
+	if formatExpr, ok := valuesFormatNode(n.Left); ok {
+		inheritFormats(n.Right, formatExpr.Formats)
+	}
+}
+
+// pipeResult passes the result of the left side of a pipe to the right side: the
+// result as is for a result sink, the object itself for an object transform, or
+// its values otherwise.
+func pipeResult(r io.Reader, leftResult any, right Node) (any, error) {
+	if sink, ok := right.(ResultSink); ok {
+		return sink.EvalResult(r, leftResult)
+	}
+
+	// An object transform works on the object itself rather than its values
+	if transform, ok := right.(ObjectTransform); ok {
+		obj, ok := leftResult.(*Object)
+		if !ok {
+			return nil, fmt.Errorf("pipe right side expects an object, got %T; "+
+				"name the values first, e.g. '<bH | {0 -> a, 1 -> b}'", leftResult)
+		}
+		return transform.EvalObject(r, obj)
+	}
+
+	leftValues, err := pipeValues(leftResult)
+	if err != nil {
+		return nil, err
+	}
+	return right.Eval(r, leftValues)
+}
 
-	// Left result can be []any or *Object
-	var leftValues []any
+// pipeValues returns the values of the left side of a pipe, which can be []any
+// or the field values of an *Object.
+func pipeValues(leftResult any) ([]any, error) {
 	switch lr := leftResult.(type) {
 	case []any:
-		leftValues = lr
+		return lr, nil
 	case *Object:
 		// Extract values from object for piping
-		leftValues = make([]any, len(lr.Fields))
+		values := make([]any, len(lr.Fields))
 		for i, f := range lr.Fields {
-			leftValues[i] = f.Value
+			values[i] = f.Value
 		}
+		return values, nil
 	default:
 		return nil, fmt.Errorf("pipe left side must produce []any or *Object, got %T", leftResult)
 	}
+}
 
-	// If right is WriteNode, inherit byte order from left FormatNode
-	if writeNode, ok := n.Right.(*WriteNode); ok {
-		if formatExpr, ok := extractFormatNode(n.Left); ok {
-			writeNode.ByteOrder = formatExpr.Order
+// inheritByteOrder sets the byte order of a WriteNode, including those fanned
+// out to, from the format expression on the left side of its pipe.
+func inheritByteOrder(node Node, order ByteOrder) {
+	switch n := node.(type) {
+	case *WriteNode:
+		n.ByteOrder = order
+	case *FanoutNode:
+		for _, child := range n.Children {
+			inheritByteOrder(child, order)
 		}
 	}
+}
 
-	return n.Right.Eval(r, leftValues)
+// inheritFormats sets the format codes of a WriteNode, including those fanned
+// out to, from the format expression on the left side.
+func inheritFormats(node Node, formats []FormatCode) {
+	switch n := node.(type) {
+	case *WriteNode:
+		n.Formats = formats
+	case *FanoutNode:
+		for _, child := range n.Children {
+			inheritFormats(child, formats)
+		}
+	}
 }
 
 // FieldDef defines a single field in an object with index mapping or nested object.
 type FieldDef struct {
-	Index  int         // index into the input values (ignored if Nested is set)
-	Name   string      // field name in the output object
-	Nested *ObjectNode // nested object definition (nil for regular index field)
+	Index    int         // index into the input values (ignored if Nested or Compute is set)
+	Name     string      // field name in the output object
+	Nested   *ObjectNode // nested object definition (nil for regular index field)
+	Compute  ArithExpr   // computed value (nil for regular index field)
+	Optional bool        // an out-of-range index yields nil instead of an error
+	Hint     string      // render hint for the Value column (HintHex, HintDec or empty)
 }
 
 // ObjectNode creates named fields from indexed values.
 type ObjectNode struct {
-	Fields []FieldDef // ordered list of field definitions
+	Fields       []FieldDef // ordered list of field definitions
+	WarnUnmapped bool       // log the input values no field refers to
 }
 
 // Eval transforms the input values into an Object with named fields.
@@ -89,7 +379,18 @@ func (n *ObjectNode) Eval(_ io.Reader, values []any) (any, error) {
 	}
 
 	for _, fd := range n.Fields {
-		if fd.Nested != nil {
+		if fd.Compute != nil {
+			// Computed field: evaluate the arithmetic over the values
+			computed, err := fd.Compute.Eval(values)
+			if err != nil {
+				return nil, fmt.Errorf("computed field %q: %w", fd.Name, err)
+			}
+			obj.Fields = append(obj.Fields, ObjectField{
+				Name:  fd.Name,
+				Value: computed,
+				Hint:  fd.Hint,
+			})
+		} else if fd.Nested != nil {
 			// Nested object: recursively evaluate
 			nestedResult, err := fd.Nested.Eval(nil, values)
 			if err != nil {
@@ -100,39 +401,172 @@ func (n *ObjectNode) Eval(_ io.Reader, values []any) (any, error) {
 				Value: nestedResult,
 			})
 		} else {
-			// Regular index field
+			// Regular index field, where an optional field tolerates a short record
 			if fd.Index < 0 || fd.Index >= len(values) {
+				if fd.Optional {
+					obj.Fields = append(obj.Fields, ObjectField{Name: fd.Name, Hint: fd.Hint})
+					continue
+				}
 				return nil, fmt.Errorf("field %q: index %d out of range (have %d values)", fd.Name, fd.Index, len(values))
 			}
 			obj.Fields = append(obj.Fields, ObjectField{
 				Name:  fd.Name,
 				Value: values[fd.Index],
+				Hint:  fd.Hint,
 			})
 		}
 	}
 
+	if n.WarnUnmapped {
+		if unmapped := n.unmapped(len(values)); len(unmapped) > 0 {
+			log.Warn().Ints("indices", unmapped).Int("values", len(values)).Msg("object leaves values unmapped")
+		}
+	}
 	return obj, nil
 }
 
+// unmapped returns the indices below count which no field, nested field or
+// computed field refers to.
+func (n *ObjectNode) unmapped(count int) []int {
+	mapped := make(map[int]bool)
+	n.mappedIndices(mapped)
+
+	var unmapped []int
+	for i := range count {
+		if !mapped[i] {
+			unmapped = append(unmapped, i)
+		}
+	}
+	return unmapped
+}
+
+// keepsPositions returns true if the object maps the values to its fields in
+// order ({0 -> a, 1 -> b}), so the field values still line up with the format
+// codes the values were read with.
+func (n *ObjectNode) keepsPositions() bool {
+	for i, fd := range n.Fields {
+		if fd.Compute != nil || fd.Nested != nil || fd.Optional || fd.Index != i {
+			return false
+		}
+	}
+	return true
+}
+
+// mappedIndices adds the value indices the fields refer to into mapped.
+func (n *ObjectNode) mappedIndices(mapped map[int]bool) {
+	for _, fd := range n.Fields {
+		switch {
+		case fd.Compute != nil:
+			arithRefs(fd.Compute, mapped)
+		case fd.Nested != nil:
+			fd.Nested.mappedIndices(mapped)
+		default:
+			mapped[fd.Index] = true
+		}
+	}
+}
+
 // ObjectField represents a single field in an Object result.
 type ObjectField struct {
 	Name  string // field name
 	Value any    // field value
+	Hint  string // render hint for the Value column (HintHex, HintDec or empty)
 }
 
+// Render hints select how a field's value is shown in the Value column of the
+// pretty-print table (e.g., {0 -> id #hex, 1 -> count #dec}).
+const (
+	HintHex = "hex" // integers in hex, like the Hex column
+	HintDec = "dec" // integers in decimal, including arrays
+)
+
 // Object represents the result of object construction.
 type Object struct {
 	Fields []ObjectField
 }
 
-// WriteNode writes binary data to a file.
+// Get returns the value of the field with the name. Field names may repeat (e.g.,
+// {0 -> a, 1 -> a}), in which case the last field wins, as in Map.
+func (o *Object) Get(name string) (any, bool) {
+	for i := len(o.Fields) - 1; i >= 0; i-- {
+		if o.Fields[i].Name == name {
+			return o.Fields[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetPath returns the value at a dotted path of field names through nested
+// objects, e.g. "header.length", looking up each name like Get.
+func (o *Object) GetPath(path string) (any, bool) {
+	var val any = o
+	for _, name := range strings.Split(path, ".") {
+		obj, ok := val.(*Object)
+		if !ok {
+			return nil, false
+		}
+		if val, ok = obj.Get(name); !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// Keys returns the names of the fields in order, including repeated names.
+func (o *Object) Keys() []string {
+	keys := make([]string, len(o.Fields))
+	for i, f := range o.Fields {
+		keys[i] = f.Name
+	}
+	return keys
+}
+
+// Map returns the fields of the object as a map keyed by field name, converting
+// nested objects, including those of a record stream, to maps as well. A map has
+// no order, so use Keys or Fields when the field order matters, and of repeated
+// names only the last field is kept.
+func (o *Object) Map() map[string]any {
+	m := make(map[string]any, len(o.Fields))
+	for _, f := range o.Fields {
+		m[f.Name] = mapValue(f.Value)
+	}
+	return m
+}
+
+// mapValue converts the objects within a value to maps.
+func mapValue(val any) any {
+	switch v := val.(type) {
+	case *Object:
+		return v.Map()
+	case []any:
+		out := make([]any, len(v))
+		for i, elem := range v {
+			out[i] = mapValue(elem)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+// WriteNode writes binary data to a file, or to stdout for the path "-".
 type WriteNode struct {
-	Path      string    // output file path
-	ByteOrder ByteOrder // byte order for writing
+	Path      string       // output file path, or "-" for stdout
+	ByteOrder ByteOrder    // byte order for writing
+	Formats   []FormatCode // format codes the values were read with, if known
 }
 
-// Eval writes the input values to the specified file.
-func (n *WriteNode) Eval(_ io.Reader, values []any) (any, error) {
+// Eval writes the input values to the specified file, or for the "-" path to the
+// standard output of the evaluation (see WithStdout).
+func (n *WriteNode) Eval(r io.Reader, values []any) (any, error) {
+	// Stdout is left open for the rest of the output
+	if n.Path == "-" {
+		if err := n.writeValues(evalStdout(r), values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+
 	// Create or truncate the output file
 	f, err := os.Create(n.Path)
 	if err != nil {
@@ -154,11 +588,56 @@ func (n *WriteNode) Eval(_ io.Reader, values []any) (any, error) {
 	return values, nil
 }
 
-// writeValues encodes and writes all values to the writer.
+// writeValues encodes and writes all values to the writer, laid out by the format
+// codes they were read with (see encodeFormatted).
 func (n *WriteNode) writeValues(w io.Writer, values []any) error {
-	order := n.binaryOrder()
-	for i, val := range values {
-		if err := encodeValue(w, val, order); err != nil {
+	return encodeFormatted(w, n.Formats, values, n.binaryOrder())
+}
+
+// encodeFormatted encodes the values read with the format codes: a pad code
+// writes its count of zero bytes, a fixed-length string is padded or truncated
+// to its width, and bit-fields are packed back into their unit. The values use
+// the byte order of their own code, so a mixed-order expression (e.g., <H>I) is
+// written back as read; values past the codes (e.g., appended by mark()) are
+// encoded as is in the given byte order.
+func encodeFormatted(w io.Writer, formats []FormatCode, values []any, order binary.ByteOrder) error {
+	i := 0
+	var unit uint64 // bits of the unit shared by consecutive bit-fields
+	for j, fc := range formats {
+		if fc.isPad() {
+			if _, err := w.Write(make([]byte, max(fc.Count, 1))); err != nil {
+				return fmt.Errorf("failed to encode padding before value %d: %w", i, err)
+			}
+			continue
+		}
+		if i >= len(values) {
+			break
+		}
+
+		valueOrder := fc.binaryOrder()
+
+		var err error
+		if fc.Bits > 0 {
+			if fc.BitOffset == 0 {
+				unit = 0
+			}
+			// The unit is written once its last bit-field is packed
+			if unit, err = fc.packBits(unit, values[i]); err == nil && closesBitUnit(formats, j, i+1 == len(values)) {
+				err = encodeBitUnit(w, unit, fc.Size, valueOrder)
+			}
+		} else if str, ok := values[i].(string); ok && fc.fixedString() {
+			err = encodeFixedString(w, str, fc.Count)
+		} else {
+			err = encodeValue(w, values[i], valueOrder)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode value at index %d: %w", i, err)
+		}
+		i++
+	}
+
+	for ; i < len(values); i++ {
+		if err := encodeValue(w, values[i], order); err != nil {
 			return fmt.Errorf("failed to encode value at index %d: %w", i, err)
 		}
 	}
@@ -170,6 +649,15 @@ func (n *WriteNode) binaryOrder() binary.ByteOrder {
 	return toBinaryOrder(n.ByteOrder)
 }
 
+// encodeFixedString encodes a string into exactly width bytes, padding a short
+// string with NULs and truncating a long one.
+func encodeFixedString(w io.Writer, s string, width int) error {
+	buf := make([]byte, width)
+	copy(buf, s)
+	_, err := w.Write(buf)
+	return err
+}
+
 // encodeValue encodes a single value to binary format.
 func encodeValue(w io.Writer, val any, order binary.ByteOrder) error {
 	switch v := val.(type) {
@@ -189,6 +677,12 @@ func encodeValue(w io.Writer, val any, order binary.ByteOrder) error {
 		return binary.Write(w, order, v)
 	case uint64:
 		return binary.Write(w, order, v)
+	case *big.Int:
+		return encodeInt128(w, v, order)
+	case float32:
+		return binary.Write(w, order, v)
+	case float64:
+		return binary.Write(w, order, v)
 	case string:
 		// Write null-terminated string
 		if _, err := w.Write([]byte(v)); err != nil {
@@ -218,6 +712,20 @@ func encodeValue(w io.Writer, val any, order binary.ByteOrder) error {
 		return binary.Write(w, order, v)
 	case []uint64:
 		return binary.Write(w, order, v)
+	case []*big.Int:
+		for _, x := range v {
+			if err := encodeInt128(w, x, order); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []float32:
+		return binary.Write(w, order, v)
+	case []float64:
+		return binary.Write(w, order, v)
+	case Mark:
+		// Markers are zero-width
+		return nil
 	case *Object:
 		// Encode object fields in order
 		for _, field := range v.Fields {
@@ -231,6 +739,35 @@ func encodeValue(w io.Writer, val any, order binary.ByteOrder) error {
 	}
 }
 
+// Mark is a zero-width marker recording the input offset at a point of the expression.
+type Mark struct {
+	Name   string // marker name
+	Offset int64  // offset into the input in bytes
+}
+
+// String returns the offset as a decimal string.
+func (m Mark) String() string {
+	return strconv.FormatInt(m.Offset, 10)
+}
+
+// MarkNode records the current input offset as a Mark without reading any bytes.
+type MarkNode struct {
+	Name string // marker name
+}
+
+// Eval appends a Mark with the current input offset to the input values, so
+// existing indices are unchanged and the mark takes the next index.
+func (n *MarkNode) Eval(r io.Reader, values []any) (any, error) {
+	offset, err := currentOffset(r)
+	if err != nil {
+		return nil, fmt.Errorf("mark %q: %w", n.Name, err)
+	}
+
+	result := make([]any, len(values), len(values)+1)
+	copy(result, values)
+	return append(result, Mark{Name: n.Name, Offset: offset}), nil
+}
+
 // SearchNode searches for a byte pattern and returns the position.
 type SearchNode struct {
 	Pattern []byte // byte pattern to search for
@@ -266,12 +803,21 @@ const (
 	TokenColon                     // :
 	TokenArrow                     // ->
 	TokenComma                     // ,
-	TokenNumber                    // integer literal (for index)
+	TokenNumber                    // number literal (for index or value)
 	TokenIdent                     // identifier (for field name or function)
 	TokenFormat                    // format code (b, B, h, H, i, I, q, Q)
-	TokenOrder                     // byte order prefix (<, >, @)
+	TokenOrder                     // byte order prefix (<, >, @, =)
 	TokenString                    // string literal "..."
 	TokenQuestion                  // ? (search prefix)
+	TokenPlus                      // +
+	TokenMinus                     // -
+	TokenStar                      // *
+	TokenSlash                     // /
+	TokenDollar                    // $ (value reference in computed fields)
+	TokenHash                      // # (field render hint)
+	TokenCompare                   // comparison operator (==, !=, <=, >=)
+	TokenLBracket                  // [
+	TokenRBracket                  // ]
 )
 
 // Token represents a single token in the expression.
@@ -293,7 +839,15 @@ var singleCharTokens = map[rune]TokenType{
 	'<': TokenOrder,
 	'>': TokenOrder,
 	'@': TokenOrder,
+	'=': TokenOrder,
 	'?': TokenQuestion,
+	'+': TokenPlus,
+	'*': TokenStar,
+	'/': TokenSlash,
+	'$': TokenDollar,
+	'#': TokenHash,
+	'[': TokenLBracket,
+	']': TokenRBracket,
 }
 
 // Tokenizer breaks an expression string into tokens.
@@ -321,6 +875,12 @@ func (t *Tokenizer) Next() (Token, error) {
 	startPos := t.pos
 	ch := t.input[t.pos]
 
+	// Two character comparison operators, before '<', '>' and '=' as byte orders
+	if t.pos+1 < len(t.input) && t.input[t.pos+1] == '=' && strings.ContainsRune("=!<>", ch) {
+		t.pos += 2
+		return Token{Type: TokenCompare, Value: string(ch) + "=", Pos: startPos}, nil
+	}
+
 	// Single character tokens
 	if tokType, ok := singleCharTokens[ch]; ok {
 		t.pos++
@@ -332,6 +892,10 @@ func (t *Tokenizer) Next() (Token, error) {
 		t.pos += 2
 		return Token{Type: TokenArrow, Value: "->", Pos: startPos}, nil
 	}
+	if ch == '-' {
+		t.pos++
+		return Token{Type: TokenMinus, Value: "-", Pos: startPos}, nil
+	}
 
 	// String literal
 	if ch == '"' {
@@ -346,7 +910,7 @@ func (t *Tokenizer) Next() (Token, error) {
 	// Format codes (single character) - only if not followed by non-format-code letters
 	// This distinguishes format codes like 'b' from identifiers like 'bar'
 	// Examples: 'bH' -> two format codes, 'b4B' -> format b, number 4, format B, 'bar' -> identifier
-	if _, ok := formatCodeRegistry[ch]; ok {
+	if isFormatCode(ch) {
 		nextPos := t.pos + 1
 		if nextPos >= len(t.input) {
 			// End of input - it's a format code
@@ -356,7 +920,7 @@ func (t *Tokenizer) Next() (Token, error) {
 		nextCh := t.input[nextPos]
 		// If next char is a format code, digit, or not alphanumeric, treat current as format code
 		// Only treat as identifier start if followed by non-format-code letter
-		_, nextIsFormat := formatCodeRegistry[nextCh]
+		nextIsFormat := isFormatCode(nextCh)
 		if !isAlphanumeric(nextCh) || nextIsFormat || isDigit(nextCh) {
 			t.pos++
 			return Token{Type: TokenFormat, Value: string(ch), Pos: startPos}, nil
@@ -386,11 +950,27 @@ func (t *Tokenizer) skipWhitespace() {
 	}
 }
 
-// scanNumber scans a number token.
+// scanNumber scans a number token, including a hexadecimal literal (e.g., 0x0A)
+// or an optional fractional part (e.g., 1.8) for float literals.
 func (t *Tokenizer) scanNumber(startPos int) (Token, error) {
+	if t.input[t.pos] == '0' && t.pos+2 < len(t.input) &&
+		(t.input[t.pos+1] == 'x' || t.input[t.pos+1] == 'X') && isHexDigit(t.input[t.pos+2]) {
+		t.pos += 2
+		for t.pos < len(t.input) && isHexDigit(t.input[t.pos]) {
+			t.pos++
+		}
+		return Token{Type: TokenNumber, Value: string(t.input[startPos:t.pos]), Pos: startPos}, nil
+	}
+
 	for t.pos < len(t.input) && isDigit(t.input[t.pos]) {
 		t.pos++
 	}
+	if t.pos+1 < len(t.input) && t.input[t.pos] == '.' && isDigit(t.input[t.pos+1]) {
+		t.pos++
+		for t.pos < len(t.input) && isDigit(t.input[t.pos]) {
+			t.pos++
+		}
+	}
 	return Token{Type: TokenNumber, Value: string(t.input[startPos:t.pos]), Pos: startPos}, nil
 }
 
@@ -402,6 +982,15 @@ func (t *Tokenizer) scanIdent(startPos int) (Token, error) {
 	return Token{Type: TokenIdent, Value: string(t.input[startPos:t.pos]), Pos: startPos}, nil
 }
 
+// rescanIdent re-scans the input from pos as a single identifier, for name
+// positions where a name starting with format code letters (e.g., "size") would
+// otherwise be split into format codes.
+func (t *Tokenizer) rescanIdent(pos int) Token {
+	t.pos = pos
+	tok, _ := t.scanIdent(pos)
+	return tok
+}
+
 // scanString scans a string literal token.
 func (t *Tokenizer) scanString(startPos int) (Token, error) {
 	t.pos++ // skip opening quote
@@ -462,6 +1051,11 @@ func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
+// isHexDigit returns true if ch is a hexadecimal digit (0-9, a-f, A-F).
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
 // isLetter returns true if ch is an ASCII letter (a-z, A-Z).
 // Intentionally ASCII-only for format string identifiers.
 func isLetter(ch rune) bool {
@@ -477,6 +1071,7 @@ func isAlphanumeric(ch rune) bool {
 type Parser struct {
 	tokenizer *Tokenizer
 	current   Token
+	opts      Options
 }
 
 // NewParser creates a new parser for the given input.
@@ -486,29 +1081,31 @@ func NewParser(input string) *Parser {
 	}
 }
 
-// ParseExpression parses an expression and returns the root AST node.
-// Grammar:
-//
-//	Expression  → Pipe
-//	Pipe        → Primary ('|' PipeRHS)*
-//	PipeRHS     → Object | WriteFunc
-//	Primary     → FunctionCall | FormatExpr
-//	FunctionCall→ IDENT '(' FormatExpr ')'
-//	WriteFunc   → 'write' '(' STRING ')'
-//	FormatExpr  → ByteOrder? (Count? FormatCode)+
-//	Object      → '{' FieldList '}'
-//	FieldList   → FieldItem (',' FieldItem)*
-//	FieldItem   → IndexField | NestedField
-//	IndexField  → NUMBER '->' IDENTIFIER
-//	NestedField → IDENTIFIER ':' Object
+// ParseExpression parses an expression and returns the root AST node. The
+// accepted syntax is described by Grammar.
 func ParseExpression(input string) (Node, error) {
+	return ParseExpressionWithOptions(input, Options{})
+}
+
+// ParseExpressionWithOptions parses an expression like ParseExpression, applying
+// the given options to the nodes it builds (e.g., the string length limit).
+func ParseExpressionWithOptions(input string, opts Options) (Node, error) {
 	p := NewParser(input)
+	p.opts = opts
 	if err := p.advance(); err != nil {
 		return nil, err
 	}
 	return p.parseExpression()
 }
 
+// maxStringLen returns the effective string length limit of the options.
+func (p *Parser) maxStringLen() int {
+	if p.opts.MaxStringLen <= 0 {
+		return DefaultMaxStringLen
+	}
+	return p.opts.MaxStringLen
+}
+
 // advance moves to the next token.
 func (p *Parser) advance() error {
 	tok, err := p.tokenizer.Next()
@@ -519,33 +1116,50 @@ func (p *Parser) advance() error {
 	return nil
 }
 
+// rescanName re-tokenizes a format code token in a name position as the whole
+// identifier it starts, e.g. "size" instead of the codes 's' and 'i'.
+func (p *Parser) rescanName() {
+	if p.current.Type == TokenFormat {
+		p.current = p.tokenizer.rescanIdent(p.current.Pos)
+	}
+}
+
+// rescanFunction re-tokenizes a format code token as an identifier if it starts
+// one of the given function names, leaving the format code untouched otherwise.
+func (p *Parser) rescanFunction(names map[string]bool) {
+	if p.current.Type != TokenFormat {
+		return
+	}
+	savedPos := p.tokenizer.pos
+	if tok := p.tokenizer.rescanIdent(p.current.Pos); names[tok.Value] {
+		p.current = tok
+		return
+	}
+	p.tokenizer.pos = savedPos
+}
+
 // parseExpression parses the top-level expression (Pipe rule).
 func (p *Parser) parseExpression() (Node, error) {
 	return p.parsePipe()
 }
 
-// parsePipe parses: Primary ('|' PipeRHS)*
+// parsePipe parses: Primary ('|' PipeRHS | StringArrayFunc)*
 func (p *Parser) parsePipe() (Node, error) {
 	left, err := p.parsePrimary()
 	if err != nil {
 		return nil, err
 	}
 
-	// Allow chaining multiple pipe operations
-	for p.current.Type == TokenPipe {
-		if err := p.advance(); err != nil {
-			return nil, err
+	// Allow chaining multiple pipe operations. A string_array() may follow the
+	// format of its count without a pipe, as in `<H string_array([0])`.
+	for p.current.Type == TokenPipe || p.current.Type == TokenIdent && p.current.Value == "string_array" {
+		if p.current.Type == TokenPipe {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
 		}
 
-		var right Node
-		// Check if it's a write function
-		if p.current.Type == TokenIdent && p.current.Value == "write" {
-			right, err = p.parseWriteFunc()
-		} else if p.current.Type == TokenLBrace {
-			right, err = p.parseObject()
-		} else {
-			return nil, fmt.Errorf("expected '{' or 'write' after pipe at position %d, got %q", p.current.Pos, p.current.Value)
-		}
+		right, err := p.parsePipeRHS()
 		if err != nil {
 			return nil, err
 		}
@@ -556,37 +1170,295 @@ func (p *Parser) parsePipe() (Node, error) {
 	return left, nil
 }
 
-// parseWriteFunc parses: 'write' '(' STRING ')'
-func (p *Parser) parseWriteFunc() (Node, error) {
-	if p.current.Value != "write" {
-		return nil, fmt.Errorf("expected 'write' at position %d", p.current.Pos)
+// pipeFunctions lists the functions which may appear on the right side of a pipe.
+var pipeFunctions = map[string]bool{
+	"write":        true,
+	"mark":         true,
+	"fields":       true,
+	"setbits":      true,
+	"reparse":      true,
+	"string_array": true,
+	"sample":       true,
+	"rename":       true,
+	"drop":         true,
+	"repeat_prev":  true,
+	"duration":     true,
+	"where":        true,
+	"bias":         true,
+	"version":      true,
+	"byteswap":     true,
+	"extract":      true,
+	"signext":      true,
+	"checksum":     true,
+	"fanout":       true,
+	"json":         true,
+	"flagset":      true,
+	"follow":       true,
+	"hexdump":      true,
+	"slice":        true,
+	"table":        true,
+	"at":           true,
+}
+
+// parsePipeRHS parses: Object | WriteFunc | MarkFunc | FieldsFunc | SetBitsFunc | ReparseFunc
+// | StringArrayFunc | SampleFunc | RenameFunc | DropFunc | RepeatPrevFunc | DurationFunc
+// | WhereFunc | BiasFunc | VersionFunc | ByteSwapFunc | ExtractFunc | SignExtFunc
+// | ChecksumFunc | FanoutFunc | JSONFunc | FlagSetFunc | FollowFunc | HexdumpFunc
+// | SliceFunc | TableFunc | AtFunc
+func (p *Parser) parsePipeRHS() (Node, error) {
+	p.rescanFunction(pipeFunctions)
+	switch {
+	case p.current.Type == TokenLBrace:
+		node, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		// Nested objects count towards the indices of the outermost one
+		node.(*ObjectNode).WarnUnmapped = p.opts.WarnUnmapped
+		return node, nil
+	case p.current.Type == TokenIdent && p.current.Value == "write":
+		return p.parseWriteFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "mark":
+		return p.parseMarkFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "fields":
+		return p.parseFieldsFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "setbits":
+		return p.parseSetBitsFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "reparse":
+		return p.parseReparseFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "string_array":
+		return p.parseStringArrayFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "sample":
+		return p.parseSampleFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "rename":
+		return p.parseRenameFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "drop":
+		return p.parseDropFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "repeat_prev":
+		return p.parseRepeatPrevFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "duration":
+		return p.parseDurationFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "where":
+		return p.parseWhereFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "bias":
+		return p.parseBiasFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "version":
+		return p.parseVersionFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "byteswap":
+		return p.parseByteSwapFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "extract":
+		return p.parseExtractFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "signext":
+		return p.parseSignExtFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "checksum":
+		return p.parseChecksumFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "fanout":
+		return p.parseFanoutFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "json":
+		return p.parseJSONFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "flagset":
+		return p.parseFlagSetFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "follow":
+		return p.parseFollowFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "hexdump":
+		return p.parseHexdumpFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "slice":
+		return p.parseSliceFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "table":
+		return p.parseTableFunc()
+	case p.current.Type == TokenIdent && p.current.Value == "at":
+		return p.parseAtFunc()
+	case p.isSourceStart():
+		return nil, fmt.Errorf("unexpected format expression after pipe at position %d: "+
+			"sources must come first, e.g. '<bH | {0 -> a, 1 -> b}'", p.current.Pos)
+	default:
+		return nil, fmt.Errorf("expected '{' or 'write' after pipe at position %d, got %q", p.current.Pos, p.current.Value)
 	}
+}
+
+// parseFieldsFunc parses: 'fields' '(' NUMBER (',' NUMBER)* ')'
+func (p *Parser) parseFieldsFunc() (Node, error) {
 	if err := p.advance(); err != nil {
 		return nil, err
 	}
+	if err := p.expect(TokenLParen, "'(' after 'fields'"); err != nil {
+		return nil, err
+	}
 
-	// Consume '('
-	if p.current.Type != TokenLParen {
-		return nil, fmt.Errorf("expected '(' after 'write' at position %d", p.current.Pos)
+	node := &FieldsNode{}
+	for {
+		idx, err := p.parseInt("field index")
+		if err != nil {
+			return nil, err
+		}
+		node.Indices = append(node.Indices, idx)
+
+		if p.current.Type != TokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
 	}
-	if err := p.advance(); err != nil {
+
+	if err := p.expect(TokenRParen, "')' after field indices"); err != nil {
 		return nil, err
 	}
+	return node, nil
+}
 
-	// Expect string literal for file path
-	if p.current.Type != TokenString {
-		return nil, fmt.Errorf("expected file path string at position %d, got %q", p.current.Pos, p.current.Value)
-	}
-	path := p.current.Value
+// parseSliceFunc parses: 'slice' '(' '-'? NUMBER ',' '-'? NUMBER ')'
+func (p *Parser) parseSliceFunc() (Node, error) {
 	if err := p.advance(); err != nil {
 		return nil, err
 	}
+	if err := p.expect(TokenLParen, "'(' after 'slice'"); err != nil {
+		return nil, err
+	}
+
+	node := &SliceNode{}
+	for i, arg := range []struct {
+		dst  *int
+		what string
+	}{
+		{&node.Start, "slice start"},
+		{&node.End, "slice end"},
+	} {
+		if i > 0 {
+			if err := p.expect(TokenComma, "',' before the "+arg.what); err != nil {
+				return nil, err
+			}
+		}
+		sign := 1
+		if p.current.Type == TokenMinus {
+			sign = -1
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		idx, err := p.parseInt(arg.what)
+		if err != nil {
+			return nil, err
+		}
+		*arg.dst = sign * idx
+	}
+
+	if err := p.expect(TokenRParen, "')' after slice range"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseRenameFunc parses: 'rename' '(' IDENTIFIER ',' IDENTIFIER ')'
+func (p *Parser) parseRenameFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'rename'"); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, 2)
+	for _, what := range []string{"field name to rename", "new field name"} {
+		if len(names) > 0 {
+			if err := p.expect(TokenComma, "',' after the field name to rename"); err != nil {
+				return nil, err
+			}
+		}
+		p.rescanName()
+		if p.current.Type != TokenIdent {
+			return nil, fmt.Errorf("expected %s at position %d, got %q", what, p.current.Pos, p.current.Value)
+		}
+		names = append(names, p.current.Value)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after the new field name"); err != nil {
+		return nil, err
+	}
+	return &RenameNode{Old: names[0], New: names[1]}, nil
+}
+
+// parseDropFunc parses: 'drop' '(' IDENTIFIER (',' IDENTIFIER)* ')'
+func (p *Parser) parseDropFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'drop'"); err != nil {
+		return nil, err
+	}
+
+	node := &DropNode{}
+	for {
+		p.rescanName()
+		if p.current.Type != TokenIdent {
+			return nil, fmt.Errorf("expected field name to drop at position %d, got %q", p.current.Pos, p.current.Value)
+		}
+		node.Names = append(node.Names, p.current.Value)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		if p.current.Type != TokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after field names to drop"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseStringArgFunc parses: IDENT '(' STRING ')' and returns the string argument.
+// The what argument describes the expected string in error messages.
+func (p *Parser) parseStringArgFunc(what string) (string, error) {
+	funcName := p.current.Value
+	if err := p.advance(); err != nil {
+		return "", err
+	}
+
+	// Consume '('
+	if p.current.Type != TokenLParen {
+		return "", fmt.Errorf("expected '(' after '%s' at position %d", funcName, p.current.Pos)
+	}
+	if err := p.advance(); err != nil {
+		return "", err
+	}
+
+	// Expect string literal
+	if p.current.Type != TokenString {
+		return "", fmt.Errorf("expected %s string at position %d, got %q", what, p.current.Pos, p.current.Value)
+	}
+	arg := p.current.Value
+	if err := p.advance(); err != nil {
+		return "", err
+	}
 
 	// Consume ')'
 	if p.current.Type != TokenRParen {
-		return nil, fmt.Errorf("expected ')' after file path at position %d", p.current.Pos)
+		return "", fmt.Errorf("expected ')' after %s at position %d", what, p.current.Pos)
 	}
 	if err := p.advance(); err != nil {
+		return "", err
+	}
+
+	return arg, nil
+}
+
+// parseWriteFunc parses: 'write' '(' STRING ')'
+func (p *Parser) parseWriteFunc() (Node, error) {
+	if p.current.Value != "write" {
+		return nil, fmt.Errorf("expected 'write' at position %d", p.current.Pos)
+	}
+
+	path, err := p.parseStringArgFunc("file path")
+	if err != nil {
 		return nil, err
 	}
 
@@ -596,8 +1468,79 @@ func (p *Parser) parseWriteFunc() (Node, error) {
 	}, nil
 }
 
+// parseMarkFunc parses: 'mark' '(' STRING ')'
+func (p *Parser) parseMarkFunc() (Node, error) {
+	name, err := p.parseStringArgFunc("mark name")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("mark name must not be empty at position %d", p.current.Pos)
+	}
+
+	return &MarkNode{Name: name}, nil
+}
+
+// isSourceStart reports whether the current token starts a source (an expression
+// reading from the input), i.e., a format expression, a search, or parse().
+func (p *Parser) isSourceStart() bool {
+	switch p.current.Type {
+	case TokenFormat, TokenOrder, TokenNumber, TokenStar, TokenQuestion:
+		return true
+	case TokenIdent:
+		return sourceFunctions[p.current.Value]
+	default:
+		return false
+	}
+}
+
+// sourceFunctions lists the functions which may start an expression.
+var sourceFunctions = map[string]bool{
+	"parse":      true,
+	"rle":        true,
+	"patch":      true,
+	"split":      true,
+	"pb":         true,
+	"name":       true,
+	"until_zero": true,
+	"stride":     true,
+	"utf8len":    true,
+	"bitmap":     true,
+	"utf16":      true,
+	"vlq":        true,
+	"union":      true,
+	"od":         true,
+	"atoi":       true,
+	"offsets":    true,
+	"seek":       true,
+	"repeat":     true,
+	"assert":     true,
+}
+
+// isSinkStart reports whether the current token starts a transform or sink,
+// which may only appear on the right of a pipe.
+func (p *Parser) isSinkStart() bool {
+	switch p.current.Type {
+	case TokenLBrace:
+		return true
+	case TokenIdent:
+		return pipeFunctions[p.current.Value]
+	default:
+		return false
+	}
+}
+
 // parsePrimary parses: FunctionCall | FormatExpr
 func (p *Parser) parsePrimary() (Node, error) {
+	p.rescanFunction(sourceFunctions)
+	p.rescanFunction(pipeFunctions)
+
+	// Objects and sinks transform a source, so they cannot start an expression
+	if p.isSinkStart() {
+		return nil, fmt.Errorf("unexpected %q at position %d: objects and write() must follow a format "+
+			"expression on the right of a pipe, e.g. '<bH | {0 -> a, 1 -> b}'", p.current.Value, p.current.Pos)
+	}
+
 	// Check for search expression: '?' STRING
 	if p.current.Type == TokenQuestion {
 		return p.parseSearchExpr()
@@ -637,10 +1580,55 @@ func (p *Parser) parseSearchExpr() (Node, error) {
 	return &SearchNode{Pattern: pattern}, nil
 }
 
-// parseFunctionCall parses: IDENT '(' FormatExpr ')'
-// Currently only supports the 'parse' function.
+// parseFunctionCall parses: IDENT '(' ... ')' for the functions which may start
+// an expression, dispatching on the function name.
 func (p *Parser) parseFunctionCall() (Node, error) {
-	funcName := p.current.Value
+	switch p.current.Value {
+	case "parse":
+		return p.parseParseFunc()
+	case "rle":
+		return p.parseRleFunc()
+	case "patch":
+		return p.parsePatchFunc()
+	case "split":
+		return p.parseSplitFunc()
+	case "pb":
+		return p.parsePbFunc()
+	case "name":
+		return p.parseNameFunc()
+	case "until_zero":
+		return p.parseUntilZeroFunc()
+	case "stride":
+		return p.parseStrideFunc()
+	case "utf8len":
+		return p.parseUtf8LenFunc()
+	case "bitmap":
+		return p.parseBitmapFunc()
+	case "utf16":
+		return p.parseUtf16Func()
+	case "vlq":
+		return p.parseVlqFunc()
+	case "union":
+		return p.parseUnionFunc()
+	case "od":
+		return p.parseOdFunc()
+	case "atoi":
+		return p.parseAtoiFunc()
+	case "offsets":
+		return p.parseOffsetsFunc()
+	case "seek":
+		return p.parseSeekFunc()
+	case "repeat":
+		return p.parseRepeatFunc()
+	case "assert":
+		return p.parseAssertFunc()
+	default:
+		return nil, fmt.Errorf("unknown function %q at position %d", p.current.Value, p.current.Pos)
+	}
+}
+
+// parseParseFunc parses: 'parse' '(' FormatExpr ')'
+func (p *Parser) parseParseFunc() (Node, error) {
 	if err := p.advance(); err != nil {
 		return nil, err
 	}
@@ -667,45 +1655,114 @@ func (p *Parser) parseFunctionCall() (Node, error) {
 		return nil, err
 	}
 
-	// Handle known functions
-	switch funcName {
-	case "parse":
-		// parse() just returns the format expression as-is
-		return arg, nil
-	default:
-		return nil, fmt.Errorf("unknown function %q at position %d", funcName, p.current.Pos)
+	// parse() just returns the format expression as-is
+	return arg, nil
+}
+
+// expect consumes the current token if it has the given type, or returns an
+// error describing what was expected.
+func (p *Parser) expect(tokType TokenType, what string) error {
+	if p.current.Type != tokType {
+		return fmt.Errorf("expected %s at position %d, got %q", what, p.current.Pos, p.current.Value)
+	}
+	return p.advance()
+}
+
+// parseInt consumes a NUMBER token and returns its value.
+func (p *Parser) parseInt(what string) (int, error) {
+	if p.current.Type != TokenNumber {
+		return 0, fmt.Errorf("expected %s at position %d, got %q", what, p.current.Pos, p.current.Value)
+	}
+	digits, base := splitNumberBase(p.current.Value)
+	n, err := strconv.ParseInt(digits, base, 0)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", what, p.current.Value, err)
+	}
+	return int(n), p.advance()
+}
+
+// splitNumberBase returns the digits and base of an integer literal, accepting a
+// 0x prefix for hexadecimal (e.g., 0x0A).
+func splitNumberBase(literal string) (string, int) {
+	if len(literal) > 2 && (literal[:2] == "0x" || literal[:2] == "0X") {
+		return literal[2:], 16
+	}
+	return literal, 10
+}
+
+// parseSingleFormat parses a format expression holding exactly one format code
+// without a count (e.g., <H), as used by function arguments.
+func (p *Parser) parseSingleFormat(what string) (FormatCode, error) {
+	pos := p.current.Pos
+	node, err := p.parseFormatExpr()
+	if err != nil {
+		return FormatCode{}, fmt.Errorf("%s: %w", what, err)
 	}
+
+	formats := node.(*FormatNode).Formats
+	if len(formats) != 1 || formats[0].Count != 1 || formats[0].isPad() || formats[0].Bits > 0 {
+		return FormatCode{}, fmt.Errorf("%s at position %d must be a single format code", what, pos)
+	}
+	return formats[0], nil
 }
 
-// parseFormatExpr parses: ByteOrder? (Count? FormatCode)+
-// Count is an optional digit prefix for arrays, e.g., 4B means 4 unsigned chars.
+// parseFormatExpr parses: ByteOrder? (ByteOrder? Count? FormatCode ('/' NUMBER)? (':' IDENTIFIER)?)+
+// Count is an optional digit prefix for arrays, e.g., 4B means 4 unsigned chars,
+// or the number of padding bytes skipped by x, e.g., 4x.
+// An optional '/bits' suffix reads a bit-field, e.g., B/3 B/5 splits a byte.
+// An optional ':name' suffix binds a field name directly to the code, e.g., <b:key H:value.
+// A byte order may also appear between format codes, switching the order for
+// the subsequent codes, e.g., <H>I reads a little-endian H then a big-endian I.
 func (p *Parser) parseFormatExpr() (Node, error) {
 	expr := &Expr{
-		Order:   NativeOrder,
-		Formats: make([]FormatCode, 0),
+		Order:        NativeOrder,
+		Formats:      make([]FormatCode, 0),
+		MaxStringLen: p.opts.MaxStringLen,
+		NoTrim:       p.opts.NoTrim,
+		TrimSet:      p.opts.TrimSet,
 	}
 
 	// Check for byte order prefix
 	if p.current.Type == TokenOrder {
-		switch p.current.Value {
-		case "<":
-			expr.Order = LittleEndian
-		case ">":
-			expr.Order = BigEndian
-		case "@":
-			expr.Order = NativeOrder
-		}
+		expr.Order = parseByteOrder(p.current.Value)
 		if err := p.advance(); err != nil {
 			return nil, err
 		}
 	}
+	order := expr.Order
+
+	// Parse format codes with optional count prefix and inline byte order switches
+	for p.current.Type == TokenFormat || p.current.Type == TokenNumber || p.current.Type == TokenOrder || p.current.Type == TokenStar {
+		count, rest := 1, false
+
+		// Switch the byte order for the subsequent codes
+		if p.current.Type == TokenOrder {
+			order = parseByteOrder(p.current.Value)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			// After a byte order, we must have a format code
+			if p.current.Type != TokenFormat && p.current.Type != TokenNumber && p.current.Type != TokenStar {
+				return nil, fmt.Errorf("expected format code after byte order at position %d", p.current.Pos)
+			}
+			continue
+		}
 
-	// Parse format codes with optional count prefix
-	for p.current.Type == TokenFormat || p.current.Type == TokenNumber {
-		count := 1
+		// Trailing codes would never get data after a code reading until EOF
+		if len(expr.Formats) > 0 && expr.Formats[len(expr.Formats)-1].Rest {
+			return nil, fmt.Errorf("format code with a '*' count must be the last one, got more codes at position %d", p.current.Pos)
+		}
 
-		// Check for count prefix (e.g., 4B means 4 unsigned chars)
-		if p.current.Type == TokenNumber {
+		// Check for count prefix (e.g., 4B means 4 unsigned chars, *B reads until EOF)
+		if p.current.Type == TokenStar {
+			rest = true
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.current.Type != TokenFormat {
+				return nil, fmt.Errorf("expected format code after '*' at position %d", p.current.Pos)
+			}
+		} else if p.current.Type == TokenNumber {
 			var err error
 			count, err = strconv.Atoi(p.current.Value)
 			if err != nil {
@@ -723,17 +1780,62 @@ func (p *Parser) parseFormatExpr() (Node, error) {
 			}
 		}
 
-		code := rune(p.current.Value[0])
+		code := p.resolveCode(rune(p.current.Value[0]))
 		info := formatCodeRegistry[code]
-		expr.Formats = append(expr.Formats, FormatCode{
-			Code:   code,
-			Size:   info.size,
-			Signed: info.signed,
-			Count:  count,
-		})
+		if rest && (info.size == 0 || code == 'x') {
+			return nil, fmt.Errorf("'*' count at position %d needs a fixed-size value code, got %c", p.current.Pos, code)
+		}
+		pos := p.current.Pos
+		if code == 's' && count > p.maxStringLen() {
+			return nil, fmt.Errorf("fixed-length string at position %d exceeds limit of %d bytes, got %d", pos, p.maxStringLen(), count)
+		}
 		if err := p.advance(); err != nil {
 			return nil, err
 		}
+
+		// Check for a bit width (e.g., B/3), sharing the unit of the previous bit-field
+		bits, bitOffset := 0, 0
+		if p.current.Type == TokenSlash {
+			if count > 1 || rest {
+				return nil, fmt.Errorf("bit-field %c at position %d cannot have a count", code, pos)
+			}
+			var err error
+			if bits, err = p.parseBitWidth(code, info.size); err != nil {
+				return nil, err
+			}
+			bitOffset = nextBitOffset(expr.Formats, code, order, bits)
+		}
+
+		// Check for an inline field name (e.g., b:key)
+		name := ""
+		if p.current.Type == TokenColon {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			p.rescanName()
+			if p.current.Type != TokenIdent {
+				return nil, fmt.Errorf("expected field name after ':' at position %d, got %q", p.current.Pos, p.current.Value)
+			}
+			if code == 'x' {
+				return nil, fmt.Errorf("padding code x at position %d has no value to name", p.current.Pos)
+			}
+			name = p.current.Value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+
+		expr.Formats = append(expr.Formats, FormatCode{
+			Code:      code,
+			Size:      info.size,
+			Signed:    info.signed,
+			Count:     count,
+			Rest:      rest,
+			Bits:      bits,
+			BitOffset: bitOffset,
+			Order:     order,
+			Name:      name,
+		})
 	}
 
 	if len(expr.Formats) == 0 {
@@ -743,7 +1845,19 @@ func (p *Parser) parseFormatExpr() (Node, error) {
 	return &FormatNode{Expr: expr}, nil
 }
 
-// parseObject parses: '{' FieldList '}'
+// parseByteOrder converts a byte order token value into a ByteOrder.
+func parseByteOrder(value string) ByteOrder {
+	switch value {
+	case "<":
+		return LittleEndian
+	case ">":
+		return BigEndian
+	default:
+		return NativeOrder
+	}
+}
+
+// parseObject parses: '{' FieldList? '}'
 func (p *Parser) parseObject() (Node, error) {
 	if p.current.Type != TokenLBrace {
 		return nil, fmt.Errorf("expected '{' at position %d, got %q", p.current.Pos, p.current.Value)
@@ -752,9 +1866,13 @@ func (p *Parser) parseObject() (Node, error) {
 		return nil, err
 	}
 
-	fields, err := p.parseFieldList()
-	if err != nil {
-		return nil, err
+	// An empty object has no fields
+	fields := []FieldDef{}
+	if p.current.Type != TokenRBrace {
+		var err error
+		if fields, err = p.parseFieldList(); err != nil {
+			return nil, err
+		}
 	}
 
 	if p.current.Type != TokenRBrace {
@@ -798,8 +1916,9 @@ func (p *Parser) parseFieldList() ([]FieldDef, error) {
 // NestedField → IDENTIFIER ':' Object
 // Note: Nested field names can also be format code characters.
 func (p *Parser) parseFieldItem() (FieldDef, error) {
-	// Check if it's a nested field (starts with identifier or format code followed by ':')
-	if p.current.Type == TokenIdent || p.current.Type == TokenFormat {
+	// Check if it's a nested field (starts with identifier followed by ':')
+	p.rescanName()
+	if p.current.Type == TokenIdent {
 		// Peek to see if next token is ':'
 		nextTok, err := p.tokenizer.Peek()
 		if err != nil {
@@ -814,9 +1933,9 @@ func (p *Parser) parseFieldItem() (FieldDef, error) {
 	return p.parseIndexField()
 }
 
-// parseIndexField parses: NUMBER '->' IDENTIFIER
-// Note: Field names can also be format code characters (b, B, h, H, i, I, q, Q),
-// which the tokenizer may classify as TokenFormat instead of TokenIdent.
+// parseIndexField parses: NUMBER '?'? '->' IDENTIFIER
+// Note: Field names can also start with format code characters (e.g., 'b' or
+// 'size'), which the tokenizer classifies as TokenFormat and are re-scanned.
 func (p *Parser) parseIndexField() (FieldDef, error) {
 	if p.current.Type != TokenNumber {
 		return FieldDef{}, fmt.Errorf("expected index number at position %d, got %q", p.current.Pos, p.current.Value)
@@ -830,6 +1949,13 @@ func (p *Parser) parseIndexField() (FieldDef, error) {
 		return FieldDef{}, err
 	}
 
+	optional := p.current.Type == TokenQuestion
+	if optional {
+		if err := p.advance(); err != nil {
+			return FieldDef{}, err
+		}
+	}
+
 	if p.current.Type != TokenArrow {
 		return FieldDef{}, fmt.Errorf("expected '->' at position %d, got %q", p.current.Pos, p.current.Value)
 	}
@@ -837,9 +1963,8 @@ func (p *Parser) parseIndexField() (FieldDef, error) {
 		return FieldDef{}, err
 	}
 
-	// Accept both TokenIdent and TokenFormat as field names
-	// (format code characters like 'b', 'H' can be valid field names)
-	if p.current.Type != TokenIdent && p.current.Type != TokenFormat {
+	p.rescanName()
+	if p.current.Type != TokenIdent {
 		return FieldDef{}, fmt.Errorf("expected field name at position %d, got %q", p.current.Pos, p.current.Value)
 	}
 
@@ -848,14 +1973,36 @@ func (p *Parser) parseIndexField() (FieldDef, error) {
 		return FieldDef{}, err
 	}
 
-	return FieldDef{Index: index, Name: name}, nil
+	hint, err := p.parseRenderHint()
+	if err != nil {
+		return FieldDef{}, err
+	}
+
+	return FieldDef{Index: index, Name: name, Optional: optional, Hint: hint}, nil
+}
+
+// parseRenderHint parses an optional render hint: ('#' ('hex' | 'dec'))?
+func (p *Parser) parseRenderHint() (string, error) {
+	if p.current.Type != TokenHash {
+		return "", nil
+	}
+	if err := p.advance(); err != nil {
+		return "", err
+	}
+
+	p.rescanName()
+	hint := p.current.Value
+	if p.current.Type != TokenIdent || (hint != HintHex && hint != HintDec) {
+		return "", fmt.Errorf("expected render hint 'hex' or 'dec' after '#' at position %d, got %q", p.current.Pos, hint)
+	}
+	return hint, p.advance()
 }
 
 // parseNestedField parses: IDENTIFIER ':' Object
 // Note: Nested field names can also be format code characters.
 func (p *Parser) parseNestedField() (FieldDef, error) {
-	// Accept both TokenIdent and TokenFormat as nested field names
-	if p.current.Type != TokenIdent && p.current.Type != TokenFormat {
+	p.rescanName()
+	if p.current.Type != TokenIdent {
 		return FieldDef{}, fmt.Errorf("expected nested field name at position %d, got %q", p.current.Pos, p.current.Value)
 	}
 
@@ -871,6 +2018,19 @@ func (p *Parser) parseNestedField() (FieldDef, error) {
 		return FieldDef{}, err
 	}
 
+	// Anything but an object is a computed value
+	if p.current.Type != TokenLBrace {
+		compute, err := p.parseArith()
+		if err != nil {
+			return FieldDef{}, fmt.Errorf("computed field %q: %w", name, err)
+		}
+		hint, err := p.parseRenderHint()
+		if err != nil {
+			return FieldDef{}, err
+		}
+		return FieldDef{Name: name, Compute: compute, Hint: hint}, nil
+	}
+
 	// Parse the nested object
 	nestedNode, err := p.parseObject()
 	if err != nil {
@@ -905,18 +2065,76 @@ type FormatCode struct {
 	Size int
 	// Signed indicates whether the value is signed.
 	Signed bool
-	// Count is the number of elements to read (1 for single value, >1 for array).
+	// Count is the number of elements to read (1 for single value, >1 for array),
+	// or the width in bytes of a fixed-length string (e.g., 16s).
 	Count int
+	// Rest reads the elements of an array until the input ends (e.g., *B),
+	// instead of Count elements.
+	Rest bool
+	// Bits is the width of a bit-field (e.g., B/3), 0 for a whole value.
+	Bits int
+	// BitOffset is the offset of a bit-field from the most significant bit of the
+	// unit it shares with the preceding bit-fields; a bit-field at offset 0 starts
+	// a new unit.
+	BitOffset int
+	// Order is the resolved byte order for this code (from the leading or the
+	// most recent inline byte order).
+	Order ByteOrder
+	// Name is the optional inline field name (e.g., b:key), empty if unnamed.
+	Name string
+}
+
+// isPad returns true if the code skips padding bytes without producing a value.
+func (fc *FormatCode) isPad() bool {
+	return fc.Code == 'x'
+}
+
+// fixedString returns true if the code reads a fixed-length string of Count bytes
+// rather than a null-terminated one.
+func (fc *FormatCode) fixedString() bool {
+	return fc.Code == 's' && fc.Count > 1
+}
+
+// byteSize returns the number of bytes the code reads and true, or false for a
+// null-terminated string whose size is only known once read.
+func (fc *FormatCode) byteSize() (int, bool) {
+	if fc.Rest {
+		return 0, false
+	}
+	if fc.Bits > 0 && fc.BitOffset > 0 {
+		// The unit was read by the first bit-field
+		return 0, true
+	}
+	if fc.fixedString() {
+		return fc.Count, true
+	}
+	if fc.Size == 0 {
+		return 0, false
+	}
+	return fc.Size * max(fc.Count, 1), true
 }
 
 // Expr represents a parsed binary format expression.
 type Expr struct {
-	// Order is the byte order for reading binary data.
+	// Order is the leading byte order of the expression; each format code carries
+	// its own resolved order for reading.
 	Order ByteOrder
 	// Formats is the list of format codes to apply.
 	Formats []FormatCode
+	// MaxStringLen caps the bytes read for a null-terminated string (0 uses DefaultMaxStringLen).
+	MaxStringLen int
+	// NoTrim keeps the trailing NULs of a fixed-length string, so the value has
+	// the raw width of the field and shows its padding.
+	NoTrim bool
+	// TrimSet holds the characters trimmed from the end of a fixed-length string
+	// along with the NULs, such as a space for fields padded with spaces.
+	TrimSet string
 }
 
+// DefaultMaxStringLen is the default cap on the bytes read for a null-terminated string,
+// which protects against runaway reads on malformed data without a terminator.
+const DefaultMaxStringLen = 64 * 1024
+
 // formatCodeMeta holds metadata for each format code.
 type formatCodeMeta struct {
 	size     int    // byte size (0 for variable-length types)
@@ -927,28 +2145,78 @@ type formatCodeMeta struct {
 // formatCodeRegistry maps format codes to their metadata.
 // Size of 0 indicates variable-length type (e.g., null-terminated string).
 var formatCodeRegistry = map[rune]formatCodeMeta{
-	'b': {1, true, "int8"},    // signed char
-	'B': {1, false, "uint8"},  // unsigned char
-	'h': {2, true, "int16"},   // signed short
-	'H': {2, false, "uint16"}, // unsigned short
-	'i': {4, true, "int32"},   // signed int
-	'I': {4, false, "uint32"}, // unsigned int
-	'q': {8, true, "int64"},   // signed long
-	'Q': {8, false, "uint64"}, // unsigned long
-	's': {0, false, "string"}, // null-terminated string
+	'b': {1, true, "int8"},      // signed char
+	'B': {1, false, "uint8"},    // unsigned char
+	'h': {2, true, "int16"},     // signed short
+	'H': {2, false, "uint16"},   // unsigned short
+	'i': {4, true, "int32"},     // signed int
+	'I': {4, false, "uint32"},   // unsigned int
+	'q': {8, true, "int64"},     // signed long
+	'Q': {8, false, "uint64"},   // unsigned long
+	'o': {16, true, "int128"},   // signed 128-bit integer
+	'O': {16, false, "uint128"}, // unsigned 128-bit integer
+	'f': {4, true, "float32"},   // IEEE-754 single precision
+	'd': {8, true, "float64"},   // IEEE-754 double precision
+	's': {0, false, "string"},   // null-terminated string
+	'x': {1, false, "pad"},      // padding byte, read without a value
+}
+
+// formatCodeAliases maps alternate format codes to their canonical codes, easing
+// the porting of format strings from C-centric sources. The 'l'/'L' aliases follow
+// the 32-bit C long by default; Options.CLong switches them to 64-bit (LP64).
+var formatCodeAliases = map[rune]rune{
+	'l': 'i', // signed long
+	'L': 'I', // unsigned long
+}
+
+// lp64CodeAliases overrides the aliases for LP64 semantics (Options.CLong).
+var lp64CodeAliases = map[rune]rune{
+	'l': 'q', // signed long
+	'L': 'Q', // unsigned long
+}
+
+// isFormatCode returns true if ch is a canonical format code or an alias.
+func isFormatCode(ch rune) bool {
+	if _, ok := formatCodeRegistry[ch]; ok {
+		return true
+	}
+	_, ok := formatCodeAliases[ch]
+	return ok
+}
+
+// resolveCode resolves a format code alias into its canonical code.
+func (p *Parser) resolveCode(code rune) rune {
+	if p.opts.CLong {
+		if canonical, ok := lp64CodeAliases[code]; ok {
+			return canonical
+		}
+	}
+	if canonical, ok := formatCodeAliases[code]; ok {
+		return canonical
+	}
+	return code
 }
 
 // Parse parses a format string and returns an Expr.
 // The format string consists of an optional byte order prefix followed by format codes.
-// Byte order prefixes: '<' (little-endian), '>' (big-endian), '@' (native)
-// Format codes: b, B, h, H, i, I, q, Q
-// Count prefix: optional digit(s) before format code, e.g., 4B means 4 unsigned chars
+// Byte order prefixes: '<' (little-endian), '>' (big-endian), '@' or '=' (native).
+// Every prefix uses the same fixed sizes and no alignment padding, since Go's
+// types are fixed-width: the prefixes differ only in byte order.
+// Format codes: b, B, h, H, i, I, q, Q, o, O, f, d
+// Count prefix: optional digit(s) before format code, e.g., 4B means 4 unsigned chars,
+// while 16s means a single fixed-length string of 16 bytes
 func Parse(format string) (*Expr, error) {
+	return ParseWithOptions(format, Options{})
+}
+
+// ParseWithOptions parses a format string like Parse, using the parse options
+// such as CLong and MaxStringLen.
+func ParseWithOptions(format string, opts Options) (*Expr, error) {
 	if len(format) == 0 {
 		return nil, fmt.Errorf("empty format string")
 	}
 
-	node, err := ParseExpression(format)
+	node, err := ParseExpressionWithOptions(format, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -963,54 +2231,190 @@ func Parse(format string) (*Expr, error) {
 
 // Read reads binary data from the reader and returns the parsed values.
 // For format codes with Count > 1, returns a typed slice (e.g., []int8 for 4b).
+//
+// Errors wrap the underlying read error, so callers can tell a clean end of the
+// stream from truncation: errors.Is(err, io.EOF) holds only when no byte of the
+// record was read, while a record cut short matches io.ErrUnexpectedEOF.
 func (e *Expr) Read(r io.Reader) ([]any, error) {
-	order := e.binaryOrder()
-	values := make([]any, 0, len(e.Formats))
+	return e.ReadInto(r, make([]any, 0, len(e.Formats)))
+}
 
-	for _, fc := range e.Formats {
-		count := fc.Count
-		if count == 0 {
-			count = 1 // default for backward compatibility
-		}
+// ReadInto reads binary data like Read, but stores the parsed values into dst
+// (reusing its backing array and growing it if needed) to avoid allocating a new
+// slice per record. The returned slice must be used instead of dst.
+func (e *Expr) ReadInto(r io.Reader, dst []any) ([]any, error) {
+	values := dst[:0]
 
-		// Handle null-terminated string specially
-		if fc.Code == 's' {
-			str, err := readNullTerminatedString(r)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read null-terminated string: %w", err)
+	var unit uint64 // bits of the unit shared by consecutive bit-fields
+	for i, fc := range e.Formats {
+		var val any
+		var err error
+		if fc.Bits > 0 {
+			val, err = fc.readBits(r, &unit)
+		} else {
+			val, err = e.readField(r, fc)
+		}
+		if err != nil {
+			// Only an EOF at the first field is a clean end of the stream
+			if i > 0 && errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("truncated record at field %d (format %c): %w", i, fc.Code, io.ErrUnexpectedEOF)
 			}
-			values = append(values, str)
-			continue
+			return nil, err
 		}
+		if !fc.isPad() {
+			values = append(values, val)
+		}
+	}
 
-		if count == 1 {
-			// Single value
-			buf := make([]byte, fc.Size)
-			if _, err := io.ReadFull(r, buf); err != nil {
-				return nil, fmt.Errorf("failed to read %d bytes for format %c: %w", fc.Size, fc.Code, err)
-			}
-			val, err := fc.decode(buf, order)
-			if err != nil {
-				return nil, err
+	return values, nil
+}
+
+// readField reads the value of a single format code.
+func (e *Expr) readField(r io.Reader, fc FormatCode) (any, error) {
+	count := fc.Count
+	if count == 0 {
+		count = 1 // default for backward compatibility
+	}
+
+	// Padding is skipped, leaving no value
+	if fc.isPad() {
+		if _, err := io.CopyN(io.Discard, r, int64(count)); err != nil {
+			if errors.Is(err, io.EOF) && count > 1 {
+				err = io.ErrUnexpectedEOF
 			}
-			values = append(values, val)
-		} else {
-			// Array of values
-			arr, err := fc.decodeArray(r, order, count)
-			if err != nil {
-				return nil, err
+			return nil, fmt.Errorf("failed to skip %d padding bytes: %w", count, err)
+		}
+		return nil, nil
+	}
+
+	// Handle strings specially: a count prefix gives a fixed width
+	if fc.fixedString() {
+		return readFixedString(r, count, e.trimSet())
+	}
+	if fc.Code == 's' {
+		str, err := readNullTerminatedString(r, e.maxStringLen())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read null-terminated string: %w", err)
+		}
+		return str, nil
+	}
+
+	if fc.Rest {
+		return fc.decodeRest(r)
+	}
+	if count > 1 {
+		// Array of values
+		return fc.decodeArray(r, count)
+	}
+
+	// Single value
+	buf := make([]byte, fc.Size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read %d bytes for format %c: %w", fc.Size, fc.Code, err)
+	}
+	return fc.decode(buf)
+}
+
+// ReadEach reads records from the reader until a clean EOF, invoking fn with the
+// values of each record. The slice passed to fn is reused for the next record, so
+// fn must copy it to keep the values. Reading stops at the first error returned by
+// fn, which is returned as is; an EOF in the middle of a record is an error. A
+// record reading no bytes, as one ending with a * count, ends the stream too.
+func (e *Expr) ReadEach(r io.Reader, fn func([]any) error) error {
+	if len(e.Formats) == 0 {
+		return fmt.Errorf("cannot read records of an empty format")
+	}
+
+	counter := &countingReader{r: r}
+	values := make([]any, 0, len(e.Formats))
+	for {
+		start := counter.Offset()
+
+		var err error
+		if values, err = e.ReadInto(counter, values); err != nil {
+			if errors.Is(err, io.EOF) && counter.Offset() == start {
+				return nil
 			}
-			values = append(values, arr)
+			return err
+		}
+
+		if counter.Offset() == start {
+			return nil
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+}
+
+// maxStringLen returns the effective null-terminated string length limit.
+func (e *Expr) maxStringLen() int {
+	if e.MaxStringLen <= 0 {
+		return DefaultMaxStringLen
+	}
+	return e.MaxStringLen
+}
+
+// valueFormats returns the format codes producing a value, in the order of the
+// values read: all codes but the padding, so value i comes from code i of them.
+func (e *Expr) valueFormats() []FormatCode {
+	formats := make([]FormatCode, 0, len(e.Formats))
+	for _, fc := range e.Formats {
+		if !fc.isPad() {
+			formats = append(formats, fc)
+		}
+	}
+	return formats
+}
+
+// readN reads exactly n bytes, growing the buffer as the bytes arrive rather than
+// allocating n bytes up front, so a huge size taken from the expression or the
+// input fails at the end of the input instead of exhausting the memory. A short
+// read is io.ErrUnexpectedEOF, or io.EOF if no byte was read.
+func readN(r io.Reader, n int64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) < n {
+		if len(buf) == 0 {
+			return nil, io.EOF
 		}
+		return nil, io.ErrUnexpectedEOF
 	}
+	return buf, nil
+}
 
-	return values, nil
+// trimSet returns the trailing characters trimmed from a fixed-length string.
+func (e *Expr) trimSet() string {
+	if e.NoTrim {
+		return ""
+	}
+	return "\x00" + e.TrimSet
+}
+
+// readFixedString reads a string of exactly width bytes, such as a name field
+// padded with NULs, and trims the trailing characters of the cutset.
+func readFixedString(r io.Reader, width int, cutset string) (string, error) {
+	buf := make([]byte, width)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", fmt.Errorf("failed to read %d bytes for fixed-length string: %w", width, err)
+	}
+	return string(bytes.TrimRight(buf, cutset)), nil
 }
 
 // readNullTerminatedString reads bytes from the reader until a null byte (0x00) is found.
 // Returns the string without the null terminator.
-// Returns an error if any non-printable character is encountered.
-func readNullTerminatedString(r io.Reader) (string, error) {
+// Returns an error if any non-printable character is encountered, or if more than
+// limit bytes are read without finding the terminator.
+func readNullTerminatedString(r io.Reader, limit int) (string, error) {
+	return readCString(r, limit, false)
+}
+
+// readCString reads a null-terminated string like readNullTerminatedString. If
+// strict, a string cut short by the end of input is io.ErrUnexpectedEOF instead
+// of being returned without its terminator.
+func readCString(r io.Reader, limit int, strict bool) (string, error) {
 	var buf []byte
 	b := make([]byte, 1)
 
@@ -1018,6 +2422,13 @@ func readNullTerminatedString(r io.Reader) (string, error) {
 		_, err := io.ReadFull(r, b)
 		if err != nil {
 			if err == io.EOF {
+				if len(buf) == 0 {
+					// Nothing left to read
+					return "", io.EOF
+				}
+				if strict {
+					return "", io.ErrUnexpectedEOF
+				}
 				// Validate and return what we have if EOF before null terminator
 				return validatePrintableString(buf)
 			}
@@ -1029,6 +2440,9 @@ func readNullTerminatedString(r io.Reader) (string, error) {
 			break
 		}
 
+		if len(buf) >= limit {
+			return "", fmt.Errorf("unterminated string exceeds limit of %d bytes", limit)
+		}
 		buf = append(buf, b[0])
 	}
 
@@ -1051,13 +2465,32 @@ func validatePrintableString(buf []byte) (string, error) {
 }
 
 // decodeArray reads count elements and returns a typed slice.
-func (fc *FormatCode) decodeArray(r io.Reader, order binary.ByteOrder, count int) (any, error) {
+func (fc *FormatCode) decodeArray(r io.Reader, count int) (any, error) {
 	totalSize := fc.Size * count
 	buf := make([]byte, totalSize)
 	if _, err := io.ReadFull(r, buf); err != nil {
 		return nil, fmt.Errorf("failed to read %d bytes for %d x format %c: %w", totalSize, count, fc.Code, err)
 	}
+	return fc.decodeElements(buf, count)
+}
+
+// decodeRest reads elements until the input ends and returns a typed slice,
+// which is empty at the end of the input. Bytes left over after the last whole
+// element are an error.
+func (fc *FormatCode) decodeRest(r io.Reader) (any, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the rest of the input for *%c: %w", fc.Code, err)
+	}
+	if extra := len(buf) % fc.Size; extra != 0 {
+		return nil, fmt.Errorf("trailing %d bytes of a partial %d-byte element for *%c: %w", extra, fc.Size, fc.Code, io.ErrUnexpectedEOF)
+	}
+	return fc.decodeElements(buf, len(buf)/fc.Size)
+}
 
+// decodeElements decodes count elements from buf into a typed slice.
+func (fc *FormatCode) decodeElements(buf []byte, count int) (any, error) {
+	order := fc.binaryOrder()
 	switch fc.Code {
 	case 'b': // []int8
 		arr := make([]int8, count)
@@ -1105,14 +2538,32 @@ func (fc *FormatCode) decodeArray(r io.Reader, order binary.ByteOrder, count int
 			arr[i] = order.Uint64(buf[i*8:])
 		}
 		return arr, nil
+	case 'o', 'O': // []*big.Int
+		arr := make([]*big.Int, count)
+		for i := 0; i < count; i++ {
+			arr[i] = decodeInt128(buf[i*int128Size:], order, fc.Code == 'o')
+		}
+		return arr, nil
+	case 'f': // []float32
+		arr := make([]float32, count)
+		for i := 0; i < count; i++ {
+			arr[i] = math.Float32frombits(order.Uint32(buf[i*4:]))
+		}
+		return arr, nil
+	case 'd': // []float64
+		arr := make([]float64, count)
+		for i := 0; i < count; i++ {
+			arr[i] = math.Float64frombits(order.Uint64(buf[i*8:]))
+		}
+		return arr, nil
 	default:
 		return nil, fmt.Errorf("unknown format code: %c", fc.Code)
 	}
 }
 
-// binaryOrder returns the binary.ByteOrder for this expression.
-func (e *Expr) binaryOrder() binary.ByteOrder {
-	return toBinaryOrder(e.Order)
+// binaryOrder returns the binary.ByteOrder for this format code.
+func (fc *FormatCode) binaryOrder() binary.ByteOrder {
+	return toBinaryOrder(fc.Order)
 }
 
 // toBinaryOrder converts ByteOrder to binary.ByteOrder.
@@ -1136,8 +2587,19 @@ func nativeEndian() binary.ByteOrder {
 	return binary.LittleEndian
 }
 
-// decode decodes the bytes into the appropriate type based on the format code.
-func (fc *FormatCode) decode(buf []byte, order binary.ByteOrder) (any, error) {
+// nativeOrderName returns the name of the byte order the '@' prefix resolves to
+// on the current platform.
+func nativeOrderName() string {
+	if nativeEndian() == binary.BigEndian {
+		return byteOrderName(BigEndian)
+	}
+	return byteOrderName(LittleEndian)
+}
+
+// decode decodes the bytes into the appropriate type based on the format code,
+// using the format code's own byte order.
+func (fc *FormatCode) decode(buf []byte) (any, error) {
+	order := fc.binaryOrder()
 	switch fc.Code {
 	case 'b': // signed char
 		return int8(buf[0]), nil
@@ -1155,27 +2617,277 @@ func (fc *FormatCode) decode(buf []byte, order binary.ByteOrder) (any, error) {
 		return int64(order.Uint64(buf)), nil
 	case 'Q': // unsigned long
 		return order.Uint64(buf), nil
+	case 'o', 'O': // 128-bit integer
+		return decodeInt128(buf, order, fc.Code == 'o'), nil
+	case 'f': // float
+		return math.Float32frombits(order.Uint32(buf)), nil
+	case 'd': // double
+		return math.Float64frombits(order.Uint64(buf)), nil
 	default:
 		return nil, fmt.Errorf("unknown format code: %c", fc.Code)
 	}
 }
 
-// Execute parses the expression, reads from the reader, and outputs the result.
-func Execute(format string, r io.Reader, pretty bool) error {
-	node, err := ParseExpression(format)
+// Output formats selectable via Options.Output.
+const (
+	OutputTable = "table" // human-readable table (same as Pretty)
+	OutputRaw   = "raw"   // bare values, one per line
+	OutputJSON  = "json"  // a single line of JSON
+	OutputEnv   = "env"   // FIELD=value lines for a shell to eval
+	OutputSQL   = "sql"   // an INSERT INTO statement per object
+	OutputTmpl  = "tmpl"  // a Go text/template of the result (Options.Template)
+	OutputCSV   = "csv"   // a header row, then a row per record
+)
+
+// Options controls how an expression is parsed, evaluated, and printed.
+type Options struct {
+	// Pretty prints the result in human-readable table format.
+	Pretty bool
+	// Output selects the output format (OutputTable, OutputRaw, ...); empty
+	// prints nothing unless Pretty is set.
+	Output string
+	// Table names the table of the SQL output (empty uses DefaultSQLTable).
+	Table string
+	// Template is the Go text/template rendering the result of the tmpl output.
+	Template string
+	// WarnUnmapped logs a warning for the input values of an object which no
+	// field refers to.
+	WarnUnmapped bool
+	// MaxStringLen caps the bytes read for a null-terminated string (0 uses DefaultMaxStringLen).
+	MaxStringLen int
+	// NoTrim keeps the trailing NULs of fixed-length strings instead of trimming them.
+	NoTrim bool
+	// TrimSet holds more characters trimmed from the end of fixed-length strings
+	// along with the NULs (e.g., " " for fields padded with spaces), unless NoTrim.
+	TrimSet string
+	// WithHex prints a hexdump of the consumed bytes after the result.
+	WithHex bool
+	// Offset is the position of the input reader in the whole input when the
+	// reader cannot tell it (e.g., stdin after skipping --offset bytes), so the
+	// reported offsets agree with seek().
+	Offset int64
+	// CLong resolves the 'l'/'L' aliases to 64-bit codes (LP64 C long) instead of 32-bit.
+	CLong bool
+	// FloatPrecision is the number of digits after the decimal point for floats
+	// in the Value column (0 uses the shortest round-trippable form).
+	FloatPrecision int
+	// MaxBytes caps the total bytes read from the input (0 means no limit).
+	MaxBytes int64
+	// Timeout aborts a read from a pipe or socket which gets no data for this
+	// long (0 means no timeout). Regular files are not affected.
+	Timeout time.Duration
+	// CombinedHex renders the value and hex in a single "<decimal> (<hex>)" column.
+	CombinedHex bool
+	// NoHeader omits the header of the pretty-print table and the footer counting
+	// the records of a record stream.
+	NoHeader bool
+	// Columns selects and orders the columns of the pretty-print table by name
+	// (see TableColumns); empty uses all the columns.
+	Columns []string
+	// ColorTheme colors the pretty-print table with a theme (ColorThemeDark,
+	// ColorThemeLight or ColorThemeMono); empty prints no colors. A NO_COLOR
+	// environment variable disables the colors of any theme.
+	ColorTheme string
+	// ArrayLen renders array types with their length in the Type column (e.g.,
+	// [4]uint8 instead of []uint8).
+	ArrayLen bool
+	// PrintConsumed prints the total number of bytes consumed as the final line.
+	PrintConsumed bool
+	// Verify re-encodes the result and reports where it differs from the consumed
+	// bytes, returning an error on mismatch. The padding trimmed by TrimSet cannot
+	// be re-encoded, so the two are rejected together unless NoTrim.
+	Verify bool
+	// Check parses and evaluates the input without printing anything, so only
+	// the errors of a failed validation (e.g., checksum() or Verify) are reported.
+	Check bool
+	// Follow flushes the output after each record of repeat(), so a consumer of a
+	// live input (e.g., a socket) sees every record as soon as it is read. It is
+	// always on for a terminal.
+	Follow bool
+}
+
+// Execute parses the expression, reads from the reader, and writes the result to
+// w through a buffer which is flushed once everything is written, even on error.
+// The records of repeat() are written as they are read, unless the output needs
+// the whole result (see streamsOutput), so a large input is not held in memory,
+// and with Follow (or on a terminal) the buffer is flushed after each record.
+func Execute(format string, r io.Reader, w io.Writer, opts Options) (err error) {
+	out := bufio.NewWriter(w)
+	defer func() {
+		if flushErr := out.Flush(); err == nil {
+			err = flushErr
+		}
+	}()
+
+	node, err := ParseExpressionWithOptions(format, opts)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to parse expression")
 		return err
 	}
 
-	result, err := node.Eval(r, nil)
+	// Reject unknown table columns before reading any input
+	if _, err := tableLayout(opts); err != nil {
+		log.Error().Err(err).Msg("invalid table columns")
+		return err
+	}
+	if _, err := resolveColorTheme(opts.ColorTheme); err != nil {
+		log.Error().Err(err).Msg("invalid color theme")
+		return err
+	}
+	if opts.Output == OutputTmpl {
+		if _, err := parseOutputTemplate(opts.Template); err != nil {
+			log.Error().Err(err).Msg("invalid output template")
+			return err
+		}
+	}
+
+	// A fixed-length string is re-encoded padded with NULs, whatever it was trimmed of
+	if opts.Verify && opts.TrimSet != "" && !opts.NoTrim {
+		err := fmt.Errorf("verify cannot re-encode the padding trimmed by --trim-set %q, use --no-trim to keep it instead", opts.TrimSet)
+		log.Error().Err(err).Msg("invalid verify options")
+		return err
+	}
+
+	r = limitInput(timeoutInput(r, opts.Timeout), opts.MaxBytes)
+
+	// Offsets are positions in the whole input, as seek() takes them: queried
+	// from a seekable input, or given by the options for one such as stdin
+	start := opts.Offset
+	if offset, err := currentOffset(r); err == nil {
+		start = offset
+	}
+
+	// Capture a copy of the consumed bytes for the hexdump and verification
+	var consumed bytes.Buffer
+	var tee *teeReader
+	if opts.WithHex || opts.Verify {
+		tee = newTeeReader(r, &consumed, start)
+		r = tee
+	}
+
+	// write("-") goes to the buffer, in order with the rest of the output
+	counter := &countingReader{r: r, offset: start, stdout: out}
+	if _, inner := unwrapNamed(node); streamsRecords(inner) && streamsOutput(opts) {
+		opts.Follow = opts.Follow || isInteractive(w)
+		return executeStream(out, node, counter, opts)
+	}
+	result, err := node.Eval(counter, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to evaluate expression")
 		return err
 	}
 
-	if pretty {
-		return PrettyPrintResult(os.Stdout, node, result)
+	// A check only validates the input, so nothing but its errors is printed
+	if opts.Check {
+		if opts.Verify {
+			if err := writeVerify(io.Discard, node, result, consumed.Bytes()); err != nil {
+				log.Error().Err(err).Msg("failed to verify the result")
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := writeResult(out, node, result, opts); err != nil {
+		log.Error().Err(err).Msg("failed to write result")
+		return err
+	}
+	if (opts.Pretty || opts.Output == OutputTable) && !opts.NoHeader {
+		if err := writeRecordFooter(out, node, result, counter.Consumed()); err != nil {
+			return err
+		}
+	}
+	if opts.WithHex {
+		if err := writeConsumedHex(out, node, result, consumed.Bytes(), tee.start); err != nil {
+			return err
+		}
+	}
+
+	// A mismatch is reported after the remaining output
+	var verifyErr error
+	if opts.Verify {
+		verifyErr = writeVerify(out, node, result, consumed.Bytes())
+	}
+	if opts.PrintConsumed {
+		if _, err := fmt.Fprintln(out, counter.Consumed()); err != nil {
+			return err
+		}
+	}
+	return verifyErr
+}
+
+// executeStream writes the records of a streaming node as they are read, then the
+// footer and the consumed byte count as Execute does.
+func executeStream(w io.Writer, node Node, counter *countingReader, opts Options) error {
+	count, err := writeRecordStream(w, node, counter, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to stream records")
+		return err
+	}
+	if opts.Check {
+		return nil
+	}
+	log.Debug().Int("records", count).Msg("streamed records")
+
+	if (opts.Pretty || opts.Output == OutputTable) && !opts.NoHeader {
+		if err := writeRecordCount(w, count, counter.Consumed()); err != nil {
+			return err
+		}
+	}
+	if opts.PrintConsumed {
+		if _, err := fmt.Fprintln(w, counter.Consumed()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EvalBytes parses the expression and evaluates it against the in-memory data,
+// returning the result ([]any or *Object), e.g. for unit-testing expressions:
+//
+//	result, err := bq.EvalBytes("<bH | {0 -> key, 1 -> value}", []byte{0xff, 0x01, 0x02})
+func EvalBytes(expr string, data []byte) (any, error) {
+	node, err := ParseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return node.Eval(newCountingReader(bytes.NewReader(data)), nil)
+}
+
+// limitInput caps the bytes read from r at limit (0 means no limit). Reads past
+// the cap see EOF, which surfaces as the usual insufficient data error.
+func limitInput(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &limitReader{r: r, n: limit}
+}
+
+// writeResult outputs the evaluation result in the format selected by the options.
+func writeResult(w io.Writer, node Node, result any, opts Options) error {
+	// A bitmap preview or dump is its own output, apart from JSON
+	if bm, ok := result.(*Bitmap); ok && opts.Output != OutputJSON {
+		return WriteBitmap(w, bm)
+	}
+	if dump, ok := result.(*Dump); ok && opts.Output != OutputJSON {
+		return WriteDump(w, dump)
+	}
+
+	switch {
+	case opts.Output == OutputRaw:
+		return RawPrintResult(w, result, opts)
+	case opts.Output == OutputJSON:
+		return ResultToJSON(w, namedResult(node, result))
+	case opts.Output == OutputEnv:
+		return EnvPrintResult(w, namedResult(node, result), opts)
+	case opts.Output == OutputSQL:
+		return SQLPrintResult(w, result, opts.Table)
+	case opts.Output == OutputTmpl:
+		return TemplatePrintResult(w, namedResult(node, result), opts.Template)
+	case opts.Output == OutputCSV:
+		return ResultToCSV(w, node, result)
+	case opts.Pretty || opts.Output == OutputTable:
+		return PrettyPrintResultWithOptions(w, node, result, opts)
 	}
 
 	log.Info().Any("result", result).Msg("evaluated expression")
@@ -1192,8 +2904,9 @@ func PrettyPrint(w io.Writer, expr *Expr, values []any) error {
 		return err
 	}
 
+	formats := expr.valueFormats()
 	for i, val := range values {
-		fc := expr.Formats[i]
+		fc := formats[i]
 		typeName := formatCodeRegistry[fc.Code].typeName
 		hexStr := formatHex(val)
 
@@ -1224,6 +2937,15 @@ func formatHex(val any) string {
 		return fmt.Sprintf("0x%016x", uint64(v))
 	case uint64:
 		return fmt.Sprintf("0x%016x", v)
+	case *big.Int:
+		return "0x" + formatHex128(v)
+	case float32:
+		// Floats show their IEEE-754 bits
+		return fmt.Sprintf("0x%08x", math.Float32bits(v))
+	case float64:
+		return fmt.Sprintf("0x%016x", math.Float64bits(v))
+	case Mark:
+		return fmt.Sprintf("0x%016x", uint64(v.Offset))
 	case string:
 		// Format string as hex bytes
 		return formatHexArray([]byte(v), func(x byte) string { return fmt.Sprintf("%02x", x) })
@@ -1244,6 +2966,12 @@ func formatHex(val any) string {
 		return formatHexArray(v, func(x int64) string { return fmt.Sprintf("%016x", uint64(x)) })
 	case []uint64:
 		return formatHexArray(v, func(x uint64) string { return fmt.Sprintf("%016x", x) })
+	case []*big.Int:
+		return formatHexArray(v, formatHex128)
+	case []float32:
+		return formatHexArray(v, func(x float32) string { return fmt.Sprintf("%08x", math.Float32bits(x)) })
+	case []float64:
+		return formatHexArray(v, func(x float64) string { return fmt.Sprintf("%016x", math.Float64bits(x)) })
 	default:
 		return "N/A"
 	}
@@ -1264,68 +2992,217 @@ func formatHexArray[T any](arr []T, format func(T) string) string {
 // PrettyPrintResult outputs any evaluation result in a human-readable format.
 // It handles both []any (from FormatNode) and *Object (from ObjectNode).
 func PrettyPrintResult(w io.Writer, node Node, result any) error {
-	// Print header
-	if _, err := fmt.Fprintf(w, "%-10s %-6s %-8s %20s %20s\n", "Name", "Code", "Type", "Value", "Hex"); err != nil {
+	return PrettyPrintResultWithOptions(w, node, result, Options{})
+}
+
+// PrettyPrintResultWithOptions outputs the result like PrettyPrintResult, honoring
+// the rendering options such as the float precision.
+func PrettyPrintResultWithOptions(w io.Writer, node Node, result any, opts Options) error {
+	p, err := newTablePrinter(w, opts)
+	if err != nil {
+		return err
+	}
+
+	title, node := unwrapNamed(node)
+	if err := p.printTop(title); err != nil {
 		return err
 	}
-	if _, err := fmt.Fprintf(w, "%s\n", "--------------------------------------------------------------------"); err != nil {
+	return p.printValue(node, result, 0)
+}
+
+// newTablePrinter returns a printer of the pretty-print table to w.
+func newTablePrinter(w io.Writer, opts Options) (*tablePrinter, error) {
+	columns, err := tableLayout(opts)
+	if err != nil {
+		return nil, err
+	}
+	colors, err := resolveColorTheme(opts.ColorTheme)
+	if err != nil {
+		return nil, err
+	}
+	return &tablePrinter{w: w, opts: opts, columns: columns, colors: colors}, nil
+}
+
+// printTop prints the title of a named expression (if any) and the header
+// above the table.
+func (p *tablePrinter) printTop(title string) error {
+	if title != "" {
+		if _, err := fmt.Fprintf(p.w, "=== %s ===\n", title); err != nil {
+			return err
+		}
+	}
+	if p.opts.NoHeader {
+		return nil
+	}
+	return p.printHeader()
+}
+
+// printHeader prints the column titles above a separator line.
+func (p *tablePrinter) printHeader() error {
+	if err := p.printCells(map[string]string{"name": "Name", "code": "Code", "type": "Type", "value": "Value", "hex": "Hex"}, true); err != nil {
 		return err
 	}
+	width := len(p.columns) - 1
+	for _, col := range p.columns {
+		width += col.width
+	}
+	_, err := fmt.Fprintf(p.w, "%s\n", strings.Repeat("-", width))
+	return err
+}
+
+// TableColumns lists the columns of the pretty-print table in their default
+// order, as selected by Options.Columns.
+var TableColumns = []string{"name", "code", "type", "value", "hex"}
+
+// tableColumn is a column of the pretty-print table.
+type tableColumn struct {
+	name  string // column name, as in TableColumns
+	width int    // padded width of the cells
+	left  bool   // left-align the cells instead of right-aligning them
+}
+
+// tableLayout resolves the columns of the pretty-print table. The value column
+// is widened to hold the hex as well with CombinedHex, which drops the hex column.
+func tableLayout(opts Options) ([]tableColumn, error) {
+	names := opts.Columns
+	if len(names) == 0 {
+		names = TableColumns
+	}
+
+	columns := make([]tableColumn, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "name":
+			columns = append(columns, tableColumn{name: name, width: 10, left: true})
+		case "code":
+			columns = append(columns, tableColumn{name: name, width: 6, left: true})
+		case "type":
+			columns = append(columns, tableColumn{name: name, width: 8, left: true})
+		case "value":
+			width := 20
+			if opts.CombinedHex {
+				width = 41
+			}
+			columns = append(columns, tableColumn{name: name, width: width})
+		case "hex":
+			if !opts.CombinedHex {
+				columns = append(columns, tableColumn{name: name, width: 20})
+			}
+		default:
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(TableColumns, ", "))
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns to print")
+	}
+	return columns, nil
+}
+
+// tablePrinter renders evaluation results as the pretty-print table.
+type tablePrinter struct {
+	w       io.Writer
+	opts    Options
+	columns []tableColumn
+	colors  map[string]string // colors of the theme, nil for none
+}
+
+// printRow prints a single row of the table, with the value and hex sharing a
+// single column when CombinedHex is set.
+func (p *tablePrinter) printRow(name, code, typeName, valStr, hexStr string) error {
+	if p.opts.CombinedHex {
+		valStr = combineValueHex(valStr, hexStr)
+	}
+	return p.printCells(map[string]string{"name": name, "code": code, "type": typeName, "value": valStr, "hex": hexStr}, false)
+}
+
+// printCells prints the cells of a row in the order of the table columns, in the
+// colors of the header or of each column. Cells are padded before being colored,
+// so the escape codes do not shift the columns.
+func (p *tablePrinter) printCells(cells map[string]string, header bool) error {
+	parts := make([]string, len(p.columns))
+	for i, col := range p.columns {
+		if col.left {
+			parts[i] = fmt.Sprintf("%-*s", col.width, cells[col.name])
+		} else {
+			parts[i] = fmt.Sprintf("%*s", col.width, cells[col.name])
+		}
+
+		part := col.name
+		if header {
+			part = "header"
+		}
+		parts[i] = paint(p.colors, part, parts[i])
+	}
+	_, err := fmt.Fprintln(p.w, strings.Join(parts, " "))
+	return err
+}
 
-	return prettyPrintValue(w, node, result, 0)
+// combineValueHex renders a value and its hex as "<decimal> (<hex>)". Arrays
+// (no decimal value) show just the hex, while strings and floats (no scalar hex)
+// show just the value.
+func combineValueHex(valStr, hexStr string) string {
+	switch {
+	case valStr == "":
+		return hexStr
+	case hexStr == "" || hexStr == "N/A" || strings.HasPrefix(hexStr, "["):
+		return valStr
+	default:
+		return fmt.Sprintf("%s (%s)", valStr, hexStr)
+	}
 }
 
-// prettyPrintValue recursively prints values with indentation for nested objects.
-func prettyPrintValue(w io.Writer, node Node, result any, indent int) error {
+// printValue recursively prints values with indentation for nested objects.
+func (p *tablePrinter) printValue(node Node, result any, indent int) error {
 	indentStr := strings.Repeat("  ", indent)
 
 	switch r := result.(type) {
 	case []any:
 		// Result from FormatNode - use indices as names
-		formatNode, ok := extractFormatNode(node)
-		if ok {
-			for i, val := range r {
-				fc := formatNode.Formats[i]
-				typeName := formatCodeRegistry[fc.Code].typeName
-				hexStr := formatHex(val)
-				valStr := formatValue(val)
-				name := fmt.Sprintf("%s%d", indentStr, i)
-				if _, err := fmt.Fprintf(w, "%-10s %-6c %-8s %20s %20s\n", name, fc.Code, typeName, valStr, hexStr); err != nil {
-					return err
-				}
-			}
-		} else {
-			// Fallback if no format info available
-			for i, val := range r {
-				code, typeName := inferTypeInfo(val)
-				hexStr := formatHex(val)
-				valStr := formatValue(val)
-				name := fmt.Sprintf("%s%d", indentStr, i)
-				if _, err := fmt.Fprintf(w, "%-10s %-6c %-8s %20s %20s\n", name, code, typeName, valStr, hexStr); err != nil {
-					return err
-				}
+		var formats []FormatCode
+		if formatNode, ok := positionalFormatNode(node); ok {
+			formats = formatNode.valueFormats()
+		}
+		for i, val := range r {
+			if err := p.printElement(node, formats, i, val, indent); err != nil {
+				return err
 			}
 		}
 	case *Object:
 		// Result from ObjectNode - use field names
-		for _, field := range r.Fields {
+		for i, field := range r.Fields {
 			// Check if field value is a nested object
 			if nestedObj, ok := field.Value.(*Object); ok {
 				// Print nested object header
 				name := fmt.Sprintf("%s%s", indentStr, field.Name)
-				if _, err := fmt.Fprintf(w, "%-10s %-6s %-8s %20s %20s\n", name, "-", "object", "", ""); err != nil {
+				if err := p.printRow(name, "-", "object", "", ""); err != nil {
 					return err
 				}
 				// Recursively print nested fields
-				if err := prettyPrintValue(w, nil, nestedObj, indent+1); err != nil {
+				if err := p.printValue(nestedObjectNode(node, i), nestedObj, indent+1); err != nil {
+					return err
+				}
+			} else if strs, ok := field.Value.([]string); ok {
+				if err := p.printStrings(indentStr+field.Name, strs, indent); err != nil {
 					return err
 				}
 			} else {
+				// The format code of the value gives its type, which the value alone
+				// may not (e.g., a non-negative int128)
 				code, typeName := inferTypeInfo(field.Value)
-				hexStr := formatHex(field.Value)
-				valStr := formatValue(field.Value)
+				if fc, ok := objectFieldFormat(node, i); ok {
+					code, typeName = fc.Code, formatCodeRegistry[fc.Code].typeName
+					if _, isArray := arrayLen(field.Value); isArray {
+						typeName = "[]" + typeName
+					}
+				}
 				name := fmt.Sprintf("%s%s", indentStr, field.Name)
-				if _, err := fmt.Fprintf(w, "%-10s %-6c %-8s %20s %20s\n", name, code, typeName, valStr, hexStr); err != nil {
+				if err := p.printRow(name, string(code), p.arrayType(field.Value, typeName), p.formatHinted(field.Value, field.Hint), formatHex(field.Value)); err != nil {
 					return err
 				}
 			}
@@ -1337,28 +3214,234 @@ func prettyPrintValue(w io.Writer, node Node, result any, indent int) error {
 	return nil
 }
 
+// objectFieldFormat returns the format code of the value named by field i of the
+// object on the right of a pipe, or false for a computed or nested field or when
+// the values do not line up with the format codes of the left side.
+func objectFieldFormat(node Node, i int) (FormatCode, bool) {
+	pipe, ok := node.(*PipeNode)
+	if !ok {
+		return FormatCode{}, false
+	}
+	obj, ok := pipe.Right.(*ObjectNode)
+	if !ok || i >= len(obj.Fields) {
+		return FormatCode{}, false
+	}
+	fd := obj.Fields[i]
+	if fd.Compute != nil || fd.Nested != nil {
+		return FormatCode{}, false
+	}
+	expr, ok := positionalFormatNode(pipe.Left)
+	if !ok {
+		return FormatCode{}, false
+	}
+	formats := expr.valueFormats()
+	if fd.Index < 0 || fd.Index >= len(formats) {
+		return FormatCode{}, false
+	}
+	return formats[fd.Index], true
+}
+
+// nestedObjectNode returns the node producing the nested object of field i, a pipe
+// from the same left side, so its fields are typed by their format codes too.
+func nestedObjectNode(node Node, i int) Node {
+	pipe, ok := node.(*PipeNode)
+	if !ok {
+		return nil
+	}
+	obj, ok := pipe.Right.(*ObjectNode)
+	if !ok || i >= len(obj.Fields) || obj.Fields[i].Nested == nil {
+		return nil
+	}
+	return &PipeNode{Left: pipe.Left, Right: obj.Fields[i].Nested}
+}
+
+// printElement prints the value at index i of a list result, read by the format
+// codes (if known).
+func (p *tablePrinter) printElement(node Node, formats []FormatCode, i int, val any, indent int) error {
+	indentStr := strings.Repeat("  ", indent)
+	name := fmt.Sprintf("%s%d", indentStr, i)
+	if m, isMark := val.(Mark); isMark {
+		name = indentStr + m.Name
+	}
+
+	// A record (e.g., from split) prints its own values nested below
+	switch v := val.(type) {
+	case []any, *Object:
+		if err := p.printRow(name, "-", "record", "", ""); err != nil {
+			return err
+		}
+		return p.printValue(recordNode(node), val, indent+1)
+	case []string:
+		return p.printStrings(name, v, indent)
+	}
+
+	var code, typeName string
+	if i < len(formats) {
+		fc := formats[i]
+		code, typeName = string(fc.Code), p.arrayType(val, formatCodeRegistry[fc.Code].typeName)
+	} else {
+		// Fallback if no format info available (e.g., values appended by mark)
+		c, t := inferTypeInfo(val)
+		code, typeName = string(c), p.arrayType(val, t)
+	}
+	return p.printRow(name, code, typeName, p.formatValue(val), formatHex(val))
+}
+
+// printStrings prints a string array as a header row followed by a row per string.
+func (p *tablePrinter) printStrings(name string, strs []string, indent int) error {
+	if err := p.printRow(name, "s", p.arrayType(strs, "[]string"), "", ""); err != nil {
+		return err
+	}
+	indentStr := strings.Repeat("  ", indent+1)
+	for i, str := range strs {
+		if err := p.printRow(fmt.Sprintf("%s%d", indentStr, i), "s", "string", str, formatHex(str)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arrayType returns the type name of an array value with its length (e.g.,
+// [4]uint8) when ArrayLen is set, and the type name unchanged otherwise.
+func (p *tablePrinter) arrayType(val any, typeName string) string {
+	if !p.opts.ArrayLen {
+		return typeName
+	}
+	n, ok := arrayLen(val)
+	if !ok {
+		return typeName
+	}
+	return fmt.Sprintf("[%d]%s", n, strings.TrimPrefix(typeName, "[]"))
+}
+
+// formatHinted formats a value for the Value column following a render hint:
+// integers (and integer arrays) in hex or decimal, other values as usual.
+func (p *tablePrinter) formatHinted(val any, hint string) string {
+	if _, err := toInt64(val); err != nil && !isArrayValue(val) {
+		return p.formatValue(val)
+	}
+
+	switch hint {
+	case HintHex:
+		return formatHex(val)
+	case HintDec:
+		return fmt.Sprintf("%v", val)
+	default:
+		return p.formatValue(val)
+	}
+}
+
+// formatValue formats a value for the Value column, rendering floats (and
+// arrays of floats) with the configured precision.
+func (p *tablePrinter) formatValue(val any) string {
+	prec := p.opts.FloatPrecision
+	switch v := val.(type) {
+	case float32:
+		return formatFloat(float64(v), 32, prec)
+	case float64:
+		return formatFloat(v, 64, prec)
+	case []float32:
+		return formatHexArray(v, func(x float32) string { return formatFloat(float64(x), 32, prec) })
+	case []float64:
+		return formatHexArray(v, func(x float64) string { return formatFloat(x, 64, prec) })
+	case string:
+		// The NULs kept by NoTrim are shown rather than sent to the terminal
+		return strings.ReplaceAll(v, "\x00", `\0`)
+	default:
+		return formatValue(val)
+	}
+}
+
+// formatFloat formats a float with the given number of digits after the decimal
+// point, or in the shortest round-trippable form when prec is 0.
+func formatFloat(v float64, bitSize int, prec int) string {
+	if prec <= 0 {
+		return strconv.FormatFloat(v, 'g', -1, bitSize)
+	}
+	return strconv.FormatFloat(v, 'f', prec, bitSize)
+}
+
 // extractFormatNode extracts the FormatNode from a node tree (handles PipeNode).
 func extractFormatNode(node Node) (*Expr, bool) {
 	switch n := node.(type) {
 	case *FormatNode:
 		return n.Expr, true
+	case *NamedNode:
+		return extractFormatNode(n.Inner)
+	case *SeekNode:
+		return extractFormatNode(n.Inner)
+	case *AssertNode:
+		return n.expr(), true
 	case *PipeNode:
+		// A reparse replaces the values with those of its inner expression
+		if reparse, ok := n.Right.(*ReparseNode); ok {
+			return extractFormatNode(reparse.Inner)
+		}
 		return extractFormatNode(n.Left)
 	default:
 		return nil, false
 	}
 }
 
+// positionalFormatNode extracts the FormatNode whose format codes line up with the
+// positions of the node's []any result, descending only through pipes whose right
+// side keeps the value positions (mark() and string_array() append, write(),
+// extract() and checksum() pass values through)
+// or replaces them with those of an inner expression (reparse()).
+func positionalFormatNode(node Node) (*Expr, bool) {
+	switch n := node.(type) {
+	case *FormatNode:
+		return n.Expr, true
+	case *NamedNode:
+		return positionalFormatNode(n.Inner)
+	case *SeekNode:
+		return positionalFormatNode(n.Inner)
+	case *AssertNode:
+		return n.expr(), true
+	case *PipeNode:
+		switch right := n.Right.(type) {
+		case *MarkNode, *WriteNode, *ExtractNode, *ChecksumNode, *StringArrayNode:
+			return positionalFormatNode(n.Left)
+		case *ReparseNode:
+			return positionalFormatNode(right.Inner)
+		}
+	}
+	return nil, false
+}
+
+// valuesFormatNode extracts the FormatNode like positionalFormatNode, but also
+// descends through an object naming the values in order ({0 -> a, 1 -> b}), whose
+// field values line up with the format codes too. write() and --verify use it to
+// lay out the values of such an object by their codes.
+func valuesFormatNode(node Node) (*Expr, bool) {
+	if n, ok := node.(*PipeNode); ok {
+		if obj, ok := n.Right.(*ObjectNode); ok && obj.keepsPositions() {
+			return valuesFormatNode(n.Left)
+		}
+	}
+	return positionalFormatNode(node)
+}
+
 // isArrayValue returns true if the value is an array type.
 func isArrayValue(val any) bool {
 	switch val.(type) {
-	case []int8, []uint8, []int16, []uint16, []int32, []uint32, []int64, []uint64:
+	case []int8, []uint8, []int16, []uint16, []int32, []uint32, []int64, []uint64, []*big.Int:
 		return true
 	default:
 		return false
 	}
 }
 
+// arrayLen returns the number of elements of an array value: any typed slice
+// such as []uint8, []float64, []*big.Int or []string, but not a []any of values.
+func arrayLen(val any) (int, bool) {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Interface {
+		return 0, false
+	}
+	return v.Len(), true
+}
+
 // formatValue formats a value for the Value column.
 // For arrays, returns empty string (only Hex is shown).
 // For scalars, returns the value as a string.
@@ -1371,7 +3454,7 @@ func formatValue(val any) string {
 
 // inferTypeInfo infers the format code and type name from a value's Go type.
 func inferTypeInfo(val any) (rune, string) {
-	switch val.(type) {
+	switch v := val.(type) {
 	case int8:
 		return 'b', "int8"
 	case uint8:
@@ -1388,6 +3471,12 @@ func inferTypeInfo(val any) (rune, string) {
 		return 'q', "int64"
 	case uint64:
 		return 'Q', "uint64"
+	case *big.Int:
+		// The value alone has no signedness: only a negative one must be signed
+		if v.Sign() < 0 {
+			return 'o', "int128"
+		}
+		return 'O', "uint128"
 	// Array types
 	case []int8:
 		return 'b', "[]int8"
@@ -1405,9 +3494,57 @@ func inferTypeInfo(val any) (rune, string) {
 		return 'q', "[]int64"
 	case []uint64:
 		return 'Q', "[]uint64"
+	case []*big.Int:
+		for _, x := range v {
+			if x.Sign() < 0 {
+				return 'o', "[]int128"
+			}
+		}
+		return 'O', "[]uint128"
+	case float32:
+		return 'f', "float32"
+	case []float32:
+		return 'f', "[]float32"
+	case []float64:
+		return 'd', "[]float64"
 	case string:
 		return 's', "string"
+	case []string:
+		return 's', "[]string"
+	case float64:
+		return 'd', "float64"
+	case Mark:
+		return '-', "mark"
+	case nil:
+		return '-', "nil"
 	default:
 		return '?', "unknown"
 	}
 }
+
+// toInt64 converts an integer value of any width into an int64.
+func toInt64(val any) (int64, error) {
+	switch v := val.(type) {
+	case int8:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", v)
+		}
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("expected an integer value, got %T", val)
+	}
+}
@@ -3,8 +3,16 @@ package bq
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -56,7 +64,7 @@ func TestParse(t *testing.T) {
 		},
 		{
 			name:    "unknown code",
-			format:  "<x",
+			format:  "<z",
 			wantErr: true,
 		},
 	}
@@ -122,6 +130,30 @@ func TestExpr_Read(t *testing.T) {
 			data:   []byte{0x01, 0x02},
 			want:   []any{int16(0x0102)},
 		},
+		{
+			name:   "padding skipped",
+			format: "<b4xH",
+			data:   []byte{0xFF, 0xAA, 0xBB, 0xCC, 0xDD, 0x01, 0x02},
+			want:   []any{int8(-1), uint16(0x0201)},
+		},
+		{
+			name:    "padding past the input",
+			format:  "<b4x",
+			data:    []byte{0x01, 0x00, 0x00},
+			wantErr: true,
+		},
+		{
+			name:   "little endian float",
+			format: "<f",
+			data:   []byte{0x00, 0x00, 0xC0, 0x3F},
+			want:   []any{float32(1.5)},
+		},
+		{
+			name:   "big endian double",
+			format: ">d",
+			data:   []byte{0xC0, 0x09, 0x21, 0xFB, 0x54, 0x44, 0x2D, 0x18},
+			want:   []any{-3.141592653589793},
+		},
 		{
 			name:   "little endian unsigned short",
 			format: "<H",
@@ -208,6 +240,335 @@ func TestExpr_Read(t *testing.T) {
 	}
 }
 
+func TestParseMixedByteOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		wantOrder  ByteOrder
+		wantOrders []ByteOrder
+		wantErr    bool
+	}{
+		{
+			name:       "little then big",
+			format:     "<H>I",
+			wantOrder:  LittleEndian,
+			wantOrders: []ByteOrder{LittleEndian, BigEndian},
+		},
+		{
+			name:       "switch applies to subsequent codes",
+			format:     ">bH<hI",
+			wantOrder:  BigEndian,
+			wantOrders: []ByteOrder{BigEndian, BigEndian, LittleEndian, LittleEndian},
+		},
+		{
+			name:       "switch before array count",
+			format:     "B>2H",
+			wantOrder:  NativeOrder,
+			wantOrders: []ByteOrder{NativeOrder, BigEndian},
+		},
+		{
+			name:    "trailing byte order",
+			format:  "<H>",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if expr.Order != tt.wantOrder {
+				t.Errorf("Parse() Order = %v, want %v", expr.Order, tt.wantOrder)
+			}
+			if len(expr.Formats) != len(tt.wantOrders) {
+				t.Fatalf("Parse() Formats len = %v, want %v", len(expr.Formats), len(tt.wantOrders))
+			}
+			for i, fc := range expr.Formats {
+				if fc.Order != tt.wantOrders[i] {
+					t.Errorf("Parse() Formats[%d].Order = %v, want %v", i, fc.Order, tt.wantOrders[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpr_ReadMixedByteOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		want   []any
+	}{
+		{
+			name:   "little-endian H then big-endian I",
+			format: "<H>I",
+			data:   []byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x03},
+			want:   []any{uint16(0x0201), uint32(3)},
+		},
+		{
+			name:   "big-endian then little-endian array",
+			format: ">h<2H",
+			data:   []byte{0xFF, 0xFE, 0x01, 0x00, 0x02, 0x00},
+			want:   []any{int16(-2), []uint16{1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			got, err := expr.Read(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Read() len = %v, want %v", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !compareValues(got[i], tt.want[i]) {
+					t.Errorf("Read()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpr_ReadInto(t *testing.T) {
+	expr, err := Parse("<bH")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	dst := make([]any, 0, 8)
+	got, err := expr.ReadInto(bytes.NewReader([]byte{0xFF, 0x01, 0x02}), dst)
+	if err != nil {
+		t.Fatalf("ReadInto() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != int8(-1) || got[1] != uint16(0x0201) {
+		t.Errorf("ReadInto() = %v, want [-1 513]", got)
+	}
+	if &got[0] != &dst[:1][0] {
+		t.Error("ReadInto() did not reuse the destination slice")
+	}
+
+	// Reusing a slice with stale values overwrites them
+	got, err = expr.ReadInto(bytes.NewReader([]byte{0x01, 0x00, 0x00}), got)
+	if err != nil {
+		t.Fatalf("ReadInto() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != int8(1) || got[1] != uint16(0) {
+		t.Errorf("ReadInto() = %v, want [1 0]", got)
+	}
+
+	// A nil destination grows as needed
+	got, err = expr.ReadInto(bytes.NewReader([]byte{0x02, 0x03, 0x00}), nil)
+	if err != nil {
+		t.Fatalf("ReadInto() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != int8(2) || got[1] != uint16(3) {
+		t.Errorf("ReadInto() = %v, want [2 3]", got)
+	}
+}
+
+func TestExpr_ReadEOF(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		clean  bool // a clean end of stream (io.EOF) rather than a truncation
+	}{
+		{"empty input", "<BH", nil, true},
+		{"empty input for array", "<4B", nil, true},
+		{"empty input for string", "s", nil, true},
+		{"partial first field", "<HB", []byte{0x01}, false},
+		{"partial array", "<4B", []byte{0x01, 0x02}, false},
+		{"EOF at second field", "<BH", []byte{0x01}, false},
+		{"EOF at string field", "<Bs", []byte{0x01}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			_, err = expr.Read(bytes.NewReader(tt.data))
+			if err == nil {
+				t.Fatal("Read() expected error, got nil")
+			}
+			if errors.Is(err, io.EOF) != tt.clean {
+				t.Errorf("errors.Is(%v, io.EOF) = %v, want %v", err, !tt.clean, tt.clean)
+			}
+			if !tt.clean && !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("Read() error = %v, want %v", err, io.ErrUnexpectedEOF)
+			}
+		})
+	}
+}
+
+func TestExpr_ReadEach(t *testing.T) {
+	expr, err := Parse("<bH")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	data := []byte{0x01, 0x02, 0x00, 0x03, 0x04, 0x00, 0x05, 0x06, 0x00}
+	var records int
+	var sum int
+	err = expr.ReadEach(bytes.NewReader(data), func(values []any) error {
+		records++
+		sum += int(values[0].(int8))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadEach() error = %v", err)
+	}
+	if records != 3 || sum != 9 {
+		t.Errorf("ReadEach() records = %d, sum = %d, want 3 and 9", records, sum)
+	}
+
+	// A partial trailing record is an error
+	records = 0
+	err = expr.ReadEach(bytes.NewReader(data[:4]), func([]any) error {
+		records++
+		return nil
+	})
+	if err == nil || records != 1 {
+		t.Errorf("ReadEach() partial record: error = %v, records = %d", err, records)
+	}
+
+	// An error from the callback stops reading
+	stop := errors.New("stop")
+	records = 0
+	err = expr.ReadEach(bytes.NewReader(data), func([]any) error {
+		records++
+		return stop
+	})
+	if err != stop || records != 1 {
+		t.Errorf("ReadEach() callback error = %v, records = %d", err, records)
+	}
+
+	// Records starting with a string also stop cleanly
+	expr, _ = Parse("sB")
+	records = 0
+	err = expr.ReadEach(bytes.NewReader([]byte{'a', 0, 1, 'b', 0, 2}), func([]any) error {
+		records++
+		return nil
+	})
+	if err != nil || records != 2 {
+		t.Errorf("ReadEach() string records: error = %v, records = %d", err, records)
+	}
+}
+
+func BenchmarkExpr_Read(b *testing.B) {
+	expr, err := Parse("<bHiq")
+	if err != nil {
+		b.Fatalf("Parse() error = %v", err)
+	}
+	data := make([]byte, 15)
+	r := bytes.NewReader(data)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		if _, err := expr.Read(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExpr_ReadInto(b *testing.B) {
+	expr, err := Parse("<bHiq")
+	if err != nil {
+		b.Fatalf("Parse() error = %v", err)
+	}
+	data := make([]byte, 15)
+	r := bytes.NewReader(data)
+	dst := make([]any, 0, len(expr.Formats))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		if dst, err = expr.ReadInto(r, dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseFormatCodeAliases(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		opts      Options
+		wantCodes []rune
+	}{
+		{
+			name:      "32-bit C long by default",
+			format:    "<lL",
+			wantCodes: []rune{'i', 'I'},
+		},
+		{
+			name:      "64-bit LP64 C long",
+			format:    "<lL",
+			opts:      Options{CLong: true},
+			wantCodes: []rune{'q', 'Q'},
+		},
+		{
+			name:      "aliases mixed with canonical codes and counts",
+			format:    "<b2lH",
+			wantCodes: []rune{'b', 'i', 'H'},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpressionWithOptions(tt.format, tt.opts)
+			if err != nil {
+				t.Fatalf("ParseExpressionWithOptions() error = %v", err)
+			}
+
+			formats := node.(*FormatNode).Formats
+			if len(formats) != len(tt.wantCodes) {
+				t.Fatalf("Formats len = %d, want %d", len(formats), len(tt.wantCodes))
+			}
+			for i, fc := range formats {
+				if fc.Code != tt.wantCodes[i] {
+					t.Errorf("Formats[%d].Code = %c, want %c", i, fc.Code, tt.wantCodes[i])
+				}
+				if fc.Size != formatCodeRegistry[tt.wantCodes[i]].size {
+					t.Errorf("Formats[%d].Size = %d, want %d", i, fc.Size, formatCodeRegistry[tt.wantCodes[i]].size)
+				}
+			}
+		})
+	}
+
+	// Aliased values decode like their canonical codes
+	values, err := mustParse(t, "<L").Read(bytes.NewReader([]byte{0x01, 0x00, 0x00, 0x00}))
+	if err != nil || values[0] != uint32(1) {
+		t.Errorf("Read() = %v, %v, want [1]", values, err)
+	}
+}
+
+// mustParse parses a format string or fails the test.
+func mustParse(t *testing.T, format string) *Expr {
+	t.Helper()
+	expr, err := Parse(format)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", format, err)
+	}
+	return expr
+}
+
 func TestFormatCodeSize(t *testing.T) {
 	tests := []struct {
 		code rune
@@ -221,6 +582,8 @@ func TestFormatCodeSize(t *testing.T) {
 		{'I', 4},
 		{'q', 8},
 		{'Q', 8},
+		{'f', 4},
+		{'d', 8},
 	}
 
 	for _, tt := range tests {
@@ -292,6 +655,50 @@ func TestPrettyPrint(t *testing.T) {
 	}
 }
 
+func TestFloatRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		check  func(any) bool
+	}{
+		{"float NaN", "<f", []byte{0x00, 0x00, 0xC0, 0x7F}, func(v any) bool { return math.IsNaN(float64(v.(float32))) }},
+		{"float +Inf", ">f", []byte{0x7F, 0x80, 0x00, 0x00}, func(v any) bool { return math.IsInf(float64(v.(float32)), 1) }},
+		{"float -0", "<f", []byte{0x00, 0x00, 0x00, 0x80}, func(v any) bool { return math.Signbit(float64(v.(float32))) }},
+		{"double NaN", ">d", []byte{0x7F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, func(v any) bool { return math.IsNaN(v.(float64)) }},
+		{"double -Inf", "<d", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF0, 0xFF}, func(v any) bool { return math.IsInf(v.(float64), -1) }},
+		{"double array", "<2d", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF0, 0x7F, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x7F}, func(v any) bool {
+			arr := v.([]float64)
+			return math.IsInf(arr[0], 1) && math.IsNaN(arr[1])
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			values, err := expr.Read(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !tt.check(values[0]) {
+				t.Errorf("Read() = %v, unexpected value", values[0])
+			}
+
+			// Writing the value back must reproduce the exact bits, NaN payload included
+			var buf bytes.Buffer
+			if err := encodeValue(&buf, values[0], toBinaryOrder(expr.Order)); err != nil {
+				t.Fatalf("encodeValue() error = %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.data) {
+				t.Errorf("encodeValue() = % x, want % x", buf.Bytes(), tt.data)
+			}
+		})
+	}
+}
+
 func TestFormatHex(t *testing.T) {
 	tests := []struct {
 		name string
@@ -310,6 +717,10 @@ func TestFormatHex(t *testing.T) {
 		{"int64", int64(0x0102030405060708), "0x0102030405060708"},
 		{"int64 negative", int64(-1), "0xffffffffffffffff"},
 		{"uint64", uint64(0xFFFFFFFFFFFFFFFF), "0xffffffffffffffff"},
+		{"int128", big.NewInt(0x0102), "0x00000000000000000000000000000102"},
+		{"int128 negative", big.NewInt(-1), "0xffffffffffffffffffffffffffffffff"},
+		{"float32", float32(1.5), "0x3fc00000"},
+		{"float64", float64(-2), "0xc000000000000000"},
 		{"string", "hello", "[68 65 6c 6c 6f]"},
 		{"string empty", "", "[]"},
 		{"unknown type", struct{}{}, "N/A"},
@@ -371,11 +782,71 @@ func TestTokenizer(t *testing.T) {
 		},
 		{
 			name:  "all byte orders",
-			input: "< > @",
+			input: "< > @ =",
 			tokens: []Token{
 				{Type: TokenOrder, Value: "<"},
 				{Type: TokenOrder, Value: ">"},
 				{Type: TokenOrder, Value: "@"},
+				{Type: TokenOrder, Value: "="},
+				{Type: TokenEOF},
+			},
+		},
+		{
+			name:  "hex and float numbers",
+			input: "0x0A 0XfF 1.5 0 x",
+			tokens: []Token{
+				{Type: TokenNumber, Value: "0x0A"},
+				{Type: TokenNumber, Value: "0XfF"},
+				{Type: TokenNumber, Value: "1.5"},
+				{Type: TokenNumber, Value: "0"},
+				{Type: TokenFormat, Value: "x"},
+				{Type: TokenEOF},
+			},
+		},
+		{
+			name:  "arithmetic operators",
+			input: "($0 - 1) * 2 + 3 / 4",
+			tokens: []Token{
+				{Type: TokenLParen, Value: "("},
+				{Type: TokenDollar, Value: "$"},
+				{Type: TokenNumber, Value: "0"},
+				{Type: TokenMinus, Value: "-"},
+				{Type: TokenNumber, Value: "1"},
+				{Type: TokenRParen, Value: ")"},
+				{Type: TokenStar, Value: "*"},
+				{Type: TokenNumber, Value: "2"},
+				{Type: TokenPlus, Value: "+"},
+				{Type: TokenNumber, Value: "3"},
+				{Type: TokenSlash, Value: "/"},
+				{Type: TokenNumber, Value: "4"},
+				{Type: TokenEOF},
+			},
+		},
+		{
+			name:  "render hint",
+			input: "0 -> uid #hex",
+			tokens: []Token{
+				{Type: TokenNumber, Value: "0"},
+				{Type: TokenArrow, Value: "->"},
+				{Type: TokenIdent, Value: "uid"},
+				{Type: TokenHash, Value: "#"},
+				{Type: TokenIdent, Value: "hex"},
+				{Type: TokenEOF},
+			},
+		},
+		{
+			name:  "comparison operators",
+			input: "0 >= 1 != 2 < 3 == 4",
+			tokens: []Token{
+				{Type: TokenNumber, Value: "0"},
+				{Type: TokenCompare, Value: ">="},
+				{Type: TokenNumber, Value: "1"},
+				{Type: TokenCompare, Value: "!="},
+				{Type: TokenNumber, Value: "2"},
+				{Type: TokenOrder, Value: "<"},
+				{Type: TokenNumber, Value: "3"},
+				{Type: TokenCompare, Value: "=="},
+				{Type: TokenNumber, Value: "4"},
 				{Type: TokenEOF},
 			},
 		},
@@ -538,13 +1009,54 @@ func TestParseExpression(t *testing.T) {
 	}
 }
 
-func TestParseExpressionAST(t *testing.T) {
-	// Test that parsing produces the correct AST structure
-	t.Run("format only", func(t *testing.T) {
-		node, err := ParseExpression("<bH")
-		if err != nil {
-			t.Fatalf("ParseExpression() error = %v", err)
-		}
+func TestParseExpressionReversedPipe(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "object before format",
+			input: "{0 -> a} | <bH",
+			want:  "must follow a format expression",
+		},
+		{
+			name:  "write before format",
+			input: `write("out.bin") | <bH`,
+			want:  "must follow a format expression",
+		},
+		{
+			name:  "format after object",
+			input: "<bH | {0 -> a} | <bH",
+			want:  "sources must come first",
+		},
+		{
+			name:  "parse after pipe",
+			input: "<bH | parse(<bH)",
+			want:  "sources must come first",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpression(tt.input)
+			if err == nil {
+				t.Fatalf("ParseExpression(%q) expected error, got nil", tt.input)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseExpression(%q) error = %v, want it to contain %q", tt.input, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionAST(t *testing.T) {
+	// Test that parsing produces the correct AST structure
+	t.Run("format only", func(t *testing.T) {
+		node, err := ParseExpression("<bH")
+		if err != nil {
+			t.Fatalf("ParseExpression() error = %v", err)
+		}
 
 		formatNode, ok := node.(*FormatNode)
 		if !ok {
@@ -647,6 +1159,26 @@ func TestObjectNodeEval(t *testing.T) {
 			values:  []any{int8(1)},
 			wantErr: true,
 		},
+		{
+			name:       "empty object",
+			fields:     []FieldDef{},
+			values:     []any{int8(1)},
+			wantFields: []ObjectField{},
+			wantErr:    false,
+		},
+		{
+			name: "optional index out of range",
+			fields: []FieldDef{
+				{Index: 0, Name: "present", Optional: true},
+				{Index: 3, Name: "maybe", Optional: true},
+			},
+			values: []any{int8(1)},
+			wantFields: []ObjectField{
+				{Name: "present", Value: int8(1)},
+				{Name: "maybe", Value: nil},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -683,6 +1215,499 @@ func TestObjectNodeEval(t *testing.T) {
 	}
 }
 
+func TestObjectNodeUnmapped(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		count int
+		want  []int
+	}{
+		{"all mapped", "B | {0 -> a, 1 -> b}", 2, nil},
+		{"skipped index", "B | {0 -> a, 2 -> c}", 4, []int{1, 3}},
+		{"double-mapped index", "B | {0 -> a, 0 -> b}", 2, []int{1}},
+		{"nested and computed fields", "B | {0 -> a, n: {1 -> b}, c: -$2 + 1}", 4, []int{3}},
+		{"optional beyond the values", "B | {0 -> a, 5? -> z}", 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpressionWithOptions(tt.input, Options{WarnUnmapped: true})
+			if err != nil {
+				t.Fatalf("ParseExpressionWithOptions() error = %v", err)
+			}
+			obj, ok := node.(*PipeNode).Right.(*ObjectNode)
+			if !ok || !obj.WarnUnmapped {
+				t.Fatalf("ParseExpressionWithOptions() right = %#v, want an *ObjectNode warning about unmapped values", node.(*PipeNode).Right)
+			}
+			if got := obj.unmapped(tt.count); !slices.Equal(got, tt.want) {
+				t.Errorf("unmapped(%d) = %v, want %v", tt.count, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectLookup(t *testing.T) {
+	result, err := EvalBytes("<BBH | {0 -> a, 1 -> a, header: {2 -> length}}", []byte{0x01, 0x02, 0x03, 0x00})
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	obj := result.(*Object)
+
+	if got, want := obj.Keys(), []string{"a", "a", "header"}; !slices.Equal(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		path string
+		want any
+		ok   bool
+	}{
+		{"a", uint8(2), true}, // the last of the repeated names wins
+		{"header.length", uint16(3), true},
+		{"header", &Object{Fields: []ObjectField{{Name: "length", Value: uint16(3)}}}, true},
+		{"missing", nil, false},
+		{"header.missing", nil, false},
+		{"a.length", nil, false}, // not an object
+	}
+	for _, tt := range tests {
+		got, ok := obj.GetPath(tt.path)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetPath(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if got, ok := obj.Get("a"); !ok || got != uint8(2) {
+		t.Errorf("Get(\"a\") = %v, %v, want 2, true", got, ok)
+	}
+	if got := obj.Map()["a"]; got != uint8(2) {
+		t.Errorf("Map()[\"a\"] = %v, want the same field as Get", got)
+	}
+}
+
+func TestOptionalIndexField(t *testing.T) {
+	node, err := ParseExpression("<BB | {0 -> a, 2? -> maybe, n: {3? -> deep}}")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	obj := node.(*PipeNode).Right.(*ObjectNode)
+	if obj.Fields[0].Optional || !obj.Fields[1].Optional || !obj.Fields[2].Nested.Fields[0].Optional {
+		t.Errorf("Optional = %v, %v, %v, want false, true, true",
+			obj.Fields[0].Optional, obj.Fields[1].Optional, obj.Fields[2].Nested.Fields[0].Optional)
+	}
+
+	// The optional fields of a short record are null, and the rest unchanged
+	result, err := node.Eval(bytes.NewReader([]byte{0x01, 0x02}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ResultToJSON(&buf, result); err != nil {
+		t.Fatalf("ResultToJSON() error = %v", err)
+	}
+	if got, want := buf.String(), `{"a":1,"maybe":null,"n":{"deep":null}}`+"\n"; got != want {
+		t.Errorf("ResultToJSON() = %s, want %s", got, want)
+	}
+
+	if _, err := ParseExpression("<BB | {0 ? -> a}"); err != nil {
+		t.Errorf("ParseExpression() with spaced '?' error = %v", err)
+	}
+	if _, err := ParseExpression("<BB | {0 -> a?}"); err == nil {
+		t.Error("ParseExpression() expected error for '?' after the name, got nil")
+	}
+}
+
+func TestRenderHints(t *testing.T) {
+	node, err := ParseExpression("<HH2Bb | {0 -> id #hex, 1 -> count #dec, 2 -> raw #dec, 3 -> neg #hex, dbl: $1 * 2 #hex, 1 -> plain}")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte{0x2a, 0x00, 0x03, 0x00, 0x01, 0x02, 0xff}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{
+		"id         H      uint16                 0x002a               0x002a",
+		"count      H      uint16                      3               0x0003",
+		"raw        B      []uint8                 [1 2]              [01 02]",
+		"neg        b      int8                     0xff                 0xff",
+		"dbl        q      int64      0x0000000000000006   0x0000000000000006",
+		"plain      H      uint16                      3               0x0003",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, output)
+		}
+	}
+
+	for _, input := range []string{
+		"<H | {0 -> id #oct}",
+		"<H | {0 -> id #}",
+		"<H | {0 -> id hex}",
+		"<H | {0 #hex -> id}",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestFieldNamesStartingWithFormatCodes(t *testing.T) {
+	// Each name starts with a format code followed by another format code letter,
+	// which the tokenizer would otherwise split into separate codes
+	for _, code := range "bBhHiIqQslL" {
+		name := string(code) + "ize"
+
+		t.Run(name, func(t *testing.T) {
+			inputs := map[string][]string{
+				"<bH | {0 -> " + name + ", 1 -> Qty}":       {name, "Qty"},
+				"<bH | {" + name + ": {0 -> bits}}":         {name},
+				"<bH | {" + name + ": $1 + 1, 0 -> Hash}":   {name, "Hash"},
+				"<b:" + name + " H:Index":                   {name, "Index"},
+				"<bH | {0 -> Qty, " + name + ": {1 -> qq}}": {"Qty", name},
+			}
+
+			for input, want := range inputs {
+				node, err := ParseExpression(input)
+				if err != nil {
+					t.Fatalf("ParseExpression(%q) error = %v", input, err)
+				}
+				result, err := node.Eval(bytes.NewReader([]byte{0x01, 0x02, 0x00}), nil)
+				if err != nil {
+					t.Fatalf("Eval(%q) error = %v", input, err)
+				}
+
+				obj := result.(*Object)
+				if len(obj.Fields) != len(want) {
+					t.Fatalf("%q: got %d fields, want %d", input, len(obj.Fields), len(want))
+				}
+				for i, field := range obj.Fields {
+					if field.Name != want[i] {
+						t.Errorf("%q: field %d name = %q, want %q", input, i, field.Name, want[i])
+					}
+				}
+			}
+		})
+	}
+
+	// Format codes themselves are still split outside name positions
+	node, err := ParseExpression("<bi | {0 -> b, 1 -> i}")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	if formats := node.(*PipeNode).Left.(*FormatNode).Formats; len(formats) != 2 {
+		t.Errorf("ParseExpression() parsed %d format codes, want 2", len(formats))
+	}
+}
+
+func TestInlineNamedFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		data       []byte
+		wantFields []ObjectField
+		wantErr    bool
+	}{
+		{
+			name:  "all codes named",
+			input: "<b:key H:value",
+			data:  []byte{0xFF, 0x01, 0x02},
+			wantFields: []ObjectField{
+				{Name: "key", Value: int8(-1)},
+				{Name: "value", Value: uint16(0x0201)},
+			},
+		},
+		{
+			name:  "unnamed codes use index",
+			input: "<b:key H",
+			data:  []byte{0xFF, 0x01, 0x02},
+			wantFields: []ObjectField{
+				{Name: "key", Value: int8(-1)},
+				{Name: "1", Value: uint16(0x0201)},
+			},
+		},
+		{
+			name:  "named array",
+			input: "<2B:magic",
+			data:  []byte{0x89, 0x50},
+			wantFields: []ObjectField{
+				{Name: "magic", Value: []uint8{0x89, 0x50}},
+			},
+		},
+		{
+			name:  "combined with pipe and object",
+			input: "<b:key H:value | {1 -> v}",
+			data:  []byte{0xFF, 0x01, 0x02},
+			wantFields: []ObjectField{
+				{Name: "v", Value: uint16(0x0201)},
+			},
+		},
+		{
+			name:    "missing name after colon",
+			input:   "<b:",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+
+			obj, ok := result.(*Object)
+			if !ok {
+				t.Fatalf("Eval() result type = %T, want *Object", result)
+			}
+			if len(obj.Fields) != len(tt.wantFields) {
+				t.Fatalf("Eval() fields len = %d, want %d", len(obj.Fields), len(tt.wantFields))
+			}
+			for i, f := range obj.Fields {
+				if f.Name != tt.wantFields[i].Name || !compareValues(f.Value, tt.wantFields[i].Value) {
+					t.Errorf("Eval() field[%d] = %v, want %v", i, f, tt.wantFields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMarkNodeEval(t *testing.T) {
+	node, err := ParseExpression(`<bH | mark("end") | {0 -> a, 1 -> b, 2 -> end}`)
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+
+	result, err := node.Eval(bytes.NewReader([]byte{0xFF, 0x01, 0x02, 0x03}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	obj, ok := result.(*Object)
+	if !ok {
+		t.Fatalf("Eval() result type = %T, want *Object", result)
+	}
+	want := []ObjectField{
+		{Name: "a", Value: int8(-1)},
+		{Name: "b", Value: uint16(0x0201)},
+		{Name: "end", Value: Mark{Name: "end", Offset: 3}},
+	}
+	if len(obj.Fields) != len(want) {
+		t.Fatalf("Eval() fields len = %d, want %d", len(obj.Fields), len(want))
+	}
+	for i, f := range obj.Fields {
+		if f != want[i] {
+			t.Errorf("Eval() field[%d] = %v, want %v", i, f, want[i])
+		}
+	}
+
+	// Marks are zero-width when written
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, Mark{Name: "end", Offset: 3}, binary.LittleEndian); err != nil || buf.Len() != 0 {
+		t.Errorf("encodeValue(Mark) wrote %d bytes, err = %v, want 0 bytes", buf.Len(), err)
+	}
+}
+
+func TestPrettyPrintMark(t *testing.T) {
+	node, err := ParseExpression(`<bH | mark("end")`)
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+
+	result, err := node.Eval(bytes.NewReader([]byte{0xFF, 0x01, 0x02}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+	for _, want := range []string{"end", "mark", "3", "0x0000000000000003"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestMarkParseErrors(t *testing.T) {
+	for _, input := range []string{`mark("x") | <bH`, `<bH | mark()`, `<bH | mark("")`, `<bH | mark(end)`} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestFieldsNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []any
+		wantErr bool
+	}{
+		{
+			name:  "keep and renumber",
+			input: "<bHI | fields(0, 2)",
+			data:  []byte{0xFF, 0x01, 0x02, 0x03, 0x00, 0x00, 0x00},
+			want:  []any{int8(-1), uint32(3)},
+		},
+		{
+			name:  "reorder",
+			input: "<bH | fields(1, 0)",
+			data:  []byte{0xFF, 0x01, 0x02},
+			want:  []any{uint16(0x0201), int8(-1)},
+		},
+		{
+			name:    "out of range",
+			input:   "<bH | fields(2)",
+			data:    []byte{0xFF, 0x01, 0x02},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := result.([]any)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Eval() len = %d, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Eval()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	// The selected values compose with an object and print with their own types
+	node, err := ParseExpression("<bHI | fields(0, 2) | {0 -> a, 1 -> b}")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	if _, err := node.Eval(bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7}), nil); err != nil {
+		t.Errorf("Eval() error = %v", err)
+	}
+
+	node, _ = ParseExpression("<bHI | fields(2)")
+	result, _ := node.Eval(bytes.NewReader([]byte{1, 2, 3, 4, 0, 0, 0}), nil)
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "uint32") {
+		t.Errorf("PrettyPrintResult() output missing selected type\nGot:\n%s", buf.String())
+	}
+
+	for _, input := range []string{"<bH | fields()", "<bH | fields(a)", "<bH | fields(0"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestSliceNodeEval(t *testing.T) {
+	data := []byte{0xFF, 0x01, 0x02, 0x03, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr string
+	}{
+		{
+			name:  "middle range",
+			input: "parse(<bHiI) | slice(1, 3)",
+			want:  []any{uint16(0x0201), int32(3)},
+		},
+		{
+			name:  "whole range",
+			input: "parse(<bHiI) | slice(0, 4)",
+			want:  []any{int8(-1), uint16(0x0201), int32(3), uint32(4)},
+		},
+		{
+			name:  "empty range",
+			input: "parse(<bHiI) | slice(2, 2)",
+			want:  []any{},
+		},
+		{
+			name:  "negative indices",
+			input: "parse(<bHiI) | slice(-3, -1)",
+			want:  []any{uint16(0x0201), int32(3)},
+		},
+		{
+			name:  "composes with an object",
+			input: "parse(<bHiI) | slice(1, 3) | {0 -> x, 1 -> y}",
+			want:  &Object{Fields: []ObjectField{{Name: "x", Value: uint16(0x0201)}, {Name: "y", Value: int32(3)}}},
+		},
+		{
+			name:    "end past the values",
+			input:   "parse(<bHiI) | slice(1, 5)",
+			wantErr: "slice: range 1:5 out of range (have 4 values)",
+		},
+		{
+			name:    "negative start before the values",
+			input:   "parse(<bHiI) | slice(-5, 2)",
+			wantErr: "slice: range -5:2 out of range (have 4 values)",
+		},
+		{
+			name:    "reversed range",
+			input:   "parse(<bHiI) | slice(3, 1)",
+			wantErr: "slice: range 3:1 out of range (have 4 values)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, data)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("EvalBytes() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("EvalBytes() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"<bH | slice(1)", "<bH | slice(a, 1)", "<bH | slice(0, 1"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
 func TestPipeNodeEval(t *testing.T) {
 	// Test full pipeline: FormatNode | ObjectNode
 	data := []byte{0xFF, 0x01, 0x02}
@@ -723,62 +1748,272 @@ func TestPipeNodeEval(t *testing.T) {
 	}
 }
 
-func TestPrettyPrintResult(t *testing.T) {
+func TestPrettyPrintResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		data     []byte
+		contains []string
+	}{
+		{
+			name:  "format only",
+			input: "<bH",
+			data:  []byte{0xFF, 0x01, 0x02},
+			contains: []string{
+				"Name", "Code", "Type", "Value", "Hex",
+				"0", "b", "int8", "-1", "0xff",
+				"1", "H", "uint16", "513", "0x0201",
+			},
+		},
+		{
+			name:  "pipe with object",
+			input: "<bH | {0 -> key, 1 -> value}",
+			data:  []byte{0xFF, 0x01, 0x02},
+			contains: []string{
+				"Name", "Code", "Type", "Value", "Hex",
+				"key", "b", "int8", "-1", "0xff",
+				"value", "H", "uint16", "513", "0x0201",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := PrettyPrintResult(&buf, node, result); err != nil {
+				t.Fatalf("PrettyPrintResult() error = %v", err)
+			}
+
+			output := buf.String()
+			for _, want := range tt.contains {
+				if !bytes.Contains([]byte(output), []byte(want)) {
+					t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestPrettyPrintCombinedHex(t *testing.T) {
+	node, err := ParseExpression("<bHs2B")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte{0xFF, 0x01, 0x02, 'h', 'i', 0x00, 0x01, 0x02}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResultWithOptions(&buf, node, result, Options{CombinedHex: true}); err != nil {
+		t.Fatalf("PrettyPrintResultWithOptions() error = %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"-1 (0xff)", "513 (0x0201)", " hi\n", " [01 02]\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("PrettyPrintResultWithOptions() output missing %q\nGot:\n%s", want, output)
+		}
+	}
+	for _, unwanted := range []string{"Hex", "[68 69]"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("PrettyPrintResultWithOptions() output contains %q\nGot:\n%s", unwanted, output)
+		}
+	}
+}
+
+func TestPrettyPrintStringArray(t *testing.T) {
+	node, err := ParseExpression("<H | string_array(0)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte("\x02\x00foo\x00bar\x00")), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+
+	// Each string gets its own row below the array
+	output := buf.String()
+	for _, want := range []string{"0          H      uint16", "1          s      []string", "  0        s      string                    foo", "  1        s      string                    bar"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, output)
+		}
+	}
+}
+
+func TestPrettyPrintFloatPrecision(t *testing.T) {
+	result := &Object{Fields: []ObjectField{
+		{Name: "ratio", Value: float64(1) / 3},
+		{Name: "single", Value: float32(0.1)},
+		{Name: "samples", Value: []float32{1.5, 2.25}},
+	}}
+
+	tests := []struct {
+		name      string
+		precision int
+		contains  []string
+	}{
+		{
+			name:      "default round-trip form",
+			precision: 0,
+			contains:  []string{"0.3333333333333333", "0.1", "[1.5 2.25]"},
+		},
+		{
+			name:      "two digits",
+			precision: 2,
+			contains:  []string{"0.33", "0.10", "[1.50 2.25]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := PrettyPrintResultWithOptions(&buf, nil, result, Options{FloatPrecision: tt.precision}); err != nil {
+				t.Fatalf("PrettyPrintResultWithOptions() error = %v", err)
+			}
+
+			output := buf.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(output, want) {
+					t.Errorf("PrettyPrintResultWithOptions() output missing %q\nGot:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestPrettyPrintArrayLen(t *testing.T) {
+	node, err := ParseExpression("<b4B | string_array(0)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte("\x02\x01\x02\x03\x04a\x00b\x00")), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	object := &Object{Fields: []ObjectField{
+		{Name: "samples", Value: []float32{1.5, 2.25, 3}},
+		{Name: "readings", Value: []float64{0.5, 1}},
+		{Name: "ids", Value: []*big.Int{big.NewInt(-1)}},
+	}}
+
 	tests := []struct {
 		name     string
-		input    string
-		data     []byte
+		arrayLen bool
 		contains []string
 	}{
 		{
-			name:  "format only",
-			input: "<bH",
-			data:  []byte{0xFF, 0x01, 0x02},
-			contains: []string{
-				"Name", "Code", "Type", "Value", "Hex",
-				"0", "b", "int8", "-1", "0xff",
-				"1", "H", "uint16", "513", "0x0201",
-			},
+			name:     "default",
+			arrayLen: false,
+			contains: []string{"1          B      uint8 ", "2          s      []string", "samples    f      []float32", "readings   d      []float64", "ids        o      []int128"},
 		},
 		{
-			name:  "pipe with object",
-			input: "<bH | {0 -> key, 1 -> value}",
-			data:  []byte{0xFF, 0x01, 0x02},
-			contains: []string{
-				"Name", "Code", "Type", "Value", "Hex",
-				"key", "b", "int8", "-1", "0xff",
-				"value", "H", "uint16", "513", "0x0201",
-			},
+			name:     "with lengths",
+			arrayLen: true,
+			contains: []string{"0          b      int8 ", "1          B      [4]uint8", "2          s      [2]string", "samples    f      [3]float32", "readings   d      [2]float64", "ids        o      [1]int128"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			node, err := ParseExpression(tt.input)
-			if err != nil {
-				t.Fatalf("ParseExpression() error = %v", err)
-			}
-
-			result, err := node.Eval(bytes.NewReader(tt.data), nil)
-			if err != nil {
-				t.Fatalf("Eval() error = %v", err)
-			}
-
 			var buf bytes.Buffer
-			if err := PrettyPrintResult(&buf, node, result); err != nil {
-				t.Fatalf("PrettyPrintResult() error = %v", err)
+			opts := Options{ArrayLen: tt.arrayLen}
+			if err := PrettyPrintResultWithOptions(&buf, node, result, opts); err != nil {
+				t.Fatalf("PrettyPrintResultWithOptions() error = %v", err)
+			}
+			if err := PrettyPrintResultWithOptions(&buf, nil, object, opts); err != nil {
+				t.Fatalf("PrettyPrintResultWithOptions() error = %v", err)
 			}
 
 			output := buf.String()
 			for _, want := range tt.contains {
-				if !bytes.Contains([]byte(output), []byte(want)) {
-					t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, output)
+				if !strings.Contains(output, want) {
+					t.Errorf("PrettyPrintResultWithOptions() output missing %q\nGot:\n%s", want, output)
 				}
 			}
 		})
 	}
 }
 
+func TestPrettyPrintColumns(t *testing.T) {
+	result := &Object{Fields: []ObjectField{{Name: "a", Value: int8(-1)}, {Name: "b", Value: uint16(513)}}}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "name and value",
+			opts: Options{Columns: []string{"name", "value"}},
+			want: "Name                      Value\n" +
+				"-------------------------------\n" +
+				"a                            -1\n" +
+				"b                           513\n",
+		},
+		{
+			name: "reordered",
+			opts: Options{Columns: []string{"hex", "name"}},
+			want: "                 Hex Name      \n" +
+				"-------------------------------\n" +
+				"                0xff a         \n" +
+				"              0x0201 b         \n",
+		},
+		{
+			name: "combined hex drops the hex column",
+			opts: Options{Columns: []string{"value", "hex"}, CombinedHex: true},
+			want: "                                    Value\n" +
+				"-----------------------------------------\n" +
+				"                                -1 (0xff)\n" +
+				"                             513 (0x0201)\n",
+		},
+		{name: "unknown column", opts: Options{Columns: []string{"name", "size"}}, wantErr: true},
+		{name: "duplicate column", opts: Options{Columns: []string{"name", "name"}}, wantErr: true},
+		{name: "only the hex column when combined", opts: Options{Columns: []string{"hex"}, CombinedHex: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := PrettyPrintResultWithOptions(&buf, nil, result, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PrettyPrintResultWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && buf.String() != tt.want {
+				t.Errorf("PrettyPrintResultWithOptions() =\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	// The default columns are the full set
+	var def, all bytes.Buffer
+	if err := PrettyPrintResult(&def, nil, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+	if err := PrettyPrintResultWithOptions(&all, nil, result, Options{Columns: TableColumns}); err != nil {
+		t.Fatalf("PrettyPrintResultWithOptions() error = %v", err)
+	}
+	if def.String() != all.String() {
+		t.Errorf("default columns =\n%s\nwant:\n%s", def.String(), all.String())
+	}
+}
+
 func TestInferTypeInfo(t *testing.T) {
 	tests := []struct {
 		val      any
@@ -793,6 +2028,10 @@ func TestInferTypeInfo(t *testing.T) {
 		{uint32(0), 'I', "uint32"},
 		{int64(0), 'q', "int64"},
 		{uint64(0), 'Q', "uint64"},
+		{big.NewInt(-1), 'o', "int128"},
+		{big.NewInt(1), 'O', "uint128"},
+		{float32(0), 'f', "float32"},
+		{float64(0), 'd', "float64"},
 		{"hello", 's', "string"},
 		{struct{}{}, '?', "unknown"},
 		// Array types
@@ -804,6 +2043,8 @@ func TestInferTypeInfo(t *testing.T) {
 		{[]uint32{}, 'I', "[]uint32"},
 		{[]int64{}, 'q', "[]int64"},
 		{[]uint64{}, 'Q', "[]uint64"},
+		{[]float32{}, 'f', "[]float32"},
+		{[]float64{}, 'd', "[]float64"},
 	}
 
 	for _, tt := range tests {
@@ -861,6 +2102,17 @@ func TestParseWithArrayCount(t *testing.T) {
 			format:  "4",
 			wantErr: true,
 		},
+		{
+			name:      "fixed-length string at the string limit",
+			format:    "65536s",
+			wantCount: []int{65536},
+			wantCodes: []rune{'s'},
+		},
+		{
+			name:    "fixed-length string over the string limit",
+			format:  "9223372036854775807s",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -917,6 +2169,12 @@ func TestExpr_ReadArray(t *testing.T) {
 			data:   []byte{0x00, 0x01, 0x00, 0x02},
 			want:   []any{[]uint16{1, 2}},
 		},
+		{
+			name:   "2 floats big endian",
+			format: ">2f",
+			data:   []byte{0x3F, 0x80, 0x00, 0x00, 0xC0, 0x20, 0x00, 0x00},
+			want:   []any{[]float32{1, -2.5}},
+		},
 		{
 			name:   "mixed single and array",
 			format: "<b4B",
@@ -973,6 +2231,83 @@ func TestExpr_ReadArray(t *testing.T) {
 	}
 }
 
+func TestExpr_ReadRest(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    []byte
+		want    []any
+		wantErr bool
+	}{
+		{
+			name:   "empty input",
+			format: "*B",
+			data:   []byte{},
+			want:   []any{[]uint8{}},
+		},
+		{
+			name:   "bytes until EOF",
+			format: "*B",
+			data:   []byte{0x01, 0x02, 0x03},
+			want:   []any{[]uint8{1, 2, 3}},
+		},
+		{
+			name:   "exact multiple after a header",
+			format: "<H*H",
+			data:   []byte{0x02, 0x00, 0x01, 0x00, 0x02, 0x00},
+			want:   []any{uint16(2), []uint16{1, 2}},
+		},
+		{
+			name:   "big endian ints",
+			format: ">b*i",
+			data:   []byte{0xFF, 0x00, 0x00, 0x00, 0x01},
+			want:   []any{int8(-1), []int32{1}},
+		},
+		{
+			name:    "trailing partial element",
+			format:  "<*H",
+			data:    []byte{0x01, 0x00, 0x02},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			got, err := expr.Read(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("Read() error = %v, want io.ErrUnexpectedEOF", err)
+				}
+				return
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Read() len = %v, want %v", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !compareValues(got[i], tt.want[i]) {
+					t.Errorf("Read() [%d] = %v (%T), want %v (%T)", i, got[i], got[i], tt.want[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	// Codes after a * count would never get data, and * needs a fixed size
+	for _, format := range []string{"*BH", "*B<H", "*s", "*x", "4*B", "*"} {
+		if _, err := Parse(format); err == nil {
+			t.Errorf("Parse(%q) expected error, got nil", format)
+		}
+	}
+}
+
 // compareValues compares two values, handling slices specially.
 func compareValues(a, b any) bool {
 	switch av := a.(type) {
@@ -1064,6 +2399,12 @@ func compareValues(a, b any) bool {
 			}
 		}
 		return true
+	case []float32:
+		bv, ok := b.([]float32)
+		return ok && slices.Equal(av, bv)
+	case []float64:
+		bv, ok := b.([]float64)
+		return ok && slices.Equal(av, bv)
 	default:
 		return a == b
 	}
@@ -1226,7 +2567,7 @@ func TestParseFunctionWithPipe(t *testing.T) {
 
 func TestTokenizerColon(t *testing.T) {
 	// Test that colon token is recognized
-	tokenizer := NewTokenizer("nested: {0 -> x}")
+	tokenizer := NewTokenizer("nested: {0 -> y}")
 
 	tokens := []Token{
 		{Type: TokenIdent, Value: "nested"},
@@ -1234,7 +2575,7 @@ func TestTokenizerColon(t *testing.T) {
 		{Type: TokenLBrace, Value: "{"},
 		{Type: TokenNumber, Value: "0"},
 		{Type: TokenArrow, Value: "->"},
-		{Type: TokenIdent, Value: "x"},
+		{Type: TokenIdent, Value: "y"},
 		{Type: TokenRBrace, Value: "}"},
 		{Type: TokenEOF},
 	}
@@ -1255,7 +2596,7 @@ func TestTokenizerColon(t *testing.T) {
 
 func TestTokenizerNestedObject(t *testing.T) {
 	// Test tokenizing a full nested object expression
-	input := "<bHB | {0 -> header, nested: {1 -> length, 2 -> flag}}"
+	input := "<bHB | {0 -> header, nested: {1 -> length, 2 -> kind}}"
 	tokenizer := NewTokenizer(input)
 
 	expectedTokens := []Token{
@@ -1278,7 +2619,7 @@ func TestTokenizerNestedObject(t *testing.T) {
 		{Type: TokenComma, Value: ","},
 		{Type: TokenNumber, Value: "2"},
 		{Type: TokenArrow, Value: "->"},
-		{Type: TokenIdent, Value: "flag"},
+		{Type: TokenIdent, Value: "kind"},
 		{Type: TokenRBrace, Value: "}"},
 		{Type: TokenRBrace, Value: "}"},
 		{Type: TokenEOF},
@@ -1324,6 +2665,16 @@ func TestParseExpressionNestedObject(t *testing.T) {
 			input:   "<bHiI | {first: {0 -> a, 1 -> b}, second: {2 -> c, 3 -> d}}",
 			wantErr: false,
 		},
+		{
+			name:    "empty object",
+			input:   "bH | {}",
+			wantErr: false,
+		},
+		{
+			name:    "empty nested object",
+			input:   "bH | {0 -> x, empty: {}}",
+			wantErr: false,
+		},
 		{
 			name:    "nested missing colon",
 			input:   "bH | {nested {0 -> x}}",
@@ -1625,6 +2976,15 @@ func TestPrettyPrintNestedObject(t *testing.T) {
 				"flag", "B", "uint8", "3", "0x03",
 			},
 		},
+		{
+			name:  "empty nested object",
+			input: "bH | {0 -> x, empty: {}}",
+			data:  []byte{0x01, 0x02, 0x00},
+			contains: []string{
+				"x", "b", "int8", "1", "0x01",
+				"empty", "object",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1839,7 +3199,7 @@ func TestReadNullTerminatedString(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := readNullTerminatedString(bytes.NewReader(tt.data))
+			got, err := readNullTerminatedString(bytes.NewReader(tt.data), DefaultMaxStringLen)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("readNullTerminatedString() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1851,6 +3211,34 @@ func TestReadNullTerminatedString(t *testing.T) {
 	}
 }
 
+func TestReadNullTerminatedStringLimit(t *testing.T) {
+	long := bytes.Repeat([]byte{'a'}, 128)
+
+	// Exactly at the limit with a terminator is fine
+	got, err := readNullTerminatedString(bytes.NewReader(append(long, 0)), len(long))
+	if err != nil {
+		t.Fatalf("readNullTerminatedString() unexpected error = %v", err)
+	}
+	if got != string(long) {
+		t.Errorf("readNullTerminatedString() = %q, want %q", got, long)
+	}
+
+	// A NUL-less buffer beyond the limit errors
+	_, err = readNullTerminatedString(bytes.NewReader(long), 64)
+	if err == nil || !strings.Contains(err.Error(), "unterminated string exceeds limit") {
+		t.Errorf("readNullTerminatedString() error = %v, want limit error", err)
+	}
+
+	// The limit is threaded from the parser options into Expr.Read
+	node, err := ParseExpressionWithOptions("s", Options{MaxStringLen: 16})
+	if err != nil {
+		t.Fatalf("ParseExpressionWithOptions() error = %v", err)
+	}
+	if _, err := node.Eval(bytes.NewReader(long), nil); err == nil {
+		t.Error("Eval() expected limit error, got nil")
+	}
+}
+
 func TestExpr_ReadString(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1883,6 +3271,24 @@ func TestExpr_ReadString(t *testing.T) {
 			data:   []byte{'x', 0, 0xFF},
 			want:   []any{"x", uint8(255)},
 		},
+		{
+			name:   "fixed-length string exact fit",
+			format: "4sB",
+			data:   []byte{'a', 'b', 'c', 'd', 0x07},
+			want:   []any{"abcd", uint8(7)},
+		},
+		{
+			name:   "fixed-length string padded with NULs",
+			format: "6sB",
+			data:   []byte{'a', 'b', 0, 0, 0, 0, 0x07},
+			want:   []any{"ab", uint8(7)},
+		},
+		{
+			name:    "fixed-length string past the input",
+			format:  "8s",
+			data:    []byte{'a', 'b', 'c'},
+			wantErr: true,
+		},
 		{
 			name:    "empty string",
 			format:  "s",
@@ -2305,6 +3711,139 @@ func TestParseExpressionWriteAST(t *testing.T) {
 	})
 }
 
+// reverseFields is an object transform reversing the field order, for testing.
+type reverseFields struct{}
+
+func (reverseFields) Eval(_ io.Reader, _ []any) (any, error) {
+	return nil, errors.New("reverseFields must transform an object")
+}
+
+func (reverseFields) EvalObject(_ io.Reader, obj *Object) (any, error) {
+	out := &Object{}
+	for i := len(obj.Fields) - 1; i >= 0; i-- {
+		out.Fields = append(out.Fields, obj.Fields[i])
+	}
+	return out, nil
+}
+
+func TestPipeChainedTransforms(t *testing.T) {
+	data := []byte{0xFF, 0x01, 0x02}
+
+	// Each stage receives the result of the stages before it
+	result, err := EvalBytes("<bH | {0 -> a, 1 -> b} | {1 -> second, 0 -> first} | {0 -> only}", data)
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	obj := result.(*Object)
+	if len(obj.Fields) != 1 || obj.Fields[0].Name != "only" || obj.Fields[0].Value != uint16(513) {
+		t.Errorf("EvalBytes() = %v, want {only: 513}", obj.Fields)
+	}
+
+	// An object transform receives the object with its field names
+	node, err := ParseExpression("<bH | {0 -> a, 1 -> b}")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	chained := &PipeNode{Left: &PipeNode{Left: node, Right: reverseFields{}}, Right: &FieldsNode{Indices: []int{0}}}
+	result, err = chained.Eval(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if values := result.([]any); len(values) != 1 || values[0] != uint16(513) {
+		t.Errorf("Eval() = %v, want [513]", values)
+	}
+
+	// Plain values cannot feed an object transform
+	format, _ := ParseExpression("<bH")
+	if _, err := (&PipeNode{Left: format, Right: reverseFields{}}).Eval(bytes.NewReader(data), nil); err == nil {
+		t.Error("Eval() expected error for values piped into an object transform, got nil")
+	}
+}
+
+func TestRenameNode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"rename keeps position", "<bH | {0 -> a, 1 -> b} | rename(a, id)", "id=-1 b=513", false},
+		{"chained renames", "<bH | {0 -> a, 1 -> b} | rename(a, hb) | rename(b, bH)", "hb=-1 bH=513", false},
+		{"inline names", "<b:key H:value | rename(value, v)", "key=-1 v=513", false},
+		{"missing field", "<bH | {0 -> a, 1 -> b} | rename(c, d)", "", true},
+		{"existing field", "<bH | {0 -> a, 1 -> b} | rename(a, b)", "", true},
+		{"plain values", "<bH | rename(a, b)", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, []byte{0xFF, 0x01, 0x02})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var fields []string
+			for _, f := range result.(*Object).Fields {
+				fields = append(fields, fmt.Sprintf("%s=%v", f.Name, f.Value))
+			}
+			if got := strings.Join(fields, " "); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"<bH | rename(a)", "<bH | rename(a, 1)", "<bH | rename(a, b", "rename(a, b)"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestDropNode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"single field", "<bHI | {0 -> id, 1 -> padding, 2 -> size} | drop(padding)", "id=-1 size=3", false},
+		{"several fields", "<bHI | {0 -> id, 1 -> padding, 2 -> size} | drop(size, id)", "padding=513", false},
+		{"all fields", "<bHI | {0 -> id, 1 -> padding, 2 -> size} | drop(id, padding, size)", "", false},
+		{"after rename", "<bHI | {0 -> id, 1 -> padding, 2 -> size} | rename(padding, reserved) | drop(reserved)", "id=-1 size=3", false},
+		{"missing field", "<bHI | {0 -> id, 1 -> padding, 2 -> size} | drop(flags)", "", true},
+		{"plain values", "<bHI | drop(id)", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, []byte{0xFF, 0x01, 0x02, 0x03, 0x00, 0x00, 0x00})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var fields []string
+			for _, f := range result.(*Object).Fields {
+				fields = append(fields, fmt.Sprintf("%s=%v", f.Name, f.Value))
+			}
+			if got := strings.Join(fields, " "); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"<bH | drop()", "<bH | drop(a,)", "<bH | drop(a", "drop(a)"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
 func TestEncodeValue(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -2409,6 +3948,12 @@ func TestEncodeValue(t *testing.T) {
 			order: binary.BigEndian,
 			want:  []byte{0x01, 0x02, 0x03, 0x04},
 		},
+		{
+			name:    "int128 overflow",
+			val:     new(big.Int).Lsh(big.NewInt(1), 128),
+			order:   binary.LittleEndian,
+			wantErr: true,
+		},
 		{
 			name:    "unsupported type",
 			val:     struct{}{},
@@ -2470,20 +4015,57 @@ func TestWriteNodeEval(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ParseExpression error: %v", err)
 	}
-
+
+	data := []byte{0xFF, 0x01, 0x02}
+	_, err = node.Eval(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	// Verify written content
+	written, err := os.ReadFile(tmpPath)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if !bytes.Equal(written, data) {
+		t.Errorf("Written data = %v, want %v", written, data)
+	}
+}
+
+func TestWriteToStdout(t *testing.T) {
+	node, err := ParseExpression(`<bH | write("-")`)
+	if err != nil {
+		t.Fatalf("ParseExpression error: %v", err)
+	}
+	var stdout bytes.Buffer
 	data := []byte{0xFF, 0x01, 0x02}
-	_, err = node.Eval(bytes.NewReader(data), nil)
-	if err != nil {
+	if _, err := node.Eval(WithStdout(bytes.NewReader(data), &stdout), nil); err != nil {
 		t.Fatalf("Eval error: %v", err)
 	}
+	if !bytes.Equal(stdout.Bytes(), data) {
+		t.Errorf("Written data = %v, want %v", stdout.Bytes(), data)
+	}
+	if _, err := os.Stat("-"); err == nil {
+		t.Error("write(\"-\") created a file named -")
+	}
+}
 
-	// Verify written content
-	written, err := os.ReadFile(tmpPath)
+func TestWriteToStdoutPerEval(t *testing.T) {
+	// One parsed node writes wherever each evaluation says, records included
+	node, err := ParseExpression(`split(0x0A, B | write("-"))`)
 	if err != nil {
-		t.Fatalf("ReadFile error: %v", err)
+		t.Fatalf("ParseExpression error: %v", err)
 	}
-	if !bytes.Equal(written, data) {
-		t.Errorf("Written data = %v, want %v", written, data)
+
+	for _, data := range [][]byte{{0x01, 0x0A, 0x02}, {0x03}} {
+		var stdout bytes.Buffer
+		if _, err := node.Eval(WithStdout(bytes.NewReader(data), &stdout), nil); err != nil {
+			t.Fatalf("Eval error: %v", err)
+		}
+		want := bytes.ReplaceAll(data, []byte{0x0A}, nil)
+		if !bytes.Equal(stdout.Bytes(), want) {
+			t.Errorf("Written data = % x, want % x", stdout.Bytes(), want)
+		}
 	}
 }
 
@@ -2572,6 +4154,23 @@ func TestWriteByteOrderPropagation(t *testing.T) {
 	}
 }
 
+func TestWriteMixedByteOrder(t *testing.T) {
+	// A little-endian H, then a big-endian I
+	data := []byte{0x01, 0x80, 0x3F, 0x00, 0x01, 0x80}
+
+	node, err := ParseExpression(`<H>I | write("-")`)
+	if err != nil {
+		t.Fatalf("ParseExpression error: %v", err)
+	}
+	var stdout bytes.Buffer
+	if _, err := node.Eval(WithStdout(bytes.NewReader(data), &stdout), nil); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if !bytes.Equal(stdout.Bytes(), data) {
+		t.Errorf("Written data = % x, want % x", stdout.Bytes(), data)
+	}
+}
+
 func TestWriteWithArray(t *testing.T) {
 	tmpFile, err := os.CreateTemp("", "bq-test-*.bin")
 	if err != nil {
@@ -2602,6 +4201,103 @@ func TestWriteWithArray(t *testing.T) {
 	}
 }
 
+func TestPaddingIndexAlignment(t *testing.T) {
+	// The padding has no value, so index 1 is the uint16 after it
+	node, err := ParseExpression("<b4xH | {0 -> a, 1 -> b}")
+	if err != nil {
+		t.Fatalf("ParseExpression error: %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte{0x05, 0xAA, 0xBB, 0xCC, 0xDD, 0x34, 0x12}), nil)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	want := &Object{Fields: []ObjectField{{Name: "a", Value: int8(5)}, {Name: "b", Value: uint16(0x1234)}}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Eval() = %v, want %v", result, want)
+	}
+
+	// Inline names skip the padding too
+	node, err = ParseExpression("<b:a 2x H:b")
+	if err != nil {
+		t.Fatalf("ParseExpression error: %v", err)
+	}
+	result, err = node.Eval(bytes.NewReader([]byte{0x05, 0x00, 0x00, 0x34, 0x12}), nil)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Eval() = %v, want %v", result, want)
+	}
+
+	if _, err := ParseExpression("<b 2x:reserved"); err == nil {
+		t.Error("ParseExpression() of a named padding code should fail")
+	}
+}
+
+func TestWriteWithPadding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+	node, err := ParseExpression(fmt.Sprintf(`<B3xH | write("%s")`, path))
+	if err != nil {
+		t.Fatalf("ParseExpression error: %v", err)
+	}
+
+	// Padding is written back as zero bytes, whatever it was in the input
+	if _, err := node.Eval(bytes.NewReader([]byte{0x01, 0xAA, 0xBB, 0xCC, 0x02, 0x03}), nil); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if want := []byte{0x01, 0x00, 0x00, 0x00, 0x02, 0x03}; !bytes.Equal(written, want) {
+		t.Errorf("Written data = %v, want %v", written, want)
+	}
+}
+
+func TestWriteObjectByFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		data  []byte
+		want  []byte
+	}{
+		{
+			name:  "padding",
+			input: `<B2xH | {0 -> a, 1 -> b} | write("-")`,
+			data:  []byte{0x01, 0xAA, 0xBB, 0x02, 0x03},
+			want:  []byte{0x01, 0x00, 0x00, 0x02, 0x03},
+		},
+		{
+			name:  "bit-fields",
+			input: `B/4 B/4 | {0 -> hi, 1 -> lo} | write("-")`,
+			data:  []byte{0xAB},
+			want:  []byte{0xAB},
+		},
+		{
+			name:  "mixed byte order",
+			input: `<H>I | {0 -> a, 1 -> b} | write("-")`,
+			data:  []byte{0x01, 0x80, 0x3F, 0x00, 0x01, 0x80},
+			want:  []byte{0x01, 0x80, 0x3F, 0x00, 0x01, 0x80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression error: %v", err)
+			}
+			var stdout bytes.Buffer
+			if _, err := node.Eval(WithStdout(bytes.NewReader(tt.data), &stdout), nil); err != nil {
+				t.Fatalf("Eval error: %v", err)
+			}
+			if !bytes.Equal(stdout.Bytes(), tt.want) {
+				t.Errorf("Written data = % x, want % x", stdout.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
 func TestWriteWithString(t *testing.T) {
 	tmpFile, err := os.CreateTemp("", "bq-test-*.bin")
 	if err != nil {
@@ -2632,6 +4328,138 @@ func TestWriteWithString(t *testing.T) {
 	}
 }
 
+func TestFixedLengthStringNoTrim(t *testing.T) {
+	data := []byte{'a', 'b', 0, 0, 0, 0, 0x07}
+
+	// The trailing NULs are kept, giving the raw width of the field
+	node, err := ParseExpressionWithOptions("<6sB", Options{NoTrim: true})
+	if err != nil {
+		t.Fatalf("ParseExpressionWithOptions() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if want := []any{"ab\x00\x00\x00\x00", uint8(7)}; !reflect.DeepEqual(result, want) {
+		t.Errorf("Eval() = %q, want %q", result, want)
+	}
+
+	// and re-encoded as read
+	var buf bytes.Buffer
+	if err := Execute("<6sB", bytes.NewReader(data), &buf, Options{NoTrim: true, Verify: true, Check: true}); err != nil {
+		t.Errorf("Execute() with --verify error = %v", err)
+	}
+
+	// The table shows the NULs escaped
+	buf.Reset()
+	if err := Execute("<6sB", bytes.NewReader(data), &buf, Options{NoTrim: true, Pretty: true}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for _, want := range []string{`ab\0\0\0\0`, "[61 62 00 00 00 00]"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Execute() output missing %q\nGot:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestFixedLengthStringTrimSet(t *testing.T) {
+	// A field padded with spaces, then NULs
+	data := []byte{'a', 'b', ' ', ' ', 0, 0}
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"NULs only by default", Options{}, "ab  "},
+		{"spaces and NULs", Options{TrimSet: " "}, "ab"},
+		{"no trim wins", Options{TrimSet: " ", NoTrim: true}, "ab  \x00\x00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpressionWithOptions("6s", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseExpressionWithOptions() error = %v", err)
+			}
+			result, err := node.Eval(bytes.NewReader(data), nil)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if got := result.([]any)[0]; got != tt.want {
+				t.Errorf("Eval() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The trimmed spaces are not re-encoded, so a verification is rejected
+	var buf bytes.Buffer
+	err := Execute("6s", bytes.NewReader(data), &buf, Options{TrimSet: " ", Verify: true})
+	if err == nil || !strings.Contains(err.Error(), "--trim-set") {
+		t.Errorf("Execute() error = %v, want a --trim-set error", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Execute() output = %q, want none", buf.String())
+	}
+	if err := Execute("6s", bytes.NewReader(data), &buf, Options{TrimSet: " ", NoTrim: true, Verify: true, Check: true}); err != nil {
+		t.Errorf("Execute() with NoTrim error = %v", err)
+	}
+}
+
+func TestWriteFixedLengthString(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []byte
+	}{
+		{"exact fit", "abcd", []byte{'a', 'b', 'c', 'd', 0x07}},
+		{"short string padded", "ab", []byte{'a', 'b', 0, 0, 0x07}},
+		{"long string truncated", "abcdef", []byte{'a', 'b', 'c', 'd', 0x07}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.bin")
+			node, err := ParseExpression(fmt.Sprintf(`<4sB | write("%s")`, path))
+			if err != nil {
+				t.Fatalf("ParseExpression error: %v", err)
+			}
+			pipe := node.(*PipeNode)
+			write := pipe.Right.(*WriteNode)
+			write.Formats = pipe.Left.(*FormatNode).Formats
+			if _, err := write.Eval(nil, []any{tt.value, uint8(7)}); err != nil {
+				t.Fatalf("Eval error: %v", err)
+			}
+
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile error: %v", err)
+			}
+			if !bytes.Equal(written, tt.want) {
+				t.Errorf("Written data = %v, want %v", written, tt.want)
+			}
+		})
+	}
+
+	// Reading and writing through the pipe keeps the padding of the field
+	path := filepath.Join(t.TempDir(), "pipe.bin")
+	node, err := ParseExpression(fmt.Sprintf(`<6sB | write("%s")`, path))
+	if err != nil {
+		t.Fatalf("ParseExpression error: %v", err)
+	}
+	data := []byte{'h', 'i', 0, 0, 0, 0, 0x01}
+	if _, err := node.Eval(bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if !bytes.Equal(written, data) {
+		t.Errorf("Written data = %v, want %v", written, data)
+	}
+}
+
 // --- Search Pattern Tests ---
 
 func TestTokenizerQuestion(t *testing.T) {
@@ -2796,3 +4624,206 @@ func TestSearchWithPipe(t *testing.T) {
 		t.Errorf("expected value 2, got %v", obj.Fields[0].Value)
 	}
 }
+
+func TestByteOrderSizes(t *testing.T) {
+	data := []byte{0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xFF}
+	native := binary.NativeEndian
+
+	tests := []struct {
+		format string
+		want   []any
+		size   int // bytes consumed
+	}{
+		{"<i", []any{int32(1)}, 4},
+		{">i", []any{int32(0x01000000)}, 4},
+		{"@i", []any{int32(native.Uint32(data))}, 4},
+		{"=i", []any{int32(native.Uint32(data))}, 4},
+		// No alignment padding is inserted before the int after a char
+		{"<Bi", []any{uint8(1), int32(0x02000000)}, 5},
+		{">Bi", []any{uint8(1), int32(0x00000002)}, 5},
+		{"@Bi", []any{uint8(1), int32(native.Uint32(data[1:]))}, 5},
+		{"=Bi", []any{uint8(1), int32(native.Uint32(data[1:]))}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			r := bytes.NewReader(data)
+			got, err := expr.Read(r)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Read() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Read()[%d] = %v (%T), want %v (%T)", i, got[i], got[i], tt.want[i], tt.want[i])
+				}
+			}
+			if read := len(data) - r.Len(); read != tt.size {
+				t.Errorf("Read() consumed %d bytes, want %d", read, tt.size)
+			}
+		})
+	}
+}
+
+func TestNativeOrderName(t *testing.T) {
+	buf := make([]byte, 2)
+	binary.NativeEndian.PutUint16(buf, 0x0102)
+
+	want := "little-endian"
+	if buf[0] == 0x01 {
+		want = "big-endian"
+	}
+	if got := nativeOrderName(); got != want {
+		t.Errorf("nativeOrderName() = %q, want %q", got, want)
+	}
+}
+
+// countingWriter counts the writes reaching the underlying buffer.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestExecuteBufferedOutput(t *testing.T) {
+	var w countingWriter
+	data := []byte{0xFF, 0x01, 0x02}
+	if err := Execute("<bH", bytes.NewReader(data), &w, Options{Pretty: true, WithHex: true, PrintConsumed: true}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	// The table, hexdump and offset all reach the writer
+	output := w.String()
+	for _, want := range []string{"uint16", "Consumed 3 bytes", "3\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Execute() output missing %q\nGot:\n%s", want, output)
+		}
+	}
+
+	// A failed evaluation writes nothing
+	w = countingWriter{}
+	if err := Execute("<bHI", bytes.NewReader(data), &w, Options{Pretty: true}); err == nil {
+		t.Fatal("Execute() expected error, got nil")
+	}
+	if w.writes != 0 {
+		t.Errorf("Execute() made %d writes on error, want 0", w.writes)
+	}
+}
+
+func TestExecuteWriter(t *testing.T) {
+	data := []byte{0xFF, 0x01, 0x02}
+
+	var buf bytes.Buffer
+	if err := Execute("<bH", bytes.NewReader(data), &buf, Options{Output: OutputRaw}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got, want := buf.String(), "-1\n513\n"; got != want {
+		t.Errorf("Execute() output = %q, want %q", got, want)
+	}
+
+	// write("-") goes to the same writer, before the result
+	buf.Reset()
+	if err := Execute(`<bH | write("-")`, bytes.NewReader(data), &buf, Options{Output: OutputRaw}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got, want := buf.String(), "\xff\x01\x02-1\n513\n"; got != want {
+		t.Errorf("Execute() output = %q, want %q", got, want)
+	}
+}
+
+func TestLimitInput(t *testing.T) {
+	data := make([]byte, 16)
+
+	node, err := ParseExpression("<II")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+
+	// Within the cap the expression reads as usual
+	if _, err := node.Eval(limitInput(bytes.NewReader(data), 8), nil); err != nil {
+		t.Errorf("Eval() within cap error = %v", err)
+	}
+
+	// Reading past the cap fails like insufficient data
+	_, err = node.Eval(limitInput(bytes.NewReader(data), 6), nil)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Eval() past cap error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// Zero means no limit
+	r := bytes.NewReader(data)
+	if limitInput(r, 0) != io.Reader(r) {
+		t.Error("limitInput() with zero limit wrapped the reader")
+	}
+}
+
+func TestExecuteCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		data    []byte
+		opts    Options
+		wantErr bool
+	}{
+		{"valid input", "<bH", []byte{0xFF, 0x01, 0x02}, Options{Output: OutputTable}, false},
+		{"truncated input", "<bHI", []byte{0xFF, 0x01, 0x02}, Options{Output: OutputTable}, true},
+		{"checksum mismatch", "<BB | checksum(adler32, 1, 0, 0)", []byte{0x01, 0x02}, Options{}, true},
+		{"verify match", "<bH", []byte{0xFF, 0x01, 0x02}, Options{Verify: true, PrintConsumed: true}, false},
+		{"verify mismatch", "stride(2, B, pad)", []byte{0x01, 0xEE}, Options{Verify: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.opts.Check = true
+			err := Execute(tt.expr, bytes.NewReader(tt.data), &buf, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("Execute() output = %q, want none", buf.String())
+			}
+		})
+	}
+}
+
+func TestEvalBytes(t *testing.T) {
+	result, err := EvalBytes("<bH | {0 -> key, 1 -> value}", []byte{0xFF, 0x01, 0x02})
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	obj, ok := result.(*Object)
+	if !ok {
+		t.Fatalf("EvalBytes() = %T, want *Object", result)
+	}
+	if obj.Fields[0].Value != int8(-1) || obj.Fields[1].Value != uint16(0x0201) {
+		t.Errorf("EvalBytes() fields = %+v, want key=-1, value=513", obj.Fields)
+	}
+
+	// Offsets are tracked against the data
+	result, err = EvalBytes("<bH | mark(\"end\")", []byte{0xFF, 0x01, 0x02})
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	if m := result.([]any)[2].(Mark); m.Offset != 3 {
+		t.Errorf("EvalBytes() mark offset = %d, want 3", m.Offset)
+	}
+
+	if _, err := EvalBytes("<bH | {", nil); err == nil {
+		t.Error("EvalBytes() expected parse error, got nil")
+	}
+	if _, err := EvalBytes("<I", []byte{0x01}); err == nil {
+		t.Error("EvalBytes() expected error for insufficient data, got nil")
+	}
+}
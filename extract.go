@@ -0,0 +1,97 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// ExtractNode writes the raw source bytes a single field consumed to a file, as
+// for an embedded sub-file, e.g. <I16B:payload | extract(payload, "out.bin").
+// Unlike write(), the bytes are copied from the input rather than re-encoded.
+type ExtractNode struct {
+	Index int    // index of the field (ignored if Name is set)
+	Name  string // inline field name, empty to use Index
+	Path  string // output file path
+}
+
+// Eval fails, as the field's bytes are only known to the pipe evaluating it.
+func (n *ExtractNode) Eval(_ io.Reader, _ []any) (any, error) {
+	return nil, fmt.Errorf("extract: must follow a format expression in a pipe, e.g. '<I16B:payload | extract(payload, \"out.bin\")'")
+}
+
+// EvalConsumed writes the bytes of the field from the bytes consumed by the left
+// side of the pipe, and returns the values unchanged like write(). The byte ranges
+// come from the format codes of the left side, so it must be a format expression.
+func (n *ExtractNode) EvalConsumed(left Node, values []any, consumed []byte, _ int64) (any, error) {
+	expr, ok := positionalFormatNode(left)
+	spans := fieldSpans(left, values)
+	if !ok || spans == nil {
+		return nil, fmt.Errorf("extract: the byte ranges of the fields are only known after a format expression")
+	}
+
+	idx := n.Index
+	if n.Name != "" {
+		idx = slices.IndexFunc(expr.valueFormats(), func(fc FormatCode) bool { return fc.Name == n.Name })
+		if idx < 0 {
+			return nil, fmt.Errorf("extract: no field named %q", n.Name)
+		}
+	}
+	if idx < 0 || idx >= len(spans) {
+		return nil, fmt.Errorf("extract: index %d out of range (have %d values)", idx, len(spans))
+	}
+
+	span := spans[idx]
+	if span.Offset+span.Size > int64(len(consumed)) {
+		return nil, fmt.Errorf("extract: field %d ends at byte %d past the %d consumed bytes", idx, span.Offset+span.Size, len(consumed))
+	}
+	if err := os.WriteFile(n.Path, consumed[span.Offset:span.Offset+span.Size], 0o644); err != nil {
+		return nil, fmt.Errorf("extract: %w", err)
+	}
+	return values, nil
+}
+
+// parseExtractFunc parses: 'extract' '(' (NUMBER | IDENTIFIER) ',' STRING ')'
+func (p *Parser) parseExtractFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'extract'"); err != nil {
+		return nil, err
+	}
+
+	node := &ExtractNode{}
+	p.rescanName()
+	switch p.current.Type {
+	case TokenNumber:
+		idx, err := p.parseInt("extract field index")
+		if err != nil {
+			return nil, err
+		}
+		node.Index = idx
+	case TokenIdent:
+		node.Name = p.current.Value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected a field index or name at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+
+	if err := p.expect(TokenComma, "',' after the extract field"); err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenString {
+		return nil, fmt.Errorf("expected file path string at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+	node.Path = p.current.Value
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after the extract file path"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
@@ -0,0 +1,96 @@
+package bq
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractNodeEval(t *testing.T) {
+	// A length-prefixed blob followed by a trailer
+	data := []byte{0x04, 0x00, 0x00, 0x00, 0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x63, 0x00, 0x07}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "named blob",
+			input: `<I4B:payload | extract(payload, "%s")`,
+			want:  data[4:8],
+		},
+		{
+			name:  "indexed value",
+			input: `<I4BH | extract(2, "%s")`,
+			want:  data[8:10],
+		},
+		{
+			name:  "string with its terminator",
+			input: `<I4BBs | extract(3, "%s")`,
+			want:  data[9:11],
+		},
+		{
+			name:  "after a pass-through pipe",
+			input: `<I4B | mark("m") | extract(1, "%s")`,
+			want:  data[4:8],
+		},
+		{
+			name:    "unknown name",
+			input:   `<I4B:payload | extract(body, "%s")`,
+			wantErr: true,
+		},
+		{
+			name:    "index out of range",
+			input:   `<I4B | extract(2, "%s")`,
+			wantErr: true,
+		},
+		{
+			name:    "no format expression",
+			input:   `<I4B | {0 -> len, 1 -> payload} | extract(1, "%s")`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.bin")
+			node, err := ParseExpression(fmt.Sprintf(tt.input, path))
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.input, err)
+			}
+
+			_, err = node.Eval(bytes.NewReader(data), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("extracted bytes = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractParseErrors(t *testing.T) {
+	for _, input := range []string{
+		`<I | extract(0)`,
+		`<I | extract("out.bin")`,
+		`<I | extract(0, out)`,
+		`<I | extract(0, "out.bin"`,
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) succeeded, want an error", input)
+		}
+	}
+}
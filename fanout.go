@@ -0,0 +1,82 @@
+package bq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FanoutNode passes the same result to several pipe stages, such as writing the
+// values to a file while also printing them as JSON, e.g.
+// <bH | {0 -> a, 1 -> b} | fanout(write("o.bin"), json()).
+type FanoutNode struct {
+	Children []Node // pipe stages, evaluated in order
+}
+
+// Eval evaluates every child in order with its own copy of the values.
+func (n *FanoutNode) Eval(r io.Reader, values []any) (any, error) {
+	return n.EvalResult(r, values)
+}
+
+// EvalResult evaluates every child in order as the right side of a pipe: an object
+// keeps its field names for the children working on objects (e.g., json() or
+// rename()), while the others see its values. Each child gets its own copy of the
+// values, so one child cannot change what the next one sees. All children run
+// even if one fails, and their errors are joined. The result is the result of the
+// last child.
+func (n *FanoutNode) EvalResult(r io.Reader, result any) (any, error) {
+	var last any
+	var errs []error
+	for i, child := range n.Children {
+		in := result
+		if values, ok := result.([]any); ok {
+			in = append([]any{}, values...)
+		}
+		res, err := pipeResult(r, in, child)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fanout %d: %w", i, err))
+			continue
+		}
+		last = res
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return last, nil
+}
+
+// parseFanoutFunc parses: 'fanout' '(' PipeRHS (',' PipeRHS)* ')'
+func (p *Parser) parseFanoutFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'fanout'"); err != nil {
+		return nil, err
+	}
+
+	node := &FanoutNode{}
+	for {
+		pos, name := p.current.Pos, p.current.Value
+		child, err := p.parsePipeRHS()
+		if err != nil {
+			return nil, err
+		}
+		// These work on the consumed bytes rather than the result
+		if _, ok := child.(ConsumedSink); ok {
+			return nil, fmt.Errorf("fanout stage %q at position %d must work on the result, not the input bytes", name, pos)
+		}
+		node.Children = append(node.Children, child)
+
+		if p.current.Type != TokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after fanout stages"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
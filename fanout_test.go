@@ -0,0 +1,139 @@
+package bq
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFanoutNodeEval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+	data := []byte{0xFF, 0x01, 0x02}
+
+	result, err := EvalBytes(fmt.Sprintf(`>bH | fanout(write(%q), {0 -> a, 1 -> b})`, path), data)
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+
+	// The last stage gives the result, while the write keeps the byte order
+	var buf bytes.Buffer
+	if err := ResultToJSON(&buf, result); err != nil {
+		t.Fatalf("ResultToJSON() error = %v", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != `{"a":-1,"b":258}` {
+		t.Errorf("EvalBytes() = %s, want %s", got, `{"a":-1,"b":258}`)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !bytes.Equal(written, data) {
+		t.Errorf("written = % x, want % x", written, data)
+	}
+}
+
+func TestFanoutNodeIsolatesStages(t *testing.T) {
+	// The byteswap of the first stage is not seen by the second one
+	result, err := EvalBytes("<H | fanout(byteswap(0), {0 -> v})", []byte{0x01, 0x02})
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	obj := result.(*Object)
+	if got := obj.Fields[0].Value; got != uint16(0x0201) {
+		t.Errorf("v = %#x, want 0x201", got)
+	}
+}
+
+func TestFanoutNodeJoinsErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+	_, err := EvalBytes(fmt.Sprintf(`<BB | fanout(bias(5, 1), write(%q), sample(7, 8))`, path), []byte{0x01, 0x02})
+	if err == nil {
+		t.Fatal("EvalBytes() succeeded, want an error")
+	}
+	for _, want := range []string{"fanout 0: bias", "fanout 2: sample"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %q, want it to contain %q", err, want)
+		}
+	}
+
+	// The stages after a failing one still run
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("write stage did not run: %v", err)
+	}
+}
+
+func TestFanoutNodeObject(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+	data := []byte{0xFF, 0x01, 0x02}
+
+	// The object keeps its names for json(), while write() gets its values
+	var buf bytes.Buffer
+	expr := fmt.Sprintf(`<bH | {0 -> a, 1 -> b} | fanout(write(%q), json())`, path)
+	if err := Execute(expr, bytes.NewReader(data), &buf, Options{}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got := buf.String(); got != "{\"a\":-1,\"b\":513}\n" {
+		t.Errorf("Execute() = %q, want the object as JSON", got)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !bytes.Equal(written, data) {
+		t.Errorf("written = % x, want % x", written, data)
+	}
+
+	// An object transform is fanned out to with the object
+	result, err := EvalBytes("<BB | {0 -> a, 1 -> b} | fanout(json(), rename(a, c))", []byte{0x01, 0x02})
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	if got := result.(*Object).Fields[0].Name; got != "c" {
+		t.Errorf("renamed field = %q, want c", got)
+	}
+
+	// but needs one
+	if _, err := EvalBytes("<BB | fanout(rename(a, b))", []byte{0x01, 0x02}); err == nil || !strings.Contains(err.Error(), "expects an object") {
+		t.Errorf("EvalBytes() error = %v, want an object error", err)
+	}
+}
+
+func TestJSONNodeEval(t *testing.T) {
+	node, err := ParseExpression("<bH | json()")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+
+	// The values are printed and passed through unchanged
+	var buf bytes.Buffer
+	result, err := node.Eval(WithStdout(bytes.NewReader([]byte{0xFF, 0x01, 0x02}), &buf), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if got := buf.String(); got != "[-1,513]\n" {
+		t.Errorf("json() printed %q, want %q", got, "[-1,513]\n")
+	}
+	if values := result.([]any); len(values) != 2 || values[1] != uint16(513) {
+		t.Errorf("Eval() = %v, want the values unchanged", result)
+	}
+
+	if _, err := ParseExpression("<bH | json(1)"); err == nil {
+		t.Error("ParseExpression() with an argument expected error, got nil")
+	}
+}
+
+func TestFanoutParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"<BB | fanout()",
+		"<BB | fanout({0 -> a}",
+		"<BB | fanout(<B)",
+		`<BB | fanout(extract(0, "x.bin"))`,
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package bq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// OffsetsNode reads a table of Count offsets with an integer code, as found before
+// string pools and resource tables, for follow() to read the data they point to.
+type OffsetsNode struct {
+	Count int        // number of offsets in the table
+	Code  FormatCode // integer code of each offset
+}
+
+// Eval reads the offsets and returns them as a single []int64. A negative offset
+// is an error.
+func (n *OffsetsNode) Eval(r io.Reader, _ []any) (any, error) {
+	// The slice grows as offsets are read, so a short input fails before a large
+	// count allocates its table
+	offsets := make([]int64, 0)
+	for i := range n.Count {
+		val, err := readFixed(r, n.Code)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("offsets: failed to read offset %d of %d: %w", i, n.Count, err)
+		}
+		offset, err := toInt64(val)
+		if err != nil {
+			return nil, fmt.Errorf("offsets: %w", err)
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("offsets: negative offset %d at index %d", offset, i)
+		}
+		offsets = append(offsets, offset)
+	}
+	return []any{offsets}, nil
+}
+
+// FollowNode reads the data at each offset given by the input values, e.g.
+// offsets(3, <I) | follow(s) reads a string at each of three offsets. The input
+// must be seekable, such as a file.
+type FollowNode struct {
+	Inner Node // expression read at each offset
+}
+
+// Eval seeks to each offset from the start of the input, integer values and the
+// elements of integer arrays in order, and evaluates the inner expression there.
+// It returns an object with a field per offset, named by the offset in decimal,
+// and then restores the position of the input. An offset at or past the end of
+// the input is an error.
+func (n *FollowNode) Eval(r io.Reader, values []any) (result any, err error) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil, fmt.Errorf("follow: input does not support seeking")
+	}
+	targets, err := followOffsets(values)
+	if err != nil {
+		return nil, fmt.Errorf("follow: %w", err)
+	}
+	size, err := inputSize(seeker)
+	if err != nil {
+		return nil, seekError("follow", err)
+	}
+
+	pos, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("follow: %w", err)
+	}
+	defer func() {
+		if _, seekErr := seeker.Seek(pos, io.SeekStart); seekErr != nil && err == nil {
+			result, err = nil, fmt.Errorf("follow: failed to restore the input position: %w", seekErr)
+		}
+	}()
+
+	obj := &Object{Fields: make([]ObjectField, 0, len(targets))}
+	for _, offset := range targets {
+		if offset >= size {
+			return nil, fmt.Errorf("follow: offset %d is past the end of the %d-byte input", offset, size)
+		}
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("follow: failed to seek to offset %d: %w", offset, err)
+		}
+
+		val, err := n.Inner.Eval(r, nil)
+		if err != nil {
+			return nil, fmt.Errorf("follow: offset %d: %w", offset, err)
+		}
+		if vals, ok := val.([]any); ok {
+			val = unionValue(vals)
+		}
+		obj.Fields = append(obj.Fields, ObjectField{Name: strconv.FormatInt(offset, 10), Value: val})
+	}
+	return obj, nil
+}
+
+// followOffsets collects the offsets of the values: integers, and the elements
+// of integer arrays, in order.
+func followOffsets(values []any) ([]int64, error) {
+	var offsets []int64
+	for i, val := range values {
+		if isArrayValue(val) {
+			elems := reflect.ValueOf(val)
+			for j := range elems.Len() {
+				offset, err := toInt64(elems.Index(j).Interface())
+				if err != nil {
+					return nil, fmt.Errorf("offset %d[%d]: %w", i, j, err)
+				}
+				offsets = append(offsets, offset)
+			}
+			continue
+		}
+		offset, err := toInt64(val)
+		if err != nil {
+			return nil, fmt.Errorf("offset %d: %w", i, err)
+		}
+		offsets = append(offsets, offset)
+	}
+	for _, offset := range offsets {
+		if offset < 0 {
+			return nil, fmt.Errorf("negative offset %d", offset)
+		}
+	}
+	return offsets, nil
+}
+
+// parseOffsetsFunc parses: 'offsets' '(' NUMBER ',' FormatExpr ')'
+func (p *Parser) parseOffsetsFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'offsets'"); err != nil {
+		return nil, err
+	}
+
+	pos := p.current.Pos
+	count, err := p.parseInt("offsets count")
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 || count > maxArrayLen {
+		return nil, fmt.Errorf("offsets count at position %d must be between 0 and %d, got %d", pos, maxArrayLen, count)
+	}
+	if err := p.expect(TokenComma, "',' after offsets count"); err != nil {
+		return nil, err
+	}
+
+	code, err := p.parseSingleFormat("offsets code")
+	if err != nil {
+		return nil, err
+	}
+	if !isIntegerCode(code.Code) {
+		return nil, fmt.Errorf("offsets code must be an integer code, got %c", code.Code)
+	}
+
+	if err := p.expect(TokenRParen, "')' after offsets code"); err != nil {
+		return nil, err
+	}
+	return &OffsetsNode{Count: count, Code: code}, nil
+}
+
+// parseFollowFunc parses: 'follow' '(' Pipe ')'
+func (p *Parser) parseFollowFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'follow'"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after follow expression"); err != nil {
+		return nil, err
+	}
+	return &FollowNode{Inner: inner}, nil
+}
@@ -0,0 +1,144 @@
+package bq
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOffsetsNodeEval(t *testing.T) {
+	result, err := EvalBytes("offsets(2, >H)", []byte{0x00, 0x04, 0x01, 0x00})
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	if want := []any{[]int64{4, 256}}; !reflect.DeepEqual(result, want) {
+		t.Errorf("EvalBytes() = %v, want %v", result, want)
+	}
+
+	for _, data := range [][]byte{{0x00, 0x04, 0x01}, {0xff, 0xff, 0x00, 0x00}} {
+		if _, err := EvalBytes("offsets(2, <h)", data); err == nil {
+			t.Errorf("EvalBytes(% x) expected error, got nil", data)
+		}
+	}
+}
+
+func TestFollowNodeEval(t *testing.T) {
+	// A table of three offsets into a string pool
+	data := []byte("\x0c\x00\x00\x00\x10\x00\x00\x00\x0d\x00\x00\x00hi\x00\x00bye\x00")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "strings keyed by offset",
+			input: "offsets(3, <I) | follow(s)",
+			want:  `{"12":"hi","16":"bye","13":"i"}`,
+		},
+		{
+			name:  "objects at each offset",
+			input: "offsets(2, <I) | follow(s | {0 -> name})",
+			want:  `{"12":{"name":"hi"},"16":{"name":"bye"}}`,
+		},
+		{
+			name:  "scalar offsets",
+			input: "<II | follow(2B)",
+			want:  `{"12":[104,105],"16":[98,121]}`,
+		},
+		{
+			name:    "offset past the end",
+			input:   "<q | follow(B)",
+			wantErr: "past the end",
+		},
+		{
+			name:    "inner error names the offset",
+			input:   "<I | follow(<4I)",
+			wantErr: "follow: offset 12",
+		},
+		{
+			name:    "non-integer offset",
+			input:   "s | follow(B)",
+			wantErr: "offset 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.input, err)
+			}
+			r := bytes.NewReader(data)
+			result, err := node.Eval(r, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Eval() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := ResultToJSON(&buf, result); err != nil {
+				t.Fatalf("ResultToJSON() error = %v", err)
+			}
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("Eval() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowArrayOffsetOutOfRange(t *testing.T) {
+	// An array element above the int64 range is an error, not offset 0
+	data := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}
+	_, err := EvalBytes("<2Q | follow(B)", data)
+	if err == nil || !strings.Contains(err.Error(), "offset 0[1]") {
+		t.Errorf("EvalBytes() error = %v, want an out of range offset error", err)
+	}
+}
+
+func TestFollowNodeRestoresPosition(t *testing.T) {
+	node, err := ParseExpression("<B | follow(B)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	r := bytes.NewReader([]byte{0x02, 0xaa, 0xbb})
+	if _, err := node.Eval(r, nil); err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if pos, _ := r.Seek(0, io.SeekCurrent); pos != 1 {
+		t.Errorf("position after follow = %d, want 1", pos)
+	}
+}
+
+func TestFollowNeedsSeekableInput(t *testing.T) {
+	node, err := ParseExpression("<B | follow(B)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	if _, err := node.Eval(io.MultiReader(bytes.NewReader([]byte{0x01, 0x02})), nil); err == nil {
+		t.Error("Eval() on a non-seekable input succeeded, want an error")
+	}
+}
+
+func TestFollowParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"offsets(2)",
+		"offsets(2, s)",
+		"offsets(2, <2I)",
+		"offsets(4611686018427387904, <I)",
+		"<I | follow()",
+		"<I | follow(B",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
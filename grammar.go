@@ -0,0 +1,163 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Grammar is the EBNF grammar of the expression language accepted by
+// ParseExpression, printed by `bq --grammar`.
+const Grammar = `Expression    → Pipe
+Pipe          → Primary ('|' PipeRHS | StringArrayFunc)*
+PipeRHS       → Object | WriteFunc | MarkFunc | FieldsFunc | SetBitsFunc | ReparseFunc
+              | StringArrayFunc | SampleFunc | RenameFunc | DropFunc | RepeatPrevFunc
+              | DurationFunc | WhereFunc | BiasFunc | VersionFunc | ByteSwapFunc
+              | ExtractFunc | SignExtFunc | ChecksumFunc | FanoutFunc | JSONFunc
+              | FlagSetFunc | FollowFunc | HexdumpFunc | SliceFunc | TableFunc | AtFunc
+Primary       → SearchExpr | FunctionCall | FormatExpr
+SearchExpr    → '?' STRING
+FunctionCall  → ParseFunc | RleFunc | PatchFunc | SplitFunc | PbFunc | NameFunc
+              | UntilZeroFunc | StrideFunc | Utf8LenFunc | BitmapFunc | Utf16Func
+              | VlqFunc | UnionFunc | OdFunc | AtoiFunc | OffsetsFunc | SeekFunc
+              | RepeatFunc | AssertFunc
+ParseFunc     → 'parse' '(' FormatExpr ')'
+RleFunc       → 'rle' '(' FormatExpr ',' FormatExpr (',' NUMBER)? ')'
+PatchFunc     → 'patch' '(' NUMBER ',' FormatExpr ',' '-'? NUMBER ')'
+SplitFunc     → 'split' '(' NUMBER ',' Pipe ')'
+PbFunc        → 'pb' '(' ')'
+NameFunc      → 'name' '(' STRING ',' Pipe ')'
+UntilZeroFunc → 'until_zero' '(' FormatExpr ')'
+StrideFunc    → 'stride' '(' NUMBER ',' Pipe (',' 'pad')? ')'
+Utf8LenFunc   → 'utf8len' '(' (FormatExpr | 'varint') (',' 'lenient')? ')'
+BitmapFunc    → 'bitmap' '(' NUMBER ',' NUMBER ',' '1' ')'
+Utf16Func     → 'utf16' '(' ByteOrder? NUMBER (',' 'bom')? ')'
+VlqFunc       → 'vlq' '(' ')'
+UnionFunc     → 'union' '(' FormatExpr (',' FormatExpr)* ')'
+OdFunc        → 'od' '(' (OdRadix (',' NUMBER (',' (OdRadix | 'n'))?)?)? ')'
+OdRadix       → 'o' | 'd' | 'x'
+AtoiFunc      → 'atoi' '(' STRING ')'
+OffsetsFunc   → 'offsets' '(' NUMBER ',' FormatExpr ')'
+SeekFunc      → 'seek' '(' ('+' | '-')? NUMBER ')' '|' Primary
+RepeatFunc    → 'repeat' '(' FormatExpr ')'
+AssertFunc    → 'assert' '(' FormatExpr ',' '-'? NUMBER ')'
+WriteFunc     → 'write' '(' STRING ')'
+MarkFunc      → 'mark' '(' STRING ')'
+FieldsFunc    → 'fields' '(' NUMBER (',' NUMBER)* ')'
+SliceFunc     → 'slice' '(' '-'? NUMBER ',' '-'? NUMBER ')'
+SetBitsFunc   → 'setbits' '(' NUMBER ')'
+FlagSetFunc   → 'flagset' '(' NUMBER ',' '{' NUMBER ':' IDENTIFIER (',' NUMBER ':' IDENTIFIER)* '}' ')'
+ReparseFunc   → 'reparse' '(' Pipe ')'
+StringArrayFunc → 'string_array' '(' (NUMBER | '[' NUMBER ']') ')'
+SampleFunc    → 'sample' '(' NUMBER ',' NUMBER ')'
+RenameFunc    → 'rename' '(' IDENTIFIER ',' IDENTIFIER ')'
+DropFunc      → 'drop' '(' IDENTIFIER (',' IDENTIFIER)* ')'
+RepeatPrevFunc → 'repeat_prev' '(' Pipe ')'
+DurationFunc  → 'duration' '(' NUMBER ',' ('ns' | 'us' | 'ms' | 's') (',' 'keep')? ')'
+WhereFunc     → 'where' '(' (NUMBER | IDENTIFIER) CompareOp '-'? NUMBER ')'
+BiasFunc      → 'bias' '(' NUMBER ',' '-'? NUMBER (',' 'keep')? ')'
+SignExtFunc   → 'signext' '(' NUMBER ',' NUMBER ')'
+VersionFunc   → 'version' '(' NUMBER ',' '{' NUMBER ':' Pipe (',' NUMBER ':' Pipe)* '}' ')'
+ByteSwapFunc  → 'byteswap' '(' NUMBER ')'
+ExtractFunc   → 'extract' '(' (NUMBER | IDENTIFIER) ',' STRING ')'
+ChecksumFunc  → 'checksum' '(' ChecksumAlgo ',' NUMBER ',' NUMBER ',' NUMBER ')'
+ChecksumAlgo  → 'crc32' | 'crc32c' | 'adler32'
+FanoutFunc    → 'fanout' '(' PipeRHS (',' PipeRHS)* ')'
+JSONFunc      → 'json' '(' ')'
+FollowFunc    → 'follow' '(' Pipe ')'
+HexdumpFunc   → 'hexdump' '(' ')'
+TableFunc     → 'table' '(' '+'? (NUMBER | IDENTIFIER) ',' (NUMBER | IDENTIFIER) ',' Pipe ')'
+AtFunc        → 'at' '(' '+'? (NUMBER | IDENTIFIER) ',' Pipe ')'
+CompareOp     → '==' | '!=' | '<' | '<=' | '>' | '>='
+FormatExpr    → ByteOrder? (ByteOrder? Count? FormatCode BitWidth? (':' IDENTIFIER)?)+
+ByteOrder     → '<' | '>' | '@' | '='
+Count         → NUMBER | '*'
+BitWidth      → '/' NUMBER
+FormatCode    → 'b' | 'B' | 'h' | 'H' | 'i' | 'I' | 'q' | 'Q' | 'o' | 'O' | 'f' | 'd' | 's' | 'x' | 'l' | 'L'
+Object        → '{' FieldList? '}'
+FieldList     → FieldItem (',' FieldItem)*
+FieldItem     → IndexField | NestedField | ComputedField
+IndexField    → NUMBER '?'? '->' IDENTIFIER RenderHint?
+NestedField   → IDENTIFIER ':' Object
+ComputedField → IDENTIFIER ':' Arith RenderHint?
+RenderHint    → '#' ('hex' | 'dec')
+Arith         → Term (('+' | '-') Term)*
+Term          → Unary (('*' | '/') Unary)*
+Unary         → '-' Unary | ValueRef | NUMBER | '(' Arith ')'
+ValueRef      → '$' NUMBER
+`
+
+// grammarExamples shows a short example for each major construct of the grammar.
+const grammarExamples = `Examples:
+  <bH                              little-endian int8, then uint16
+  >I                               big-endian uint32 ('@' and '=' are native order)
+  <H>I                             switch the byte order between codes
+  4B                               an array of 4 uint8 values
+  s                                a null-terminated string
+  <bH | {0 -> key, 1 -> value}     name the values in an object
+  <bHI | {a: {0 -> x}, 1 -> y}     nest objects
+  <b:key H:value                   bind names inline
+  B/3 B/5                          split a byte into 3-bit and 5-bit fields
+  parse(<bH)                       parse explicitly, same as <bH
+  <bH | write("out.bin")           write the values back as binary
+  ?"PNG"                           search for a byte pattern
+  seek(16) | <I                    read a uint32 after a 16-byte header
+`
+
+// WriteGrammar writes the expression grammar followed by examples.
+func WriteGrammar(w io.Writer) error {
+	_, err := io.WriteString(w, "Grammar:\n\n"+Grammar+"\n"+grammarExamples)
+	return err
+}
+
+// WriteFormatCodes writes a reference table of the format codes and their
+// aliases, generated from the format code registry so it stays in sync.
+func WriteFormatCodes(w io.Writer) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%-6s %-6s %-8s %s\n", "Code", "Size", "Signed", "Type")
+	for _, code := range sortedCodes(formatCodeRegistry) {
+		info := formatCodeRegistry[code]
+		size, signed := "var", "-"
+		if info.size > 0 {
+			size, signed = fmt.Sprint(info.size), "no"
+		}
+		if info.signed {
+			signed = "yes"
+		}
+		if code == 'x' {
+			signed = "-" // padding has no value
+		}
+		fmt.Fprintf(&sb, "%-6c %-6s %-8s %s\n", code, size, signed, info.typeName)
+	}
+
+	sb.WriteString("\nAliases:\n")
+	for _, alias := range sortedCodes(formatCodeAliases) {
+		code := formatCodeAliases[alias]
+		fmt.Fprintf(&sb, "  %c  same as %c (%s)", alias, code, formatCodeRegistry[code].typeName)
+		if lp64, ok := lp64CodeAliases[alias]; ok {
+			fmt.Fprintf(&sb, ", or %c (%s) with --c-long", lp64, formatCodeRegistry[lp64].typeName)
+		}
+		sb.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// sortedCodes returns the codes of a code map, ordered by their lowercase letter
+// with the lowercase code first (e.g., b B h H).
+func sortedCodes[V any](codes map[rune]V) []rune {
+	keys := make([]rune, 0, len(codes))
+	for code := range codes {
+		keys = append(keys, code)
+	}
+	slices.SortFunc(keys, func(a, b rune) int {
+		if la, lb := strings.ToLower(string(a)), strings.ToLower(string(b)); la != lb {
+			return strings.Compare(la, lb)
+		}
+		return strings.Compare(string(b), string(a))
+	})
+	return keys
+}
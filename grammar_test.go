@@ -0,0 +1,89 @@
+package bq
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGrammarInSync(t *testing.T) {
+	// Every function and format code the parser knows is covered by the grammar
+	for name := range sourceFunctions {
+		if !strings.Contains(Grammar, "'"+name+"' '('") {
+			t.Errorf("Grammar missing source function %q", name)
+		}
+	}
+	for name := range pipeFunctions {
+		if !strings.Contains(Grammar, "'"+name+"' '('") {
+			t.Errorf("Grammar missing pipe function %q", name)
+		}
+	}
+	for code := range formatCodeRegistry {
+		if !strings.Contains(Grammar, "'"+string(code)+"'") {
+			t.Errorf("Grammar missing format code %q", code)
+		}
+	}
+	for code := range formatCodeAliases {
+		if !strings.Contains(Grammar, "'"+string(code)+"'") {
+			t.Errorf("Grammar missing format code alias %q", code)
+		}
+	}
+}
+
+func TestGrammarExamplesParse(t *testing.T) {
+	example := regexp.MustCompile(`^  (\S.*?)\s{2,}\S`)
+
+	count := 0
+	for _, line := range strings.Split(grammarExamples, "\n") {
+		m := example.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		count++
+		if _, err := ParseExpression(m[1]); err != nil {
+			t.Errorf("ParseExpression(%q) error = %v", m[1], err)
+		}
+	}
+	if count == 0 {
+		t.Fatal("no examples found")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGrammar(&buf); err != nil {
+		t.Fatalf("WriteGrammar() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), Grammar) || !strings.Contains(buf.String(), grammarExamples) {
+		t.Errorf("WriteGrammar() output missing the grammar or examples\nGot:\n%s", buf.String())
+	}
+}
+
+func TestWriteFormatCodes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFormatCodes(&buf); err != nil {
+		t.Fatalf("WriteFormatCodes() error = %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"b      1      yes      int8\n",
+		"Q      8      no       uint64\n",
+		"s      var    -        string\n",
+		"x      1      -        pad\n",
+		"  l  same as i (int32), or q (int64) with --c-long\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteFormatCodes() missing %q\nGot:\n%s", want, got)
+		}
+	}
+
+	// Every registered code has a row
+	for code := range formatCodeRegistry {
+		if !strings.Contains(got, "\n"+string(code)+" ") {
+			t.Errorf("WriteFormatCodes() missing format code %q", code)
+		}
+	}
+	if !strings.HasPrefix(got, "Code   Size") || strings.Index(got, "\nb ") > strings.Index(got, "\nB ") {
+		t.Errorf("WriteFormatCodes() rows out of order\nGot:\n%s", got)
+	}
+}
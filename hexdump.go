@@ -0,0 +1,176 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeHexdump writes data in the canonical hexdump layout: the offset, 16 hex
+// bytes per line (in two groups of 8), and an ASCII gutter.
+//
+//	00000000  01 02 03 04 05 06 07 08  09 0a 0b 0c 0d 0e 0f 10  |................|
+func writeHexdump(w io.Writer, data []byte, base int64) error {
+	var sb strings.Builder
+	for off := 0; off < len(data); off += 16 {
+		line := data[off:min(off+16, len(data))]
+
+		fmt.Fprintf(&sb, "%08x  ", base+int64(off))
+		for i := 0; i < 16; i++ {
+			if i < len(line) {
+				fmt.Fprintf(&sb, "%02x ", line[i])
+			} else {
+				sb.WriteString("   ")
+			}
+			if i == 7 {
+				sb.WriteByte(' ')
+			}
+		}
+
+		sb.WriteString(" |")
+		for _, b := range line {
+			if b >= 0x20 && b < 0x7f {
+				sb.WriteByte(b)
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteString("|\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// fieldSpan is the byte range consumed by a single parsed value.
+type fieldSpan struct {
+	Name   string // field name (the value index)
+	Code   rune   // format code
+	Offset int64  // offset of the first byte
+	Size   int64  // number of bytes consumed
+}
+
+// reparsed reports whether the node's values come from a reparsed byte slice,
+// whose offsets are relative to the slice rather than the input.
+func reparsed(node Node) bool {
+	_, node = unwrapNamed(node)
+	pipe, ok := node.(*PipeNode)
+	if !ok {
+		return false
+	}
+	if _, ok := pipe.Right.(*ReparseNode); ok {
+		return true
+	}
+	return reparsed(pipe.Left)
+}
+
+// fieldSpans computes the byte ranges consumed by the values of a format expression,
+// or returns nil when the result is not a positional list from a format expression.
+func fieldSpans(node Node, result any) []fieldSpan {
+	values, ok := result.([]any)
+	if !ok {
+		return nil
+	}
+	expr, ok := positionalFormatNode(node)
+	if !ok || reparsed(node) {
+		return nil
+	}
+
+	spans := make([]fieldSpan, 0, len(expr.Formats))
+	offset := int64(0)
+	for _, fc := range expr.Formats {
+		i := len(spans)
+		if i >= len(values) {
+			break
+		}
+
+		n, fixed := fc.byteSize()
+		size := int64(n)
+		if fc.isPad() {
+			// Padding has no value, but moves the following fields
+			offset += size
+			continue
+		}
+		if str, ok := values[i].(string); ok && !fixed {
+			size = int64(len(str)) + 1 // null terminator
+		}
+		if n, ok := arrayLen(values[i]); ok && fc.Rest {
+			size = int64(n * fc.Size)
+		}
+		if fc.Bits > 0 && fc.BitOffset > 0 && i > 0 {
+			// A bit-field lies in the unit read by the first bit-field
+			unit := spans[i-1]
+			spans = append(spans, fieldSpan{Name: fmt.Sprintf("%d", i), Code: fc.Code, Offset: unit.Offset, Size: unit.Size})
+			continue
+		}
+
+		spans = append(spans, fieldSpan{Name: fmt.Sprintf("%d", i), Code: fc.Code, Offset: offset, Size: size})
+		offset += size
+	}
+	return spans
+}
+
+// writeConsumedHex writes a hexdump of the consumed bytes, followed by the byte
+// range of each field when it can be determined.
+func writeConsumedHex(w io.Writer, node Node, result any, consumed []byte, start int64) error {
+	if _, err := fmt.Fprintf(w, "\nConsumed %d bytes:\n", len(consumed)); err != nil {
+		return err
+	}
+	if err := writeHexdump(w, consumed, start); err != nil {
+		return err
+	}
+
+	spans := fieldSpans(node, result)
+	if len(spans) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "\n%-10s %-6s %-17s %s\n", "Name", "Code", "Range", "Size"); err != nil {
+		return err
+	}
+	for _, span := range spans {
+		rng := fmt.Sprintf("%08x-%08x", start+span.Offset, start+span.Offset+span.Size-1)
+		if _, err := fmt.Fprintf(w, "%-10s %-6c %-17s %d\n", span.Name, span.Code, rng, span.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HexdumpNode renders the input bytes consumed by the left side of a pipe in the
+// canonical hexdump layout, e.g. parse(<16B) | hexdump(), instead of printing the
+// values.
+type HexdumpNode struct{}
+
+// Eval fails, as the consumed bytes are only known to the pipe evaluating it.
+func (n *HexdumpNode) Eval(_ io.Reader, _ []any) (any, error) {
+	return nil, fmt.Errorf("hexdump: must follow an expression in a pipe, e.g. 'parse(<16B) | hexdump()'")
+}
+
+// EvalConsumed returns the *Dump of the consumed bytes, one line per 16 bytes with
+// offsets counted from where the left side started reading.
+func (n *HexdumpNode) EvalConsumed(_ Node, _ []any, consumed []byte, start int64) (any, error) {
+	var sb strings.Builder
+	if err := writeHexdump(&sb, consumed, start); err != nil {
+		return nil, fmt.Errorf("hexdump: %w", err)
+	}
+
+	dump := &Dump{}
+	if text := strings.TrimSuffix(sb.String(), "\n"); text != "" {
+		dump.Lines = strings.Split(text, "\n")
+	}
+	return dump, nil
+}
+
+// parseHexdumpFunc parses: 'hexdump' '(' ')'
+func (p *Parser) parseHexdumpFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'hexdump'"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenRParen, "')' after 'hexdump('"); err != nil {
+		return nil, err
+	}
+	return &HexdumpNode{}, nil
+}
@@ -0,0 +1,159 @@
+package bq
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestWriteHexdump(t *testing.T) {
+	data := []byte("0123456789abcdef\x00\x01hi")
+
+	var buf bytes.Buffer
+	if err := writeHexdump(&buf, data, 0); err != nil {
+		t.Fatalf("writeHexdump() error = %v", err)
+	}
+
+	want := "00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|\n" +
+		"00000010  00 01 68 69                                       |..hi|\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeHexdump() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteConsumedHex(t *testing.T) {
+	data := []byte{0xFF, 0x01, 0x02, 'h', 'i', 0x00, 0x05}
+	node, err := ParseExpression("<bHsB")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	spans := fieldSpans(node, result)
+	wantSpans := []fieldSpan{
+		{Name: "0", Code: 'b', Offset: 0, Size: 1},
+		{Name: "1", Code: 'H', Offset: 1, Size: 2},
+		{Name: "2", Code: 's', Offset: 3, Size: 3},
+		{Name: "3", Code: 'B', Offset: 6, Size: 1},
+	}
+	if len(spans) != len(wantSpans) {
+		t.Fatalf("fieldSpans() len = %d, want %d", len(spans), len(wantSpans))
+	}
+	for i := range spans {
+		if spans[i] != wantSpans[i] {
+			t.Errorf("fieldSpans()[%d] = %+v, want %+v", i, spans[i], wantSpans[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeConsumedHex(&buf, node, result, data, 0); err != nil {
+		t.Fatalf("writeConsumedHex() error = %v", err)
+	}
+	for _, want := range []string{"Consumed 7 bytes", "|...hi..|", "00000001-00000002"} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("writeConsumedHex() output missing %q\nGot:\n%s", want, buf.String())
+		}
+	}
+
+	// Objects have no positional spans, only the hexdump
+	objNode, _ := ParseExpression("<bH | {0 -> a, 1 -> b}")
+	objResult, _ := objNode.Eval(bytes.NewReader(data), nil)
+	if spans := fieldSpans(objNode, objResult); spans != nil {
+		t.Errorf("fieldSpans() for object = %v, want nil", spans)
+	}
+
+	// Reparsed values have offsets relative to the blob, not the input
+	reNode, _ := ParseExpression("<3B | reparse(<bH) | mark(\"end\")")
+	reResult, _ := reNode.Eval(bytes.NewReader(data), nil)
+	if spans := fieldSpans(reNode, reResult); spans != nil {
+		t.Errorf("fieldSpans() for reparse = %v, want nil", spans)
+	}
+}
+
+func TestFieldSpansPaddingAndFixedStrings(t *testing.T) {
+	data := []byte{0x01, 0xAA, 0xAA, 'a', 'b', 0x00, 0x00, 0x02, 0x00}
+	node, err := ParseExpression("<B2x4sH")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	// The padding moves the spans after it without a span of its own
+	spans := fieldSpans(node, result)
+	wantSpans := []fieldSpan{
+		{Name: "0", Code: 'B', Offset: 0, Size: 1},
+		{Name: "1", Code: 's', Offset: 3, Size: 4},
+		{Name: "2", Code: 'H', Offset: 7, Size: 2},
+	}
+	if len(spans) != len(wantSpans) {
+		t.Fatalf("fieldSpans() = %+v, want %+v", spans, wantSpans)
+	}
+	for i := range spans {
+		if spans[i] != wantSpans[i] {
+			t.Errorf("fieldSpans()[%d] = %+v, want %+v", i, spans[i], wantSpans[i])
+		}
+	}
+}
+
+func TestHexdumpNode(t *testing.T) {
+	data := []byte("0123456789abcdef\x00\x01hi")
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "all the consumed bytes",
+			input: "parse(<16B4B) | hexdump()",
+			want: []string{
+				"00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|",
+				"00000010  00 01 68 69                                       |..hi|",
+			},
+		},
+		{
+			name:  "only the consumed bytes",
+			input: "parse(<16B) | hexdump()",
+			want:  []string{"00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, data)
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+			dump, ok := result.(*Dump)
+			if !ok {
+				t.Fatalf("EvalBytes() = %T, want *Dump", result)
+			}
+			if !slices.Equal(dump.Lines, tt.want) {
+				t.Errorf("EvalBytes() lines = %q, want %q", dump.Lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestHexdumpNodeStartOffset(t *testing.T) {
+	result, err := (&HexdumpNode{}).EvalConsumed(nil, nil, []byte("\x00\x01hi"), 16)
+	if err != nil {
+		t.Fatalf("EvalConsumed() error = %v", err)
+	}
+	want := []string{"00000010  00 01 68 69                                       |..hi|"}
+	if dump := result.(*Dump); !slices.Equal(dump.Lines, want) {
+		t.Errorf("EvalConsumed() lines = %q, want %q", dump.Lines, want)
+	}
+}
+
+func TestHexdumpNeedsPipe(t *testing.T) {
+	if _, err := ParseExpression("hexdump()"); err == nil {
+		t.Error("ParseExpression(\"hexdump()\") expected error, got nil")
+	}
+}
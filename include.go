@@ -0,0 +1,63 @@
+package bq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// includeDirective starts a line pulling in the contents of another expression
+// file, e.g. @include "common.bq".
+const includeDirective = "@include"
+
+// LoadExpression reads an expression from a file, replacing each line holding an
+// @include "path" directive with the contents of that file, resolved relative to
+// the including file. Includes nest, and a file including itself (directly or not)
+// is an error.
+func LoadExpression(path string) (string, error) {
+	return expandIncludes(path, nil)
+}
+
+// expandIncludes reads the file and expands its includes, given the stack of the
+// files including it.
+func expandIncludes(path string, stack []string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(stack, abs) {
+		return "", fmt.Errorf("cyclic include: %s", strings.Join(append(stack, abs), " -> "))
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		directive := strings.TrimSpace(line)
+		if !strings.HasPrefix(directive, includeDirective) {
+			continue
+		}
+
+		target, err := strconv.Unquote(strings.TrimSpace(strings.TrimPrefix(directive, includeDirective)))
+		if err != nil {
+			return "", fmt.Errorf("%s:%d: expected %s \"path\", got %q", path, i+1, includeDirective, directive)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		included, err := expandIncludes(target, stack)
+		if err != nil {
+			return "", fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		lines[i] = strings.TrimRight(included, "\n")
+	}
+	return strings.Join(lines, "\n"), nil
+}
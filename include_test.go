@@ -0,0 +1,95 @@
+package bq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files, relative to the directory, creating parents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+}
+
+func TestLoadExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "no includes",
+			files: map[string]string{"main.bq": "<bH | {0 -> a, 1 -> b}\n"},
+			want:  "<bH | {0 -> a, 1 -> b}\n",
+		},
+		{
+			name: "nested includes relative to the including file",
+			files: map[string]string{
+				"main.bq":         "<H\n  @include \"lib/body.bq\"\n| {0 -> len, 1 -> tag, 2 -> crc}\n",
+				"lib/body.bq":     "B\n@include \"sub/crc.bq\"\n",
+				"lib/sub/crc.bq":  "I\n",
+				"lib/unused.bq":   "q\n",
+				"other/ignore.bq": "Q\n",
+			},
+			want: "<H\nB\nI\n| {0 -> len, 1 -> tag, 2 -> crc}\n",
+		},
+		{
+			name:    "self include",
+			files:   map[string]string{"main.bq": "@include \"main.bq\"\n"},
+			wantErr: "cyclic include",
+		},
+		{
+			name: "indirect cycle",
+			files: map[string]string{
+				"main.bq": "@include \"a.bq\"\n",
+				"a.bq":    "@include \"main.bq\"\n",
+			},
+			wantErr: "cyclic include",
+		},
+		{
+			name:    "missing file",
+			files:   map[string]string{"main.bq": "B\n@include \"missing.bq\"\n"},
+			wantErr: "main.bq:2",
+		},
+		{
+			name:    "unquoted path",
+			files:   map[string]string{"main.bq": "@include common.bq\n"},
+			wantErr: "expected @include \"path\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			got, err := LoadExpression(filepath.Join(dir, "main.bq"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadExpression() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadExpression() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("LoadExpression() = %q, want %q", got, tt.want)
+			}
+			if _, err := ParseExpression(got); err != nil {
+				t.Errorf("ParseExpression() error = %v", err)
+			}
+		})
+	}
+}
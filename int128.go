@@ -0,0 +1,70 @@
+package bq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+)
+
+// int128Size is the byte size of the 'o' and 'O' codes.
+const int128Size = 16
+
+var (
+	// int128Modulus is 2^128, the offset between a negative int128 and its
+	// two's complement bits.
+	int128Modulus = new(big.Int).Lsh(big.NewInt(1), 128)
+	minInt128     = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	maxUint128    = new(big.Int).Sub(int128Modulus, big.NewInt(1))
+)
+
+// decodeInt128 decodes 16 bytes as an integer, in two's complement if signed.
+func decodeInt128(buf []byte, order binary.ByteOrder, signed bool) *big.Int {
+	b := slices.Clone(buf[:int128Size])
+	if order == binary.LittleEndian {
+		slices.Reverse(b)
+	}
+
+	v := new(big.Int).SetBytes(b)
+	if signed && b[0]&0x80 != 0 {
+		v.Sub(v, int128Modulus)
+	}
+	return v
+}
+
+// int128Bits returns the 128 bits of an integer, in two's complement if negative.
+// A value outside of both the int128 and the uint128 range is an error.
+func int128Bits(v *big.Int) (*big.Int, error) {
+	if v.Cmp(minInt128) < 0 || v.Cmp(maxUint128) > 0 {
+		return nil, fmt.Errorf("value %s does not fit in 128 bits", v)
+	}
+	if v.Sign() < 0 {
+		return new(big.Int).Add(v, int128Modulus), nil
+	}
+	return v, nil
+}
+
+// encodeInt128 encodes an integer as exactly 16 bytes.
+func encodeInt128(w io.Writer, v *big.Int, order binary.ByteOrder) error {
+	u, err := int128Bits(v)
+	if err != nil {
+		return err
+	}
+
+	b := u.FillBytes(make([]byte, int128Size))
+	if order == binary.LittleEndian {
+		slices.Reverse(b)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// formatHex128 formats the 128 bits of an integer as 32 hex digits.
+func formatHex128(v *big.Int) string {
+	u, err := int128Bits(v)
+	if err != nil {
+		return "N/A"
+	}
+	return fmt.Sprintf("%032x", u)
+}
@@ -0,0 +1,119 @@
+package bq
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestInt128RoundTrip(t *testing.T) {
+	ones := bytes.Repeat([]byte{0xFF}, 16)
+	// -2 in two's complement, and 0x0102 in either byte order
+	minus2LE := append([]byte{0xFE}, ones[:15]...)
+	minus2BE := append(bytes.Repeat([]byte{0xFF}, 15), 0xFE)
+	small := append([]byte{0x02, 0x01}, make([]byte, 14)...)
+	maxUint, _ := new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+		want   *big.Int
+	}{
+		{"max unsigned", "<O", ones, maxUint},
+		{"minus one signed", ">o", ones, big.NewInt(-1)},
+		{"negative signed little endian", "<o", minus2LE, big.NewInt(-2)},
+		{"negative signed big endian", ">o", minus2BE, big.NewInt(-2)},
+		{"negative bits unsigned", ">O", minus2BE, new(big.Int).Sub(maxUint, big.NewInt(1))},
+		{"small little endian", "<o", small, big.NewInt(0x0102)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			values, err := expr.Read(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			got, ok := values[0].(*big.Int)
+			if !ok || got.Cmp(tt.want) != 0 {
+				t.Fatalf("Read() = %v, want %v", values[0], tt.want)
+			}
+
+			var buf bytes.Buffer
+			if err := encodeValue(&buf, got, toBinaryOrder(expr.Order)); err != nil {
+				t.Fatalf("encodeValue() error = %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.data) {
+				t.Errorf("encodeValue() = % x, want % x", buf.Bytes(), tt.data)
+			}
+		})
+	}
+}
+
+func TestInt128Array(t *testing.T) {
+	data := append(bytes.Repeat([]byte{0xFF}, 16), append([]byte{0x01}, make([]byte, 15)...)...)
+
+	expr, err := Parse("<2o")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	values, err := expr.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	arr, ok := values[0].([]*big.Int)
+	if !ok || len(arr) != 2 || arr[0].Int64() != -1 || arr[1].Int64() != 1 {
+		t.Fatalf("Read() = %v, want [-1 1]", values[0])
+	}
+
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, arr, toBinaryOrder(expr.Order)); err != nil {
+		t.Fatalf("encodeValue() error = %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("encodeValue() = % x, want % x", buf.Bytes(), data)
+	}
+}
+
+func TestInt128EncodeRange(t *testing.T) {
+	tooSmall := new(big.Int).Sub(minInt128, big.NewInt(1))
+	err := encodeValue(&bytes.Buffer{}, tooSmall, toBinaryOrder(LittleEndian))
+	if err == nil || !strings.Contains(err.Error(), "does not fit in 128 bits") {
+		t.Errorf("encodeValue(%v) error = %v, want a range error", tooSmall, err)
+	}
+}
+
+func TestInt128ObjectType(t *testing.T) {
+	// A non-negative int128 and a uint128, the value alone not telling them apart
+	data := append(append([]byte{0x01}, make([]byte, 15)...), append([]byte{0x02}, make([]byte, 15)...)...)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"object", "<oO | {0 -> a, 1 -> b}", []string{"a          o      int128 ", "b          O      uint128 "}},
+		{"nested object", "<oO | {n: {0 -> a}}", []string{"  a        o      int128 "}},
+		{"array", "<2o | {0 -> a}", []string{"a          o      []int128 "}},
+		{"record stream", "repeat(<o) | {0 -> a}", []string{"  a        o      int128 "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Execute(tt.input, bytes.NewReader(data), &buf, Options{Pretty: true}); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Execute() output missing %q\nGot:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package bq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// ResultToJSON writes the result as a single line of JSON. Objects become JSON
+// objects preserving the field order, lists and typed arrays (including []uint8,
+// which is not base64-encoded) become arrays, and nested objects and records nest.
+// Marks encode as their offset, and non-finite floats as null.
+func ResultToJSON(w io.Writer, result any) error {
+	var buf bytes.Buffer
+	if err := writeJSONValue(&buf, result); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeJSONValue recursively encodes a single value.
+func writeJSONValue(buf *bytes.Buffer, val any) error {
+	switch v := val.(type) {
+	case *Object:
+		buf.WriteByte('{')
+		for i, field := range v.Fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONScalar(buf, field.Name); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSONValue(buf, field.Value); err != nil {
+				return fmt.Errorf("field %q: %w", field.Name, err)
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case Mark:
+		return writeJSONScalar(buf, v.Offset)
+	case float32:
+		return writeJSONFloat(buf, float64(v))
+	case float64:
+		return writeJSONFloat(buf, v)
+	case string:
+		return writeJSONScalar(buf, v)
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Slice {
+		buf.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONValue(buf, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	return writeJSONScalar(buf, val)
+}
+
+// writeJSONFloat encodes a float, writing null for NaN and infinities.
+func writeJSONFloat(buf *bytes.Buffer, v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		buf.WriteString("null")
+		return nil
+	}
+	return writeJSONScalar(buf, v)
+}
+
+// writeJSONScalar encodes a scalar with the standard JSON encoding, leaving
+// characters such as '<' unescaped since the output is not embedded in HTML.
+func writeJSONScalar(buf *bytes.Buffer, val any) error {
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(data.Bytes(), []byte("\n")))
+	return nil
+}
+
+// JSONNode prints the result of the left side of a pipe as a single line of JSON
+// and passes it through unchanged, e.g. to print an object while also writing its
+// values with <bH | {0 -> a, 1 -> b} | fanout(write("o.bin"), json()).
+type JSONNode struct{}
+
+// Eval prints the values as a JSON array.
+func (n *JSONNode) Eval(r io.Reader, values []any) (any, error) {
+	return n.EvalResult(r, values)
+}
+
+// EvalResult prints the result, an object or the values, as ResultToJSON does, to
+// the standard output of the evaluation (see WithStdout).
+func (n *JSONNode) EvalResult(r io.Reader, result any) (any, error) {
+	if err := ResultToJSON(evalStdout(r), result); err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+	return result, nil
+}
+
+// parseJSONFunc parses: 'json' '(' ')'
+func (p *Parser) parseJSONFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'json'"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenRParen, "')' after 'json('"); err != nil {
+		return nil, err
+	}
+	return &JSONNode{}, nil
+}
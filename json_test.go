@@ -0,0 +1,106 @@
+package bq
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestResultToJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		result any
+		want   string
+	}{
+		{
+			name:   "scalars",
+			result: []any{int8(-1), uint16(513), uint64(math.MaxUint64), "hi"},
+			want:   `[-1,513,18446744073709551615,"hi"]`,
+		},
+		{
+			name:   "arrays",
+			result: []any{[]uint8{0x89, 0x50}, []int16{-1, 2}},
+			want:   `[[137,80],[-1,2]]`,
+		},
+		{
+			name: "object preserves field order",
+			result: &Object{Fields: []ObjectField{
+				{Name: "zeta", Value: uint8(1)},
+				{Name: "alpha", Value: []uint8{2, 3}},
+			}},
+			want: `{"zeta":1,"alpha":[2,3]}`,
+		},
+		{
+			name: "nested objects",
+			result: &Object{Fields: []ObjectField{
+				{Name: "a", Value: int8(1)},
+				{Name: "level1", Value: &Object{Fields: []ObjectField{
+					{Name: "b", Value: uint16(2)},
+					{Name: "level2", Value: &Object{Fields: []ObjectField{}}},
+				}}},
+			}},
+			want: `{"a":1,"level1":{"b":2,"level2":{}}}`,
+		},
+		{
+			name: "array of objects",
+			result: []any{
+				&Object{Fields: []ObjectField{{Name: "x", Value: uint8(1)}}},
+				&Object{Fields: []ObjectField{{Name: "x", Value: uint8(2)}}},
+			},
+			want: `[{"x":1},{"x":2}]`,
+		},
+		{
+			name:   "array of records",
+			result: []any{[]any{uint8(1), "a"}, []any{uint8(2), "b"}},
+			want:   `[[1,"a"],[2,"b"]]`,
+		},
+		{
+			name:   "empty object",
+			result: &Object{Fields: []ObjectField{}},
+			want:   `{}`,
+		},
+		{
+			name:   "marks and floats",
+			result: []any{Mark{Name: "end", Offset: 7}, 1.5, math.NaN(), []float32{0.5}},
+			want:   `[7,1.5,null,[0.5]]`,
+		},
+		{
+			name:   "escaped names and strings",
+			result: &Object{Fields: []ObjectField{{Name: `a"b`, Value: "line\n"}}},
+			want:   `{"a\"b":"line\n"}`,
+		},
+		{
+			name:   "html characters unescaped",
+			result: &Object{Fields: []ObjectField{{Name: "<I", Value: "a&b"}}},
+			want:   `{"<I":"a&b"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ResultToJSON(&buf, tt.result); err != nil {
+				t.Fatalf("ResultToJSON() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("ResultToJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultToJSONFromExpression(t *testing.T) {
+	result, err := EvalBytes("split(0x0A, <BB | {0 -> x, nested: {1 -> y}})", []byte("ab\ncd"))
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ResultToJSON(&buf, result); err != nil {
+		t.Fatalf("ResultToJSON() error = %v", err)
+	}
+	want := `[{"x":97,"nested":{"y":98}},{"x":99,"nested":{"y":100}}]` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("ResultToJSON() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+)
+
+// NamedNode labels an expression with a title, e.g. to tell apart several dumped
+// formats. It is transparent to evaluation: the pretty-print output shows the
+// title above the table, and the JSON output wraps the result under the title.
+type NamedNode struct {
+	Title string // label shown in the output
+	Inner Node   // labelled expression
+}
+
+// Eval returns the result of the inner expression unchanged.
+func (n *NamedNode) Eval(r io.Reader, values []any) (any, error) {
+	return n.Inner.Eval(r, values)
+}
+
+// unwrapNamed returns the title and inner node of a named node, or an empty
+// title and the node itself otherwise.
+func unwrapNamed(node Node) (string, Node) {
+	if named, ok := node.(*NamedNode); ok {
+		return named.Title, named.Inner
+	}
+	return "", node
+}
+
+// namedResult wraps the result of a named node in an object keyed by its title,
+// returning the result unchanged for any other node.
+func namedResult(node Node, result any) any {
+	named, ok := node.(*NamedNode)
+	if !ok {
+		return result
+	}
+	return &Object{Fields: []ObjectField{{Name: named.Title, Value: result}}}
+}
+
+// parseNameFunc parses: 'name' '(' STRING ',' Pipe ')'
+func (p *Parser) parseNameFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'name'"); err != nil {
+		return nil, err
+	}
+
+	if p.current.Type != TokenString {
+		return nil, fmt.Errorf("expected title string at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+	title := p.current.Value
+	if title == "" {
+		return nil, fmt.Errorf("name title must not be empty at position %d", p.current.Pos)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after name title"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after named expression"); err != nil {
+		return nil, err
+	}
+	return &NamedNode{Title: title, Inner: inner}, nil
+}
@@ -0,0 +1,81 @@
+package bq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseNameFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		title   string
+		wantErr bool
+	}{
+		{"format", `name("header", <bH)`, "header", false},
+		{"pipe", `name("header", <bH | {0 -> a, 1 -> b})`, "header", false},
+		{"nested function", `name("lines", split(0x0A, B))`, "lines", false},
+		{"empty title", `name("", <bH)`, "", true},
+		{"missing title", `name(<bH)`, "", true},
+		{"missing expression", `name("header")`, "", true},
+		{"unclosed", `name("header", <bH`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			named, ok := node.(*NamedNode)
+			if !ok {
+				t.Fatalf("ParseExpression() = %T, want *NamedNode", node)
+			}
+			if named.Title != tt.title {
+				t.Errorf("NamedNode.Title = %q, want %q", named.Title, tt.title)
+			}
+		})
+	}
+}
+
+func TestNamedNodeOutput(t *testing.T) {
+	data := []byte{0xFF, 0x01, 0x02}
+
+	// Evaluation passes the inner result through unchanged
+	result, err := EvalBytes(`name("header", <bH)`, data)
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	values, ok := result.([]any)
+	if !ok || len(values) != 2 || values[0] != int8(-1) || values[1] != uint16(513) {
+		t.Fatalf("EvalBytes() = %v, want [-1 513]", result)
+	}
+
+	node, _ := ParseExpression(`name("header", <bH)`)
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+	output := buf.String()
+	if !strings.HasPrefix(output, "=== header ===\nName ") {
+		t.Errorf("PrettyPrintResult() output missing the title\nGot:\n%s", output)
+	}
+	// The format codes are still resolved through the named node
+	if !strings.Contains(output, "H      uint16") {
+		t.Errorf("PrettyPrintResult() output missing the format codes\nGot:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := writeResult(&buf, node, result, Options{Output: OutputJSON}); err != nil {
+		t.Fatalf("writeResult() error = %v", err)
+	}
+	if got, want := buf.String(), `{"header":[-1,513]}`+"\n"; got != want {
+		t.Errorf("writeResult() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package bq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// odBytesPerLine is the number of input bytes on each line of the od dump.
+const odBytesPerLine = 16
+
+// odRadixes maps the value and address radixes of od() to their printf verb.
+var odRadixes = map[string]byte{
+	"o": 'o', // octal
+	"d": 'd', // decimal (signed for values)
+	"x": 'x', // hex
+}
+
+// odWidths holds, per radix, the digits of the widest value of each unit size.
+var odWidths = map[byte]map[int]int{
+	'o': {1: 3, 2: 6, 4: 11, 8: 22},
+	'd': {1: 4, 2: 6, 4: 11, 8: 20},
+	'x': {1: 2, 2: 4, 4: 8, 8: 16},
+}
+
+// OdNode dumps the rest of the input like Unix od, without needing a format for
+// it: units of Size bytes in native byte order, printed in Radix after an
+// address in AddrRadix (0 prints no address).
+type OdNode struct {
+	Radix     byte // 'o', 'd' or 'x'
+	Size      int  // bytes per unit: 1, 2, 4 or 8
+	AddrRadix byte // 'o', 'd', 'x' or 0 for no address
+}
+
+// Dump is the od-style text dump of the input, one string per line.
+type Dump struct {
+	Lines []string `json:"lines"`
+}
+
+// Eval reads the remaining input and returns its *Dump. A trailing partial unit
+// is padded with zero bytes, and the final line holds the address past the end.
+func (n *OdNode) Eval(r io.Reader, _ []any) (any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("od: %w", err)
+	}
+
+	order := toBinaryOrder(NativeOrder)
+	width := odWidths[n.Radix][n.Size]
+	dump := &Dump{}
+	for off := 0; off < len(data); off += odBytesPerLine {
+		line := data[off:min(off+odBytesPerLine, len(data))]
+
+		var sb strings.Builder
+		sb.WriteString(n.address(off))
+		for i := 0; i < len(line); i += n.Size {
+			unit := make([]byte, n.Size)
+			copy(unit, line[i:])
+			fmt.Fprintf(&sb, " %*s", width, n.formatUnit(unit, order))
+		}
+		text := sb.String()
+		if n.AddrRadix == 0 {
+			// Without an address, drop the separator before the first unit
+			text = text[1:]
+		}
+		dump.Lines = append(dump.Lines, text)
+	}
+	if n.AddrRadix != 0 {
+		dump.Lines = append(dump.Lines, n.address(len(data)))
+	}
+	return dump, nil
+}
+
+// address formats an offset in the address radix, like od's 7-digit addresses.
+func (n *OdNode) address(off int) string {
+	if n.AddrRadix == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%07"+string(n.AddrRadix), off)
+}
+
+// formatUnit formats a single unit in the value radix. Octal and hex are zero
+// padded to the unit's width, as od does.
+func (n *OdNode) formatUnit(unit []byte, order binary.ByteOrder) string {
+	var v uint64
+	switch n.Size {
+	case 1:
+		v = uint64(unit[0])
+	case 2:
+		v = uint64(order.Uint16(unit))
+	case 4:
+		v = uint64(order.Uint32(unit))
+	default:
+		v = order.Uint64(unit)
+	}
+
+	if n.Radix == 'd' {
+		// Sign-extend from the unit size
+		shift := 64 - 8*n.Size
+		return fmt.Sprint(int64(v<<shift) >> shift)
+	}
+	return fmt.Sprintf("%0*"+string(n.Radix), odWidths[n.Radix][n.Size], v)
+}
+
+// WriteDump writes the lines of the dump.
+func WriteDump(w io.Writer, dump *Dump) error {
+	for _, line := range dump.Lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseOdFunc parses: 'od' '(' (RADIX (',' NUMBER (',' RADIX)?)?)? ')'
+func (p *Parser) parseOdFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'od'"); err != nil {
+		return nil, err
+	}
+
+	node := &OdNode{Radix: 'x', Size: 1, AddrRadix: 'x'}
+	if p.current.Type != TokenRParen {
+		radix, err := p.parseOdRadix("od value radix", false)
+		if err != nil {
+			return nil, err
+		}
+		node.Radix = radix
+
+		if p.current.Type == TokenComma {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			pos := p.current.Pos
+			size, err := p.parseInt("od unit size")
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := odWidths['x'][size]; !ok {
+				return nil, fmt.Errorf("od unit size at position %d must be 1, 2, 4 or 8, got %d", pos, size)
+			}
+			node.Size = size
+		}
+
+		if p.current.Type == TokenComma {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if node.AddrRadix, err = p.parseOdRadix("od address radix", true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after od arguments"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseOdRadix parses a radix name (o, d or x), also accepting n for no radix
+// if allowNone is set.
+func (p *Parser) parseOdRadix(what string, allowNone bool) (byte, error) {
+	p.rescanName()
+	name := p.current.Value
+	radix, ok := odRadixes[name]
+	if p.current.Type != TokenIdent || (!ok && (!allowNone || name != "n")) {
+		return 0, fmt.Errorf("expected %s (o, d or x) at position %d, got %q", what, p.current.Pos, name)
+	}
+	return radix, p.advance()
+}
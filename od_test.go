@@ -0,0 +1,102 @@
+package bq
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestOdNodeEval(t *testing.T) {
+	data := []byte("hello, world!\n\x00\xff\x01")
+
+	tests := []struct {
+		name  string
+		input string
+		data  []byte
+		want  []string
+	}{
+		{
+			name:  "default hex bytes",
+			input: "od()",
+			data:  data,
+			want: []string{
+				"0000000 68 65 6c 6c 6f 2c 20 77 6f 72 6c 64 21 0a 00 ff",
+				"0000010 01",
+				"0000011",
+			},
+		},
+		{
+			name:  "octal bytes with decimal addresses",
+			input: "od(o, 1, d)",
+			data:  data[14:],
+			want:  []string{"0000000 000 377 001", "0000003"},
+		},
+		{
+			name:  "signed decimal bytes",
+			input: "od(d, 1, n)",
+			data:  []byte{0x7f, 0x80, 0xff},
+			want:  []string{" 127 -128   -1"},
+		},
+		{
+			name:  "partial unit padded",
+			input: "od(x, 4)",
+			data:  []byte{0x01, 0x02, 0x03, 0x04, 0x05},
+			want:  []string{"0000000 " + hexUnit32([]byte{0x01, 0x02, 0x03, 0x04}) + " " + hexUnit32([]byte{0x05, 0, 0, 0}), "0000005"},
+		},
+		{
+			name:  "empty input",
+			input: "od()",
+			data:  []byte{},
+			want:  []string{"0000000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+			dump, ok := result.(*Dump)
+			if !ok {
+				t.Fatalf("EvalBytes() = %T, want *Dump", result)
+			}
+			if !slices.Equal(dump.Lines, tt.want) {
+				t.Errorf("EvalBytes() lines = %q, want %q", dump.Lines, tt.want)
+			}
+		})
+	}
+}
+
+// hexUnit32 formats 4 bytes as od formats a native-order uint32 unit.
+func hexUnit32(b []byte) string {
+	return fmt.Sprintf("%08x", nativeEndian().Uint32(b))
+}
+
+func TestOdParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"od(b)",
+		"od(n)",
+		"od(x, 3)",
+		"od(x, 1, b)",
+		"od(x",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestWriteResultDump(t *testing.T) {
+	result := &Dump{Lines: []string{"0000000 61", "0000001"}}
+	for _, opts := range []Options{{}, {Output: OutputTable}, {Output: OutputRaw}} {
+		var buf bytes.Buffer
+		if err := writeResult(&buf, &OdNode{}, result, opts); err != nil {
+			t.Fatalf("writeResult(%+v) error = %v", opts, err)
+		}
+		if got := buf.String(); got != "0000000 61\n0000001\n" {
+			t.Errorf("writeResult(%+v) = %q, want the dump lines", opts, got)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// RawPrintResult outputs the bare values of the result, one per line, for use in
+// shell scripts. Arrays are space-joined, strings are printed as-is, and nested
+// objects are flattened in field order. Results holding records (values that are
+// themselves lists or objects) cannot be flattened and return an error.
+func RawPrintResult(w io.Writer, result any, opts Options) error {
+	switch r := result.(type) {
+	case []any:
+		for i, val := range r {
+			if err := writeRawElement(w, i, val, opts); err != nil {
+				return err
+			}
+		}
+	case *Object:
+		for _, field := range r.Fields {
+			if nested, ok := field.Value.(*Object); ok {
+				if err := RawPrintResult(w, nested, opts); err != nil {
+					return fmt.Errorf("field %q: %w", field.Name, err)
+				}
+				continue
+			}
+			if _, ok := field.Value.([]any); ok {
+				return fmt.Errorf("raw output cannot render nested record in field %q, use a table output instead", field.Name)
+			}
+			if err := writeRawValue(w, field.Value, opts); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported result type: %T", result)
+	}
+
+	return nil
+}
+
+// writeRawElement outputs the value at index i of a list result, which cannot be
+// a nested record.
+func writeRawElement(w io.Writer, i int, val any, opts Options) error {
+	switch val.(type) {
+	case []any, *Object:
+		return fmt.Errorf("raw output cannot render nested record at index %d, use a table output instead", i)
+	}
+	return writeRawValue(w, val, opts)
+}
+
+// EnvPrintResult outputs the fields of an object result as FIELD=value lines for
+// a shell to eval. Names are uppercased and nested objects are flattened with _
+// joiners (e.g., HEADER_LENGTH). Strings and arrays are single-quoted, and a
+// missing optional field is empty. Results without field names, and fields
+// holding records, return an error.
+func EnvPrintResult(w io.Writer, result any, opts Options) error {
+	obj, ok := result.(*Object)
+	if !ok {
+		return fmt.Errorf("env output needs an object result to name the variables, got %T", result)
+	}
+	return writeEnvFields(w, "", obj, opts)
+}
+
+// writeEnvFields writes the fields of obj, prefixing each name with prefix.
+func writeEnvFields(w io.Writer, prefix string, obj *Object, opts Options) error {
+	for _, field := range obj.Fields {
+		name := prefix + strings.ToUpper(field.Name)
+
+		var value string
+		switch v := field.Value.(type) {
+		case *Object:
+			if err := writeEnvFields(w, name+"_", v, opts); err != nil {
+				return err
+			}
+			continue
+		case []any:
+			return fmt.Errorf("env output cannot render nested record in field %q, use a table output instead", field.Name)
+		case nil:
+		case string:
+			value = shellQuote(v)
+		default:
+			value = formatRawValue(v, opts)
+			if reflect.ValueOf(v).Kind() == reflect.Slice {
+				value = shellQuote(value)
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote single-quotes s for a POSIX shell, escaping embedded single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeRawValue writes a single value on its own line.
+func writeRawValue(w io.Writer, val any, opts Options) error {
+	_, err := fmt.Fprintln(w, formatRawValue(val, opts))
+	return err
+}
+
+// formatRawValue formats a scalar as %v (floats honor the precision) and
+// space-joins the elements of an array.
+func formatRawValue(val any, opts Options) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case float32:
+		return formatFloat(float64(v), 32, opts.FloatPrecision)
+	case float64:
+		return formatFloat(v, 64, opts.FloatPrecision)
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Slice {
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			parts[i] = formatRawValue(rv.Index(i).Interface(), opts)
+		}
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprintf("%v", val)
+}
@@ -0,0 +1,139 @@
+package bq
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRawPrintResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "scalars",
+			input: "<bH",
+			data:  []byte{0xFF, 0x01, 0x02},
+			want:  "-1\n513\n",
+		},
+		{
+			name:  "array space-joined",
+			input: "<b4B",
+			data:  []byte{0x01, 0x02, 0x03, 0x04, 0x05},
+			want:  "1\n2 3 4 5\n",
+		},
+		{
+			name:  "string as-is",
+			input: "sB",
+			data:  []byte{'h', 'i', ' ', 'x', 0, 0x07},
+			want:  "hi x\n7\n",
+		},
+		{
+			name:  "nested object flattened",
+			input: "<bHB | {0 -> header, nested: {1 -> length, 2 -> flag}}",
+			data:  []byte{0xFF, 0x01, 0x02, 0x03},
+			want:  "-1\n513\n3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := RawPrintResult(&buf, result, Options{}); err != nil {
+				t.Fatalf("RawPrintResult() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("RawPrintResult() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawPrintResultNestedRecords(t *testing.T) {
+	result := []any{[]any{int8(1)}, []any{int8(2)}}
+
+	var buf bytes.Buffer
+	if err := RawPrintResult(&buf, result, Options{}); err == nil {
+		t.Error("RawPrintResult() expected error for nested records, got nil")
+	}
+}
+
+func TestEnvPrintResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "uppercased names",
+			input: "<bH | {0 -> width, 1 -> height}",
+			data:  []byte{0xFF, 0x01, 0x02},
+			want:  "WIDTH=-1\nHEIGHT=513\n",
+		},
+		{
+			name:  "nested object flattened",
+			input: "<bHB | {0 -> header, nested: {1 -> length, 2 -> flag}}",
+			data:  []byte{0xFF, 0x01, 0x02, 0x03},
+			want:  "HEADER=-1\nNESTED_LENGTH=513\nNESTED_FLAG=3\n",
+		},
+		{
+			name:  "strings and arrays quoted",
+			input: "s2B | {0 -> name, 1 -> raw}",
+			data:  []byte{'i', 't', '\'', 's', 0, 0x01, 0x02},
+			want:  "NAME='it'\\''s'\nRAW='1 2'\n",
+		},
+		{
+			name:  "missing optional field empty",
+			input: "B | {0 -> a, 1? -> b}",
+			data:  []byte{0x01},
+			want:  "A=1\nB=\n",
+		},
+		{
+			name:    "unnamed values",
+			input:   "<bH",
+			data:    []byte{0xFF, 0x01, 0x02},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = EnvPrintResult(&buf, result, Options{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EnvPrintResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := buf.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("EnvPrintResult() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvPrintResultNestedRecords(t *testing.T) {
+	result := &Object{Fields: []ObjectField{{Name: "recs", Value: []any{[]any{int8(1)}}}}}
+
+	var buf bytes.Buffer
+	if err := EnvPrintResult(&buf, result, Options{}); err == nil {
+		t.Error("EnvPrintResult() expected error for nested records, got nil")
+	}
+}
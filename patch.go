@@ -0,0 +1,171 @@
+package bq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PatchNode overwrites a single value at a fixed offset of the input in place,
+// leaving the rest of the input untouched. The input must be seekable and opened
+// for writing (e.g., a file opened with --in-place).
+type PatchNode struct {
+	Offset int64      // byte offset of the value to overwrite
+	Code   FormatCode // fixed-size integer code of the value
+	Value  any        // new value, typed for the code (e.g., uint32 for I)
+}
+
+// Eval encodes the new value and writes it over the existing bytes at the offset.
+// It returns the written value.
+func (n *PatchNode) Eval(r io.Reader, _ []any) (any, error) {
+	writer, ok := r.(io.WriterAt)
+	if !ok {
+		return nil, fmt.Errorf("patch: input does not support writing in place")
+	}
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil, fmt.Errorf("patch: input does not support seeking")
+	}
+
+	size, err := inputSize(seeker)
+	if err != nil {
+		return nil, seekError("patch", err)
+	}
+	if n.Offset+int64(n.Code.Size) > size {
+		return nil, fmt.Errorf("patch: writing %d bytes at offset %d exceeds the input size of %d bytes",
+			n.Code.Size, n.Offset, size)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, n.Value, n.Code.binaryOrder()); err != nil {
+		return nil, fmt.Errorf("patch: %w", err)
+	}
+	if _, err := writer.WriteAt(buf.Bytes(), n.Offset); err != nil {
+		return nil, fmt.Errorf("patch: failed to write at offset %d: %w", n.Offset, err)
+	}
+
+	return []any{n.Value}, nil
+}
+
+// inputSize returns the total size of a seekable input, restoring its position.
+func inputSize(s io.Seeker) (int64, error) {
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	size, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Seek(pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// parsePatchFunc parses: 'patch' '(' NUMBER ',' FormatExpr ',' '-'? NUMBER ')'
+func (p *Parser) parsePatchFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'patch'"); err != nil {
+		return nil, err
+	}
+
+	offset, err := p.parseInt("patch offset")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after patch offset"); err != nil {
+		return nil, err
+	}
+
+	code, err := p.parseSingleFormat("patch code")
+	if err != nil {
+		return nil, err
+	}
+	if !isIntegerCode(code.Code) {
+		return nil, fmt.Errorf("patch code must be an integer code, got %c", code.Code)
+	}
+	if err := p.expect(TokenComma, "',' after patch code"); err != nil {
+		return nil, err
+	}
+
+	value, err := p.parseIntegerLiteral(code, "patch value")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after patch arguments"); err != nil {
+		return nil, err
+	}
+	return &PatchNode{Offset: int64(offset), Code: code, Value: value}, nil
+}
+
+// parseIntegerLiteral parses: '-'? NUMBER, a literal value of an integer code.
+func (p *Parser) parseIntegerLiteral(code FormatCode, what string) (any, error) {
+	sign := ""
+	if p.current.Type == TokenMinus {
+		sign = "-"
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.current.Type != TokenNumber {
+		return nil, fmt.Errorf("expected %s at position %d, got %q", what, p.current.Pos, p.current.Value)
+	}
+	value, err := parseIntegerValue(code, sign+p.current.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", what, err)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// parseIntegerValue parses a decimal or hex literal, optionally signed (e.g., -0x01),
+// into the Go type of an integer code, rejecting values out of the code's range
+// (e.g., 256 for B).
+func parseIntegerValue(code FormatCode, literal string) (any, error) {
+	bits := code.Size * 8
+	sign := ""
+	if strings.HasPrefix(literal, "-") {
+		sign, literal = "-", literal[1:]
+	}
+	digits, base := splitNumberBase(literal)
+
+	if code.Signed {
+		v, err := strconv.ParseInt(sign+digits, base, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch code.Size {
+		case 1:
+			return int8(v), nil
+		case 2:
+			return int16(v), nil
+		case 4:
+			return int32(v), nil
+		default:
+			return v, nil
+		}
+	}
+
+	v, err := strconv.ParseUint(sign+digits, base, bits)
+	if err != nil {
+		return nil, err
+	}
+	switch code.Size {
+	case 1:
+		return uint8(v), nil
+	case 2:
+		return uint16(v), nil
+	case 4:
+		return uint32(v), nil
+	default:
+		return v, nil
+	}
+}
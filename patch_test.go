@@ -0,0 +1,130 @@
+package bq
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatchNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "little-endian uint16",
+			input: "patch(1, <H, 4660)",
+			data:  []byte{0x01, 0x02, 0x03, 0x04},
+			want:  []byte{0x01, 0x34, 0x12, 0x04},
+		},
+		{
+			name:  "big-endian uint32",
+			input: "patch(0, >I, 1)",
+			data:  []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xAA},
+			want:  []byte{0x00, 0x00, 0x00, 0x01, 0xAA},
+		},
+		{
+			name:  "negative int8",
+			input: "patch(2, b, -1)",
+			data:  []byte{0x00, 0x00, 0x00},
+			want:  []byte{0x00, 0x00, 0xFF},
+		},
+		{
+			name:  "last bytes",
+			input: "patch(2, <H, 0)",
+			data:  []byte{0x01, 0x02, 0x03, 0x04},
+			want:  []byte{0x01, 0x02, 0x00, 0x00},
+		},
+		{
+			name:    "past end of input",
+			input:   "patch(3, <H, 0)",
+			data:    []byte{0x01, 0x02, 0x03, 0x04},
+			want:    []byte{0x01, 0x02, 0x03, 0x04},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.bin")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			defer func() { _ = f.Close() }()
+
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			_, err = node.Eval(newCountingReader(f), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("patched data = %x, want %x", got, tt.want)
+			}
+		})
+	}
+
+	// Inputs which cannot be written in place are rejected
+	node, _ := ParseExpression("patch(0, B, 1)")
+	if _, err := node.Eval(newCountingReader(bytes.NewReader([]byte{0})), nil); err == nil {
+		t.Error("Eval() expected error for a read-only input, got nil")
+	}
+}
+
+func TestParsePatchFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr bool
+	}{
+		{"unsigned", "patch(4, <I, 42)", uint32(42), false},
+		{"signed", "patch(0, >h, -2)", int16(-2), false},
+		{"uint64 max", "patch(0, Q, 18446744073709551615)", uint64(18446744073709551615), false},
+		{"hex", "patch(0, <H, 0xBEEF)", uint16(0xBEEF), false},
+		{"signed hex", "patch(0, b, -0x01)", int8(-1), false},
+		{"signed hex minimum", "patch(0, b, -0x80)", int8(-128), false},
+		{"signed hex out of range", "patch(0, b, 0xFF)", nil, true},
+		{"out of range", "patch(0, B, 256)", nil, true},
+		{"negative unsigned", "patch(0, B, -1)", nil, true},
+		{"negative offset", "patch(-1, B, 1)", nil, true},
+		{"string code", "patch(0, s, 1)", nil, true},
+		{"array code", "patch(0, 2B, 1)", nil, true},
+		{"missing value", "patch(0, B)", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			patch, ok := node.(*PatchNode)
+			if !ok {
+				t.Fatalf("ParseExpression() = %T, want *PatchNode", node)
+			}
+			if patch.Value != tt.want {
+				t.Errorf("PatchNode.Value = %v (%T), want %v (%T)", patch.Value, patch.Value, tt.want, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package bq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Errors of a wrapper of the input whose underlying reader cannot seek, such as
+// a pipe, or cannot write in place, such as a file opened read-only.
+var (
+	errNotSeekable = errors.New("input does not support seeking")
+	errNotWritable = errors.New("input does not support writing in place")
+)
+
+// seekInner forwards a seek to r if it is an io.Seeker, so the wrappers of the
+// input (e.g., --max-bytes, --timeout, the capture of the consumed bytes) keep
+// seek(), follow() and patch() working.
+func seekInner(r io.Reader, offset int64, whence int) (int64, error) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return 0, errNotSeekable
+	}
+	return seeker.Seek(offset, whence)
+}
+
+// writeAtInner forwards a positional write to r if it is an io.WriterAt.
+func writeAtInner(r io.Reader, p []byte, off int64) (int, error) {
+	writer, ok := r.(io.WriterAt)
+	if !ok {
+		return 0, errNotWritable
+	}
+	return writer.WriteAt(p, off)
+}
+
+// seekError wraps the error of a seek on the input for the named function,
+// reporting a failed seek (e.g., on a pipe) as an input which cannot seek.
+func seekError(name string, err error) error {
+	if errors.Is(err, errNotSeekable) {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return fmt.Errorf("%s: %w: %w", name, errNotSeekable, err)
+}
+
+// offsetReader is implemented by readers which track the number of bytes consumed.
+type offsetReader interface {
+	Offset() int64
+}
+
+// countingReader wraps an io.Reader and tracks the current offset into the input.
+// Seeks are forwarded when the underlying reader supports them.
+type countingReader struct {
+	r        io.Reader
+	offset   int64
+	consumed int64     // bytes read, whatever the seeks in between
+	stdout   io.Writer // destination of write("-") and json(), if set
+}
+
+// newCountingReader wraps r, unless it already tracks its offset.
+func newCountingReader(r io.Reader) io.Reader {
+	if _, ok := r.(offsetReader); ok {
+		return r
+	}
+	return &countingReader{r: r}
+}
+
+// Read reads from the underlying reader and advances the offset.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	c.consumed += int64(n)
+	return n, err
+}
+
+// Seek forwards to the underlying reader if it is an io.Seeker.
+func (c *countingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := seekInner(c.r, offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	c.offset = pos
+	return pos, nil
+}
+
+// WriteAt forwards to the underlying reader if it is an io.WriterAt, leaving
+// the offset unchanged.
+func (c *countingReader) WriteAt(p []byte, off int64) (int, error) {
+	return writeAtInner(c.r, p, off)
+}
+
+// Offset returns the number of bytes consumed (or the position after a seek).
+func (c *countingReader) Offset() int64 {
+	return c.offset
+}
+
+// Consumed returns the number of bytes read, which unlike the offset a seek
+// does not change.
+func (c *countingReader) Consumed() int64 {
+	return c.consumed
+}
+
+// Stdout returns the destination of write("-") and json() in the evaluation
+// reading from c, or nil for os.Stdout.
+func (c *countingReader) Stdout() io.Writer {
+	return c.stdout
+}
+
+// stdoutReader is implemented by readers which carry the destination of the
+// standard output of the evaluation, so a parsed node writes wherever the reader
+// it is evaluated with says.
+type stdoutReader interface {
+	Stdout() io.Writer
+}
+
+// WithStdout returns a reader for Node.Eval reading from r, whose write("-") and
+// json() stages write to w instead of os.Stdout.
+func WithStdout(r io.Reader, w io.Writer) io.Reader {
+	return &countingReader{r: r, stdout: w}
+}
+
+// evalStdout returns the standard output of the evaluation reading from r:
+// the writer r carries, or os.Stdout.
+func evalStdout(r io.Reader) io.Writer {
+	if sr, ok := r.(stdoutReader); ok && sr.Stdout() != nil {
+		return sr.Stdout()
+	}
+	return os.Stdout
+}
+
+// newRecordReader returns a reader over rec, the bytes of a record read from
+// parent, evaluated in the same context: its write("-") goes where the parent's
+// does.
+func newRecordReader(parent, rec io.Reader) io.Reader {
+	var stdout io.Writer
+	if sr, ok := parent.(stdoutReader); ok {
+		stdout = sr.Stdout()
+	}
+	return &countingReader{r: rec, stdout: stdout}
+}
+
+// currentOffset returns the current offset of the reader, either tracked by the
+// reader itself or queried via io.Seeker.
+func currentOffset(r io.Reader) (int64, error) {
+	switch rr := r.(type) {
+	case offsetReader:
+		return rr.Offset(), nil
+	case io.Seeker:
+		return rr.Seek(0, io.SeekCurrent)
+	default:
+		return 0, fmt.Errorf("input offset is not available")
+	}
+}
+
+// teeReader copies the bytes read from r into buf like io.TeeReader, but forwards
+// seeks and positional writes to r. The captured bytes are those read in one run
+// from the offset start: a read after a seek moved the position elsewhere restarts
+// the capture at the new position, so seek(16) | <I captures the 4 bytes at 16.
+type teeReader struct {
+	r     io.Reader
+	buf   *bytes.Buffer
+	start int64 // offset of the first captured byte
+	pos   int64 // current offset of r
+}
+
+// newTeeReader captures the bytes read from r into buf, r being at offset start.
+func newTeeReader(r io.Reader, buf *bytes.Buffer, start int64) *teeReader {
+	return &teeReader{r: r, buf: buf, start: start, pos: start}
+}
+
+// Read reads from the underlying reader and captures the bytes read.
+func (t *teeReader) Read(p []byte) (int, error) {
+	if t.pos != t.start+int64(t.buf.Len()) {
+		t.buf.Reset()
+		t.start = t.pos
+	}
+	n, err := t.r.Read(p)
+	t.buf.Write(p[:n])
+	t.pos += int64(n)
+	return n, err
+}
+
+// Seek forwards to the underlying reader if it is an io.Seeker.
+func (t *teeReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := seekInner(t.r, offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	t.pos = pos
+	return pos, nil
+}
+
+// WriteAt forwards to the underlying reader if it is an io.WriterAt.
+func (t *teeReader) WriteAt(p []byte, off int64) (int, error) {
+	return writeAtInner(t.r, p, off)
+}
+
+// limitReader reads at most n bytes in total from r like io.LimitReader, but
+// forwards seeks and positional writes to r. A seek leaves the bytes left
+// unchanged, so the limit stays a cap on the bytes read whatever the position.
+type limitReader struct {
+	r io.Reader
+	n int64 // bytes left to read
+}
+
+// Read reads from the underlying reader, up to the bytes left.
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// Seek forwards to the underlying reader if it is an io.Seeker.
+func (l *limitReader) Seek(offset int64, whence int) (int64, error) {
+	return seekInner(l.r, offset, whence)
+}
+
+// WriteAt forwards to the underlying reader if it is an io.WriterAt.
+func (l *limitReader) WriteAt(p []byte, off int64) (int, error) {
+	return writeAtInner(l.r, p, off)
+}
@@ -0,0 +1,53 @@
+package bq
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCountingReader(t *testing.T) {
+	r := newCountingReader(bytes.NewReader([]byte{1, 2, 3, 4, 5, 6}))
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("ReadFull() error = %v", err)
+	}
+	if got, _ := currentOffset(r); got != 4 {
+		t.Errorf("currentOffset() = %d, want 4", got)
+	}
+
+	// Seeks are forwarded and update the offset
+	if _, err := r.(io.Seeker).Seek(1, io.SeekStart); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+	if got, _ := currentOffset(r); got != 1 {
+		t.Errorf("currentOffset() after seek = %d, want 1", got)
+	}
+
+	// Wrapping twice keeps the same tracker
+	if newCountingReader(r) != r {
+		t.Error("newCountingReader() wrapped an offset-tracking reader again")
+	}
+}
+
+func TestCurrentOffset(t *testing.T) {
+	// Seekers report their position directly
+	br := bytes.NewReader([]byte{1, 2, 3})
+	_, _ = br.ReadByte()
+	if got, err := currentOffset(br); err != nil || got != 1 {
+		t.Errorf("currentOffset() = %d, %v, want 1", got, err)
+	}
+
+	// Plain readers have no offset
+	if _, err := currentOffset(io.MultiReader(strings.NewReader("x"))); err == nil {
+		t.Error("currentOffset() expected error for plain reader, got nil")
+	}
+
+	// Seeking a non-seekable reader errors
+	cr := newCountingReader(io.MultiReader(strings.NewReader("x")))
+	if _, err := cr.(io.Seeker).Seek(0, io.SeekStart); err == nil {
+		t.Error("Seek() expected error for non-seekable reader, got nil")
+	}
+}
@@ -0,0 +1,770 @@
+package bq
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// SplitNode reads records separated by a delimiter byte (e.g., 0x0A) until EOF,
+// evaluating the inner expression on each record in its own bounded reader. A
+// trailing delimiter at the end of the input does not start an empty record.
+type SplitNode struct {
+	Delim byte // record separator
+	Inner Node // expression evaluated on each record
+}
+
+// Eval splits the input into records and returns the result of each record. A
+// record longer than maxArrayLen bytes is an error, so an input without any
+// delimiter is not buffered whole.
+func (n *SplitNode) Eval(r io.Reader, _ []any) (any, error) {
+	records := make([]any, 0)
+	br := bufio.NewReader(r)
+	var chunk []byte
+
+	for {
+		line, err := br.ReadSlice(n.Delim)
+		if len(chunk)+len(line) > maxArrayLen {
+			return nil, fmt.Errorf("split: record %d exceeds the limit of %d bytes", len(records), maxArrayLen)
+		}
+		chunk = append(chunk, line...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("split: %w", err)
+		}
+
+		rec, err := n.evalRecord(r, chunk[:len(chunk)-1], len(records))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+		chunk = nil
+	}
+
+	// The last record may not be terminated by a delimiter
+	if len(chunk) > 0 {
+		rec, err := n.evalRecord(r, chunk, len(records))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// evalRecord evaluates the inner expression on a single record read from r.
+func (n *SplitNode) evalRecord(r io.Reader, chunk []byte, idx int) (any, error) {
+	rec, err := n.Inner.Eval(newRecordReader(r, bytes.NewReader(chunk)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("split: record %d: %w", idx, err)
+	}
+	return rec, nil
+}
+
+// UntilZeroNode reads fixed-size records until a record whose bytes are all zero,
+// which terminates the list and is not included, as in arrays of structs ending
+// with a null sentinel. A clean EOF at a record boundary also ends the list.
+type UntilZeroNode struct {
+	Inner *FormatNode // fixed-size record format
+	Size  int         // bytes per record
+}
+
+// Eval reads the records up to the all-zero sentinel and returns their results.
+func (n *UntilZeroNode) Eval(r io.Reader, _ []any) (any, error) {
+	records := make([]any, 0)
+	buf := make([]byte, n.Size)
+
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("until_zero: record %d: %w", len(records), err)
+		}
+		if !bytes.ContainsFunc(buf, func(r rune) bool { return r != 0 }) {
+			break
+		}
+
+		rec, err := n.Inner.Eval(newRecordReader(r, bytes.NewReader(buf)), nil)
+		if err != nil {
+			return nil, fmt.Errorf("until_zero: record %d: %w", len(records), err)
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// parseUntilZeroFunc parses: 'until_zero' '(' FormatExpr ')'
+func (p *Parser) parseUntilZeroFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'until_zero'"); err != nil {
+		return nil, err
+	}
+
+	pos := p.current.Pos
+	node, err := p.parseFormatExpr()
+	if err != nil {
+		return nil, err
+	}
+	inner := node.(*FormatNode)
+
+	// Check the counts first, so the size of the record cannot overflow
+	size := 0
+	for _, fc := range inner.Formats {
+		if fc.Count > maxArrayLen {
+			return nil, fmt.Errorf("until_zero record at position %d must be at most %d bytes, got a count of %d", pos, maxArrayLen, fc.Count)
+		}
+		n, fixed := fc.byteSize()
+		if !fixed {
+			return nil, fmt.Errorf("until_zero record at position %d must have a fixed size, got %c", pos, fc.Code)
+		}
+		if n > maxArrayLen-size {
+			return nil, fmt.Errorf("until_zero record at position %d must be at most %d bytes", pos, maxArrayLen)
+		}
+		size += n
+	}
+
+	if err := p.expect(TokenRParen, "')' after until_zero record"); err != nil {
+		return nil, err
+	}
+	return &UntilZeroNode{Inner: inner, Size: size}, nil
+}
+
+// StrideNode reads consecutive records occupying a fixed number of bytes each,
+// until EOF, evaluating the inner expression on each record. A record which does
+// not consume exactly the stride is an error, unless Pad is set, in which case its
+// trailing bytes are skipped as padding.
+type StrideNode struct {
+	Stride int  // bytes per record
+	Inner  Node // expression evaluated on each record
+	Pad    bool // skip the unread bytes of a record instead of erroring
+}
+
+// Eval reads the records and returns the result of each record.
+func (n *StrideNode) Eval(r io.Reader, _ []any) (any, error) {
+	records := make([]any, 0)
+
+	for {
+		chunk, err := readN(r, int64(n.Stride))
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("stride: record %d: %w", len(records), err)
+		}
+
+		rec := bytes.NewReader(chunk)
+		result, err := n.Inner.Eval(newRecordReader(r, rec), nil)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("stride: record %d exceeds the stride of %d bytes: %w", len(records), n.Stride, err)
+			}
+			return nil, fmt.Errorf("stride: record %d: %w", len(records), err)
+		}
+		if rec.Len() > 0 && !n.Pad {
+			return nil, fmt.Errorf("stride: record %d consumed %d of %d bytes", len(records), n.Stride-rec.Len(), n.Stride)
+		}
+		records = append(records, result)
+	}
+
+	return records, nil
+}
+
+// parseStrideFunc parses: 'stride' '(' NUMBER ',' Pipe (',' 'pad')? ')'
+func (p *Parser) parseStrideFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'stride'"); err != nil {
+		return nil, err
+	}
+
+	pos := p.current.Pos
+	stride, err := p.parseInt("stride size")
+	if err != nil {
+		return nil, err
+	}
+	if stride < 1 || stride > maxArrayLen {
+		return nil, fmt.Errorf("stride size at position %d must be between 1 and %d, got %d", pos, maxArrayLen, stride)
+	}
+	if err := p.expect(TokenComma, "',' after stride size"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	node := &StrideNode{Stride: stride, Inner: inner}
+
+	if p.current.Type == TokenComma {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.current.Type != TokenIdent || p.current.Value != "pad" {
+			return nil, fmt.Errorf("expected 'pad' at position %d, got %q", p.current.Pos, p.current.Value)
+		}
+		node.Pad = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after stride expression"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// RepeatPrevNode reads a number of records given by the single integer value
+// produced by the left side of a pipe, for the "count then elements" shape where
+// the count and the elements are separate stages (e.g., <H | repeat_prev(<Iq)).
+type RepeatPrevNode struct {
+	Inner Node // expression evaluated for each record
+}
+
+// Eval reads the records and returns the result of each record.
+func (n *RepeatPrevNode) Eval(r io.Reader, values []any) (any, error) {
+	if len(values) != 1 {
+		return nil, fmt.Errorf("repeat_prev: expected a single count value, got %d values", len(values))
+	}
+	count, err := toInt64(values[0])
+	if err != nil {
+		return nil, fmt.Errorf("repeat_prev: count: %w", err)
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("repeat_prev: negative count %d", count)
+	}
+
+	// The count comes from the input, so the slice grows as records are read
+	records := make([]any, 0)
+	for i := int64(0); i < count; i++ {
+		rec, err := n.Inner.Eval(r, nil)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("repeat_prev: record %d of %d: %w", i, count, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// parseRepeatPrevFunc parses: 'repeat_prev' '(' Pipe ')'
+func (p *Parser) parseRepeatPrevFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'repeat_prev'"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after repeat_prev expression"); err != nil {
+		return nil, err
+	}
+	return &RepeatPrevNode{Inner: inner}, nil
+}
+
+// RepeatNode reads records of a format expression until the input ends, as in a
+// log file of identical records, e.g. repeat(<bH). A trailing pipe stage applies
+// to each record, so repeat(<bH) | {0 -> a, 1 -> b} names the values of every
+// record.
+type RepeatNode struct {
+	Inner *FormatNode // record format
+}
+
+// Eval reads the records up to a clean EOF at a record boundary and returns the
+// values of each record. An EOF in the middle of a record is an error.
+func (n *RepeatNode) Eval(r io.Reader, _ []any) (any, error) {
+	records := make([]any, 0)
+	err := n.each(r, func(rec any) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// each reads the records up to a clean EOF, calling fn with each record as soon
+// as it is read. An error of fn stops the reading and is returned as is.
+func (n *RepeatNode) each(r io.Reader, fn func(rec any) error) error {
+	count := 0
+	var fnErr error
+	err := n.Inner.ReadEach(r, func(values []any) error {
+		if fnErr = fn(n.Inner.record(slices.Clone(values))); fnErr != nil {
+			return fnErr
+		}
+		count++
+		return nil
+	})
+	if err != nil && fnErr == nil {
+		return fmt.Errorf("repeat: record %d: %w", count, err)
+	}
+	return err
+}
+
+// repeatsRecords returns true if the node produces the records of repeat(): the
+// repeat() itself, or a pipe of stages after it, which keep a list of records.
+func repeatsRecords(node Node) bool {
+	switch n := node.(type) {
+	case *RepeatNode:
+		return true
+	case *PipeNode:
+		return repeatsRecords(n.Left)
+	default:
+		return false
+	}
+}
+
+// streamsRecords returns true if the node produces the records of repeat() one at
+// a time as they are read, so they need not be collected: the repeat() itself,
+// or a pipe of stages after it other than a consumed sink such as checksum(),
+// which works on the bytes of all the records.
+func streamsRecords(node Node) bool {
+	switch n := node.(type) {
+	case *RepeatNode:
+		return true
+	case *PipeNode:
+		_, isSink := n.Right.(ConsumedSink)
+		return !isSink && streamsRecords(n.Left)
+	default:
+		return false
+	}
+}
+
+// eachRecord reads the records of a streaming node (see streamsRecords), calling
+// fn with each record once it went through the stages of the node, before the
+// next record is read. A record filter such as where() drops the records it does
+// not match, and an error of fn is returned as is.
+func eachRecord(r io.Reader, node Node, fn func(rec any) error) error {
+	switch n := node.(type) {
+	case *RepeatNode:
+		return n.each(r, fn)
+	case *PipeNode:
+		n.inherit()
+		i := 0
+		if where, ok := n.Right.(*WhereNode); ok {
+			return eachRecord(r, n.Left, func(rec any) error {
+				match, err := where.match(rec)
+				if err != nil {
+					return fmt.Errorf("where: record %d: %w", i, err)
+				}
+				i++
+				if !match {
+					return nil
+				}
+				return fn(rec)
+			})
+		}
+		return eachRecord(r, n.Left, func(rec any) error {
+			res, err := pipeResult(r, rec, n.Right)
+			if err != nil {
+				return fmt.Errorf("repeat: record %d: %w", i, err)
+			}
+			i++
+			return fn(res)
+		})
+	}
+	return fmt.Errorf("%T does not produce a record stream", node)
+}
+
+// filtersRecords returns true if the node works on a whole list of records,
+// keeping some of them, rather than on the values of a single record.
+func filtersRecords(node Node) bool {
+	_, ok := node.(*WhereNode)
+	return ok
+}
+
+// evalRecords passes each record of the left side through the right side on its
+// own, returning the result of each record.
+func (n *PipeNode) evalRecords(r io.Reader, records []any) (any, error) {
+	results := make([]any, 0, len(records))
+	for i, rec := range records {
+		res, err := pipeResult(r, rec, n.Right)
+		if err != nil {
+			return nil, fmt.Errorf("repeat: record %d: %w", i, err)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// parseRepeatFunc parses: 'repeat' '(' FormatExpr ')'
+func (p *Parser) parseRepeatFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'repeat'"); err != nil {
+		return nil, err
+	}
+
+	node, err := p.parseFormatExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after repeat record"); err != nil {
+		return nil, err
+	}
+	return &RepeatNode{Inner: node.(*FormatNode)}, nil
+}
+
+// ReparseNode parses the last incoming value, a byte slice such as an extracted
+// payload, with an inner expression, for nested container formats whose payload
+// structure is only known after extracting it.
+type ReparseNode struct {
+	Inner Node // expression evaluated on the byte slice
+}
+
+// Eval evaluates the inner expression against the bytes of the last value.
+func (n *ReparseNode) Eval(r io.Reader, values []any) (any, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("reparse: no value to parse")
+	}
+	last := values[len(values)-1]
+	blob, ok := last.([]uint8)
+	if !ok {
+		return nil, fmt.Errorf("reparse: expected a byte slice ([]uint8), got %T", last)
+	}
+
+	result, err := n.Inner.Eval(newRecordReader(r, bytes.NewReader(blob)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("reparse: %w", err)
+	}
+	return result, nil
+}
+
+// parseReparseFunc parses: 'reparse' '(' Pipe ')'
+func (p *Parser) parseReparseFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'reparse'"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after reparse expression"); err != nil {
+		return nil, err
+	}
+	return &ReparseNode{Inner: inner}, nil
+}
+
+// WhereNode keeps the records whose field compares true against a literal, as in
+// split(0x0A, <Iq) | where(0 > 100). The field is an index into the values of a
+// record, or the name (or position) of a field of an object record. Signed and
+// unsigned integers compare by value, and floats as float64.
+type WhereNode struct {
+	Index int           // index of the compared field (when Name is empty)
+	Name  string        // name of the compared object field
+	Op    string        // comparison operator: ==, !=, <, <=, >, >=
+	Value *ArithLiteral // literal compared against, an int64 or a float64
+}
+
+// Eval returns the records matching the comparison, keeping their order.
+func (n *WhereNode) Eval(_ io.Reader, values []any) (any, error) {
+	kept := make([]any, 0)
+	for i, rec := range values {
+		match, err := n.match(rec)
+		if err != nil {
+			return nil, fmt.Errorf("where: record %d: %w", i, err)
+		}
+		if match {
+			kept = append(kept, rec)
+		}
+	}
+	return kept, nil
+}
+
+// match returns true if the compared field of a record matches the comparison.
+func (n *WhereNode) match(rec any) (bool, error) {
+	field, err := n.field(rec)
+	if err != nil {
+		return false, err
+	}
+	order, err := compareLiteral(field, n.Value)
+	if err != nil {
+		return false, err
+	}
+
+	switch n.Op {
+	case "==":
+		return order == 0, nil
+	case "!=":
+		return order != 0, nil
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+// field returns the compared field of a record.
+func (n *WhereNode) field(rec any) (any, error) {
+	switch rec.(type) {
+	case []any, *Object:
+		return lookupField(rec, n.Index, n.Name)
+	default:
+		return nil, fmt.Errorf("expected a record, got %T; where() filters records such as those of split()", rec)
+	}
+}
+
+// lookupField returns a field of a record: the value at an index of a record of
+// values, or the field of an object record with the name (or at the index when
+// the name is empty).
+func lookupField(rec any, index int, name string) (any, error) {
+	switch r := rec.(type) {
+	case []any:
+		if name != "" {
+			return nil, fmt.Errorf("no field %q in a record of values, use an index", name)
+		}
+		if index < 0 || index >= len(r) {
+			return nil, fmt.Errorf("index %d out of range (have %d values)", index, len(r))
+		}
+		return r[index], nil
+	case *Object:
+		if name == "" {
+			if index < 0 || index >= len(r.Fields) {
+				return nil, fmt.Errorf("index %d out of range (have %d fields)", index, len(r.Fields))
+			}
+			return r.Fields[index].Value, nil
+		}
+		for _, f := range r.Fields {
+			if f.Name == name {
+				return f.Value, nil
+			}
+		}
+		return nil, fmt.Errorf("no field %q", name)
+	default:
+		return nil, fmt.Errorf("expected values or an object, got %T", rec)
+	}
+}
+
+// parseFieldRef parses: NUMBER | IDENTIFIER, the index or name of a record field.
+func (p *Parser) parseFieldRef(what string) (int, string, error) {
+	p.rescanName()
+	switch p.current.Type {
+	case TokenNumber:
+		idx, err := p.parseInt(what)
+		return idx, "", err
+	case TokenIdent:
+		name := p.current.Value
+		return 0, name, p.advance()
+	default:
+		return 0, "", fmt.Errorf("expected a field index or name at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+}
+
+// compareLiteral compares a numeric value against a literal, returning -1, 0 or
+// +1. Integers compare exactly, including uint64 values beyond the int64 range,
+// and floats (on either side) compare as float64.
+func compareLiteral(val any, lit *ArithLiteral) (int, error) {
+	var f float64
+	switch v := val.(type) {
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case uint64:
+		if v <= math.MaxInt64 {
+			return compareLiteral(int64(v), lit)
+		}
+		if _, ok := lit.Value.(int64); ok {
+			return 1, nil // beyond any int64 literal
+		}
+		f = float64(v)
+	default:
+		n, err := toInt64(val)
+		if err != nil {
+			return 0, err
+		}
+		if l, ok := lit.Value.(int64); ok {
+			return cmp.Compare(n, l), nil
+		}
+		f = float64(n)
+	}
+	return cmp.Compare(f, toFloat64(lit.Value)), nil
+}
+
+// parseWhereFunc parses: 'where' '(' (NUMBER | IDENTIFIER) CompareOp '-'? NUMBER ')'
+func (p *Parser) parseWhereFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'where'"); err != nil {
+		return nil, err
+	}
+
+	index, name, err := p.parseFieldRef("where field index")
+	if err != nil {
+		return nil, err
+	}
+	node := &WhereNode{Index: index, Name: name}
+
+	// '<' and '>' are tokenized as byte orders, the other operators as comparisons
+	switch {
+	case p.current.Type == TokenCompare, p.current.Value == "<", p.current.Value == ">":
+		node.Op = p.current.Value
+	default:
+		return nil, fmt.Errorf("expected a comparison operator (==, !=, <, <=, >, >=) at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	negative := false
+	if p.current.Type == TokenMinus {
+		negative = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.current.Type != TokenNumber {
+		return nil, fmt.Errorf("expected a number to compare against at position %d, got %q", p.current.Pos, p.current.Value)
+	}
+	lit, err := parseNumberLiteral(p.current.Value)
+	if err != nil {
+		return nil, err
+	}
+	if negative {
+		switch v := lit.Value.(type) {
+		case int64:
+			lit.Value = -v
+		case float64:
+			lit.Value = -v
+		}
+	}
+	node.Value = lit
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after where comparison"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// writeRecordFooter writes a summary line below the table of a record stream,
+// counting the records and the bytes consumed, e.g. "# 42 records, 1008 bytes".
+// The bytes are those read, not the final offset, which a seek() or --offset
+// moves. Results other than a list of records write nothing.
+func writeRecordFooter(w io.Writer, node Node, result any, consumed int64) error {
+	records, ok := result.([]any)
+	if !ok || recordNode(node) == nil {
+		return nil
+	}
+	return writeRecordCount(w, len(records), consumed)
+}
+
+// writeRecordCount prints the footer line counting the records and their bytes.
+func writeRecordCount(w io.Writer, count int, consumed int64) error {
+	noun := "records"
+	if count == 1 {
+		noun = "record"
+	}
+	_, err := fmt.Fprintf(w, "# %d %s, %d bytes\n", count, noun, consumed)
+	return err
+}
+
+// recordNode returns the node producing each record of a node whose result is
+// a list of records, or nil if the node does not produce records.
+func recordNode(node Node) Node {
+	switch n := node.(type) {
+	case *SplitNode:
+		return n.Inner
+	case *UntilZeroNode:
+		return n.Inner
+	case *StrideNode:
+		return n.Inner
+	case *RepeatPrevNode:
+		return n.Inner
+	case *TableNode:
+		return n.Inner
+	case *AtNode:
+		// The expression at the offset may read a table of its own
+		return recordNode(n.Inner)
+	case *RepeatNode:
+		return n.Inner
+	case *PipeNode:
+		// A filter keeps the records of its left side
+		if filtersRecords(n.Right) {
+			return recordNode(n.Left)
+		}
+		// The stages after repeat() produce each record from the values of its
+		// format
+		if repeatsRecords(n.Left) {
+			return &PipeNode{Left: recordNode(n.Left), Right: n.Right}
+		}
+		// Records produced on the right of a pipe (e.g., by repeat_prev)
+		return recordNode(n.Right)
+	case *NamedNode:
+		return recordNode(n.Inner)
+	case *SeekNode:
+		return recordNode(n.Inner)
+	default:
+		return nil
+	}
+}
+
+// parseSplitFunc parses: 'split' '(' NUMBER ',' Pipe ')'
+func (p *Parser) parseSplitFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'split'"); err != nil {
+		return nil, err
+	}
+
+	pos := p.current.Pos
+	delim, err := p.parseInt("split delimiter byte")
+	if err != nil {
+		return nil, err
+	}
+	if delim > 0xFF {
+		return nil, fmt.Errorf("split delimiter at position %d must be a single byte, got %d", pos, delim)
+	}
+	if err := p.expect(TokenComma, "',' after split delimiter"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after split expression"); err != nil {
+		return nil, err
+	}
+	return &SplitNode{Delim: byte(delim), Inner: inner}, nil
+}
@@ -0,0 +1,725 @@
+package bq
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    [][]any
+		wantErr bool
+	}{
+		{
+			name:  "newline delimited",
+			input: "split(0x0A, <BB)",
+			data:  []byte("ab\ncd\n"),
+			want:  [][]any{{uint8('a'), uint8('b')}, {uint8('c'), uint8('d')}},
+		},
+		{
+			name:  "unterminated last record",
+			input: "split(10, <H)",
+			data:  []byte{0x01, 0x00, 0x0A, 0x02, 0x00},
+			want:  [][]any{{uint16(1)}, {uint16(2)}},
+		},
+		{
+			name:  "records shorter than the delimiter spacing",
+			input: "split(0, <B)",
+			data:  []byte{0x01, 0x02, 0x00, 0x03, 0x00},
+			want:  [][]any{{uint8(1)}, {uint8(3)}},
+		},
+		{
+			name:  "empty input",
+			input: "split(0, <B)",
+			data:  []byte{},
+			want:  [][]any{},
+		},
+		{
+			name:    "record too short for the inner expression",
+			input:   "split(0x0A, <H)",
+			data:    []byte{0x01, 0x0A},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			records := result.([]any)
+			if len(records) != len(tt.want) {
+				t.Fatalf("Eval() returned %d records, want %d", len(records), len(tt.want))
+			}
+			for i, rec := range records {
+				values := rec.([]any)
+				if len(values) != len(tt.want[i]) {
+					t.Fatalf("record %d = %v, want %v", i, values, tt.want[i])
+				}
+				for j := range values {
+					if values[j] != tt.want[i][j] {
+						t.Errorf("record %d value %d = %v, want %v", i, j, values[j], tt.want[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSplitRecordLimit(t *testing.T) {
+	// A record longer than the read buffer is still read whole
+	long := append(bytes.Repeat([]byte{'a'}, 10000), '\n')
+	result, err := EvalBytes("split(0x0A, *B)", long)
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	if got := len(result.([]any)[0].([]any)[0].([]uint8)); got != 10000 {
+		t.Errorf("EvalBytes() record of %d bytes, want 10000", got)
+	}
+
+	// An input without a delimiter stops at the cap instead of buffering it all
+	_, err = EvalBytes("split(0x0A, B)", bytes.Repeat([]byte{'a'}, maxArrayLen+1))
+	if err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+		t.Errorf("EvalBytes() error = %v, want record limit error", err)
+	}
+}
+
+func TestSplitParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"split(0x100, B)",
+		"split(10)",
+		"split(B, 10)",
+		"split(10, B",
+		"<B | split(10, B)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestPrettyPrintSplitRecords(t *testing.T) {
+	node, err := ParseExpression("split(0x0A, <BB | {0 -> x, 1 -> y})")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte("ab\ncd")), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+	output := buf.String()
+	for _, want := range []string{"record", "  x", "  y", "0x61", "0x64"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, output)
+		}
+	}
+
+	// Positional records keep the inner format codes
+	node, _ = ParseExpression("split(0, <bH)")
+	result, _ = node.Eval(bytes.NewReader([]byte{0xFF, 0x01, 0x02}), nil)
+	buf.Reset()
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "uint16") {
+		t.Errorf("PrettyPrintResult() output missing inner type\nGot:\n%s", buf.String())
+	}
+}
+
+func TestReparseNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    []any
+		wantErr bool
+	}{
+		{
+			name:  "payload after header",
+			input: ">H3B | reparse(<bH)",
+			data:  []byte{0x00, 0x03, 0xFF, 0x01, 0x02},
+			want:  []any{int8(-1), uint16(0x0201)},
+		},
+		{
+			name:  "inner pipe",
+			input: "<4B | reparse(<HH | fields(1))",
+			data:  []byte{0x01, 0x00, 0x02, 0x00},
+			want:  []any{uint16(2)},
+		},
+		{
+			name:  "piped onward",
+			input: "<2B | reparse(<bB) | fields(1, 0)",
+			data:  []byte{0xFF, 0x07},
+			want:  []any{uint8(7), int8(-1)},
+		},
+		{
+			name:    "not a byte slice",
+			input:   "<H | reparse(B)",
+			data:    []byte{0x01, 0x02},
+			wantErr: true,
+		},
+		{
+			name:    "payload too short",
+			input:   "<2B | reparse(<I)",
+			data:    []byte{0x01, 0x02},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			result, err := node.Eval(bytes.NewReader(tt.data), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			values := result.([]any)
+			if len(values) != len(tt.want) {
+				t.Fatalf("Eval() = %v, want %v", values, tt.want)
+			}
+			for i := range values {
+				if values[i] != tt.want[i] {
+					t.Errorf("Eval()[%d] = %v, want %v", i, values[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	for _, input := range []string{"reparse(B)", "<2B | reparse()", "<2B | reparse(B"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestUntilZeroNodeEval(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		data     []byte
+		want     [][]any
+		leftover int // bytes left unread after the sentinel
+		wantErr  bool
+	}{
+		{
+			name:     "sentinel record",
+			input:    "until_zero(<HB)",
+			data:     []byte{0x01, 0x00, 0x02, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF},
+			want:     [][]any{{uint16(1), uint8(2)}, {uint16(3), uint8(0)}},
+			leftover: 1,
+		},
+		{
+			name:  "sentinel only",
+			input: "until_zero(<I)",
+			data:  []byte{0x00, 0x00, 0x00, 0x00},
+			want:  [][]any{},
+		},
+		{
+			name:  "array codes",
+			input: "until_zero(>2H)",
+			data:  []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want:  [][]any{{[]uint16{1, 0}}},
+		},
+		{
+			name:  "clean EOF without sentinel",
+			input: "until_zero(<H)",
+			data:  []byte{0x01, 0x00},
+			want:  [][]any{{uint16(1)}},
+		},
+		{
+			name:    "truncated record",
+			input:   "until_zero(<I)",
+			data:    []byte{0x01, 0x00, 0x00, 0x00, 0x02},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			r := bytes.NewReader(tt.data)
+			result, err := node.Eval(r, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			records := result.([]any)
+			if len(records) != len(tt.want) {
+				t.Fatalf("Eval() returned %d records, want %d", len(records), len(tt.want))
+			}
+			for i, rec := range records {
+				if got, want := fmt.Sprintf("%#v", rec), fmt.Sprintf("%#v", tt.want[i]); got != want {
+					t.Errorf("record %d = %s, want %s", i, got, want)
+				}
+			}
+			if r.Len() != tt.leftover {
+				t.Errorf("Eval() left %d bytes, want %d", r.Len(), tt.leftover)
+			}
+		})
+	}
+}
+
+func TestUntilZeroParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"until_zero(<Hs)",
+		"until_zero()",
+		"until_zero(<H",
+		"until_zero(<4611686018427387904H)",
+		"until_zero(<8388608H8388608B)",
+		"<B | until_zero(<H)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestStrideNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "exact stride",
+			input: "stride(3, <HB)",
+			data:  []byte{0x01, 0x00, 0x0A, 0x02, 0x00, 0x0B},
+			want:  "[[1 10] [2 11]]",
+		},
+		{
+			name:  "padded records",
+			input: "stride(4, <H, pad)",
+			data:  []byte{0x01, 0x00, 0xAA, 0xAA, 0x02, 0x00, 0xBB, 0xBB},
+			want:  "[[1] [2]]",
+		},
+		{
+			name:  "variable-length records",
+			input: "stride(4, Bs | {0 -> id, 1 -> name}, pad)",
+			data:  []byte{0x01, 'a', 0x00, 0x00, 0x02, 'b', 'c', 0x00},
+			want:  "[{id:1 name:a} {id:2 name:bc}]",
+		},
+		{
+			name:  "empty input",
+			input: "stride(4, <I)",
+			data:  []byte{},
+			want:  "[]",
+		},
+		{
+			name:    "record shorter than the stride",
+			input:   "stride(4, <H)",
+			data:    []byte{0x01, 0x00, 0xAA, 0xAA},
+			wantErr: true,
+		},
+		{
+			name:    "record longer than the stride",
+			input:   "stride(2, <I, pad)",
+			data:    []byte{0x01, 0x00, 0x00, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "truncated last record",
+			input:   "stride(2, <H)",
+			data:    []byte{0x01, 0x00, 0x02},
+			wantErr: true,
+		},
+		{
+			name:    "largest stride on a short input",
+			input:   "stride(16777216, B)",
+			data:    []byte{0x01, 0x00},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := formatRecords(result.([]any)); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// formatRecords renders a list of records compactly, naming object fields.
+func formatRecords(records []any) string {
+	parts := make([]string, len(records))
+	for i, rec := range records {
+		if obj, ok := rec.(*Object); ok {
+			fields := make([]string, len(obj.Fields))
+			for j, f := range obj.Fields {
+				fields[j] = fmt.Sprintf("%s:%v", f.Name, f.Value)
+			}
+			parts[i] = "{" + strings.Join(fields, " ") + "}"
+			continue
+		}
+		parts[i] = fmt.Sprint(rec)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func TestStrideParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"stride(0, <H)",
+		"stride(4611686018427387904, B)",
+		"stride(4)",
+		"stride(4, <H, skip)",
+		"stride(4, <H",
+		"<B | stride(4, <H)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestRepeatPrevNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "count then records",
+			input: "<H | repeat_prev(<Ib)",
+			data:  []byte{0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0xFF, 0x02, 0x00, 0x00, 0x00, 0xFE},
+			want:  "[[1 -1] [2 -2]]",
+		},
+		{
+			name:  "records as objects",
+			input: "B | repeat_prev(B | {0 -> x})",
+			data:  []byte{0x02, 0x0A, 0x0B},
+			want:  "[{x:10} {x:11}]",
+		},
+		{
+			name:  "zero count",
+			input: "B | repeat_prev(<I)",
+			data:  []byte{0x00},
+			want:  "[]",
+		},
+		{
+			name:  "count from a single object field",
+			input: "<BH | fields(1) | repeat_prev(B)",
+			data:  []byte{0xFF, 0x01, 0x00, 0x07},
+			want:  "[[7]]",
+		},
+		{
+			name:    "fewer records than the count",
+			input:   "B | repeat_prev(<H)",
+			data:    []byte{0x02, 0x01, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "more than one value",
+			input:   "BB | repeat_prev(B)",
+			data:    []byte{0x01, 0x01, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "non-integer count",
+			input:   "s | repeat_prev(B)",
+			data:    []byte{'a', 0x00, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "negative count",
+			input:   "b | repeat_prev(B)",
+			data:    []byte{0xFF},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := formatRecords(result.([]any)); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseExpression("repeat_prev(B)"); err == nil {
+		t.Error("ParseExpression() expected error for repeat_prev() without a count stage, got nil")
+	}
+}
+
+func TestPrettyPrintRepeatPrevRecords(t *testing.T) {
+	node, err := ParseExpression("B | repeat_prev(<H)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte{0x01, 0x02, 0x01}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+
+	// The records are printed with their format codes
+	output := buf.String()
+	for _, want := range []string{"0          -      record", "  0        H      uint16                    258"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, output)
+		}
+	}
+}
+
+func TestRepeatNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "no records",
+			input: "repeat(<bH)",
+			data:  []byte{},
+			want:  "[]",
+		},
+		{
+			name:  "one record",
+			input: "repeat(<bH)",
+			data:  []byte{0xFF, 0x01, 0x02},
+			want:  "[[-1 513]]",
+		},
+		{
+			name:  "three records",
+			input: "repeat(<bH)",
+			data:  []byte{0xFF, 0x01, 0x02, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01},
+			want:  "[[-1 513] [1 3] [2 256]]",
+		},
+		{
+			name:  "object per record",
+			input: "repeat(<bH) | {0 -> key, 1 -> value}",
+			data:  []byte{0xFF, 0x01, 0x02, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01},
+			want:  "[{key:-1 value:513} {key:1 value:3} {key:2 value:256}]",
+		},
+		{
+			name:  "inline names",
+			input: "repeat(B:x)",
+			data:  []byte{0x0A, 0x0B},
+			want:  "[{x:10} {x:11}]",
+		},
+		{
+			name:  "filtered records",
+			input: "repeat(<bH) | where(0 > 1)",
+			data:  []byte{0xFF, 0x01, 0x02, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01},
+			want:  "[[2 256]]",
+		},
+		{
+			name:  "chain of stages per record",
+			input: "repeat(<bH) | {0 -> a, 1 -> b} | rename(a, x)",
+			data:  []byte{0xFF, 0x01, 0x02, 0x01, 0x03, 0x00},
+			want:  "[{x:-1 b:513} {x:1 b:3}]",
+		},
+		{
+			name:  "stages after a filter",
+			input: "repeat(<bH) | where(0 > 1) | {0 -> a, 1 -> b} | drop(a)",
+			data:  []byte{0xFF, 0x01, 0x02, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01},
+			want:  "[{b:256}]",
+		},
+		{
+			name:    "partial record",
+			input:   "repeat(<bH)",
+			data:    []byte{0xFF, 0x01, 0x02, 0x01, 0x03},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := formatRecords(result.([]any)); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"repeat()", "repeat(<bH", "repeat(parse(B))"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestWhereNodeEval(t *testing.T) {
+	records := []byte{0x01, 0xFF, 0x0A, 0xC8, 0x02, 0x0A, 0x65, 0x03}
+
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"greater than", "split(0x0A, Bb) | where(0 > 100)", records, "[[200 2] [101 3]]", false},
+		{"greater or equal", "split(0x0A, Bb) | where(0 >= 200)", records, "[[200 2]]", false},
+		{"less than", "split(0x0A, Bb) | where(0 < 101)", records, "[[1 -1]]", false},
+		{"less or equal", "split(0x0A, Bb) | where(0 <= 101)", records, "[[1 -1] [101 3]]", false},
+		{"equal hex", "split(0x0A, Bb) | where(0 == 0xC8)", records, "[[200 2]]", false},
+		{"not equal negative", "split(0x0A, Bb) | where(1 != -1)", records, "[[200 2] [101 3]]", false},
+		{"equal signed hex", "split(0x0A, Bb) | where(1 == -0x01)", records, "[[1 -1]]", false},
+		{"no match", "split(0x0A, Bb) | where(0 > 255)", records, "[]", false},
+		{"float literal", "split(0x0A, Bb) | where(1 > 2.5)", records, "[[101 3]]", false},
+		{"object field by name", "split(0x0A, Bb | {0 -> id, 1 -> v}) | where(v < 0)", records, "[{id:1 v:-1}]", false},
+		{"object field by position", "split(0x0A, Bb | {0 -> id, 1 -> v}) | where(0 == 101)", records, "[{id:101 v:3}]", false},
+		{"uint64 beyond int64", "stride(8, <Q) | where(0 > 1)", []byte{0, 0, 0, 0, 0, 0, 0, 0x80}, "[[9223372036854775808]]", false},
+		{"index out of range", "split(0x0A, Bb) | where(2 > 0)", records, "", true},
+		{"unknown field", "split(0x0A, Bb | {0 -> id}) | where(v > 0)", records, "", true},
+		{"name on values", "split(0x0A, Bb) | where(id > 0)", records, "", true},
+		{"plain values", "Bb | where(0 > 0)", records, "", true},
+		{"non-numeric field", "split(0x0A, s) | where(0 > 0)", []byte("ab\ncd"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := formatRecords(result.([]any)); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhereParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"split(0x0A, B) | where(0)",
+		"split(0x0A, B) | where(0 = 1)",
+		"split(0x0A, B) | where(0 > x)",
+		"split(0x0A, B) | where(> 1)",
+		"split(0x0A, B) | where(0 > 1",
+		"where(0 > 1)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
+
+func TestPrettyPrintWhereRecords(t *testing.T) {
+	node, err := ParseExpression("split(0x0A, <H) | where(0 > 1)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	result, err := node.Eval(bytes.NewReader([]byte{0x01, 0x00, 0x0A, 0x02, 0x01}), nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrettyPrintResult(&buf, node, result); err != nil {
+		t.Fatalf("PrettyPrintResult() error = %v", err)
+	}
+
+	// The kept records are printed with the format codes of the filtered records
+	output := buf.String()
+	for _, want := range []string{"0          -      record", "  0        H      uint16                    258"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("PrettyPrintResult() output missing %q\nGot:\n%s", want, output)
+		}
+	}
+}
+
+func TestExecuteRecordFooter(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		data []byte
+		opts Options
+		want string
+	}{
+		{"records", "split(0x0A, <H)", []byte{0x01, 0x00, 0x0A, 0x02, 0x00}, Options{Output: OutputTable}, "\n# 2 records, 5 bytes\n"},
+		{"single record", "stride(2, <H)", []byte{0x01, 0x00}, Options{Pretty: true}, "\n# 1 record, 2 bytes\n"},
+		{"count stage", "B | repeat_prev(B)", []byte{0x02, 0x01, 0x02, 0xFF}, Options{Output: OutputTable}, "\n# 2 records, 3 bytes\n"},
+		{"after a seek", "seek(2) | repeat(<H)", []byte{0xFF, 0xFF, 0x01, 0x00, 0x02, 0x00}, Options{Output: OutputTable}, "\n# 2 records, 4 bytes\n"},
+		{"no header", "split(0x0A, <H)", []byte{0x01, 0x00}, Options{Output: OutputTable, NoHeader: true}, "0x0001\n"},
+		{"plain values", "<H", []byte{0x01, 0x00}, Options{Output: OutputTable}, "0x0001\n"},
+		{"json output", "split(0x0A, B | {0 -> x})", []byte{0x01}, Options{Output: OutputJSON}, "[{\"x\":1}]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Execute(tt.expr, newCountingReader(bytes.NewReader(tt.data)), &buf, tt.opts); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !strings.HasSuffix(buf.String(), tt.want) {
+				t.Errorf("Execute() = %q, want suffix %q", buf.String(), tt.want)
+			}
+			if tt.opts.NoHeader && strings.Contains(buf.String(), "Name") {
+				t.Errorf("Execute() printed the header with NoHeader:\n%s", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+)
+
+// SeekNode moves to an offset of the input before evaluating the source after it,
+// e.g. seek(16) | <I reads a uint32 after a 16-byte header, while seek(+4) | <I
+// skips 4 bytes from the current position. The input must be seekable, such as a
+// file.
+type SeekNode struct {
+	Offset int64 // offset from the start of the input, or from the current position
+	Whence int   // io.SeekStart for an absolute offset, io.SeekCurrent for a relative one
+	Inner  Node  // source evaluated at the offset
+}
+
+// Eval seeks to the offset and evaluates the inner source there, leaving the
+// input at the position the source stopped reading. An offset before the start or
+// past the end of the input is an error.
+func (n *SeekNode) Eval(r io.Reader, values []any) (any, error) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil, fmt.Errorf("seek: input does not support seeking")
+	}
+	size, err := inputSize(seeker)
+	if err != nil {
+		// A pipe is an io.Seeker too, but fails to seek
+		return nil, seekError("seek", err)
+	}
+
+	target := n.Offset
+	if n.Whence == io.SeekCurrent {
+		pos, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("seek: %w", err)
+		}
+		target += pos
+	}
+	if target < 0 || target > size {
+		return nil, fmt.Errorf("seek: offset %d is outside the %d-byte input", target, size)
+	}
+	if _, err := seeker.Seek(target, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek: failed to seek to offset %d: %w", target, err)
+	}
+
+	return n.Inner.Eval(r, values)
+}
+
+// parseSeekFunc parses: 'seek' '(' ('+' | '-')? NUMBER ')' '|' Primary
+func (p *Parser) parseSeekFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'seek'"); err != nil {
+		return nil, err
+	}
+
+	// A sign makes the offset relative to the current position
+	node := &SeekNode{Whence: io.SeekStart}
+	sign := int64(1)
+	switch p.current.Type {
+	case TokenPlus, TokenMinus:
+		if p.current.Type == TokenMinus {
+			sign = -1
+		}
+		node.Whence = io.SeekCurrent
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	offset, err := p.parseInt("seek offset")
+	if err != nil {
+		return nil, err
+	}
+	node.Offset = sign * int64(offset)
+
+	if err := p.expect(TokenRParen, "')' after seek offset"); err != nil {
+		return nil, err
+	}
+
+	// The source reading at the offset follows the pipe
+	if p.current.Type != TokenPipe {
+		return nil, fmt.Errorf("expected '|' and the source to read after seek at position %d, e.g. 'seek(16) | <I'", p.current.Pos)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	node.Inner, err = p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
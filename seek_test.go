@@ -0,0 +1,134 @@
+package bq
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeekNodeEval(t *testing.T) {
+	// A 16-byte header, then a uint32 and a uint16
+	data := append(bytes.Repeat([]byte{0xee}, 16), 0x01, 0x00, 0x00, 0x00, 0x02, 0x00)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr string
+	}{
+		{
+			name:  "absolute offset",
+			input: "seek(16) | <I",
+			want:  []any{uint32(1)},
+		},
+		{
+			name:  "hex offset into an object",
+			input: "seek(0x14) | <H | {0 -> count}",
+			want:  &Object{Fields: []ObjectField{{Name: "count", Value: uint16(2)}}},
+		},
+		{
+			name:  "relative forward",
+			input: "seek(16) | seek(+4) | <H",
+			want:  []any{uint16(2)},
+		},
+		{
+			name:  "relative backward",
+			input: "seek(20) | seek(-4) | <I",
+			want:  []any{uint32(1)},
+		},
+		{
+			name:    "past the end",
+			input:   "seek(23) | B",
+			wantErr: "offset 23 is outside the 22-byte input",
+		},
+		{
+			name:    "before the start",
+			input:   "seek(-1) | B",
+			wantErr: "offset -1 is outside",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.input, err)
+			}
+			result, err := node.Eval(bytes.NewReader(data), nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Eval() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("Eval() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeekNeedsSeekableInput(t *testing.T) {
+	node, err := ParseExpression("seek(1) | B")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	_, err = node.Eval(io.MultiReader(bytes.NewReader([]byte{0x01, 0x02})), nil)
+	if err == nil || !strings.Contains(err.Error(), "does not support seeking") {
+		t.Errorf("Eval() on a non-seekable input error = %v, want a seeking error", err)
+	}
+
+	// A wrapper of the input reports the same error, once
+	_, err = node.Eval(newCountingReader(io.MultiReader(bytes.NewReader([]byte{0x01, 0x02}))), nil)
+	if err == nil || strings.Count(err.Error(), "does not support seeking") != 1 {
+		t.Errorf("Eval() on a wrapped non-seekable input error = %v, want a single seeking error", err)
+	}
+}
+
+func TestSeekThroughWrappers(t *testing.T) {
+	data := append(bytes.Repeat([]byte{0xee}, 16), 0x01, 0x00, 0x00, 0x00)
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"max bytes", Options{Output: OutputRaw, MaxBytes: 8}, "1\n"},
+		{"with hex", Options{Output: OutputRaw, WithHex: true}, "00000010  01 00 00 00"},
+		{"verify", Options{Output: OutputRaw, Verify: true}, "4 consumed bytes, 4 re-encoded bytes: OK"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Execute("seek(16) | <I", bytes.NewReader(data), &buf, tt.opts); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("Execute() = %q, want it to contain %q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	// A consumed sink captures the bytes read after the seek
+	result, err := EvalBytes("seek(16) | <I | hexdump()", data)
+	if err != nil {
+		t.Fatalf("EvalBytes() error = %v", err)
+	}
+	if lines := result.(*Dump).Lines; len(lines) == 0 || !strings.HasPrefix(lines[0], "00000010  01 00 00 00") {
+		t.Errorf("hexdump() after seek = %q, want the 4 bytes at offset 16", lines)
+	}
+}
+
+func TestSeekParseErrors(t *testing.T) {
+	for _, input := range []string{"seek(16)", "seek() | B", "seek(16) | {0 -> a}", "<B | seek(1) | B"} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package bq
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// DefaultSQLTable is the table name of the SQL output when Options.Table is empty.
+const DefaultSQLTable = "records"
+
+// sqlIdentifier matches the table and column names accepted without quoting.
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLPrintResult outputs an object result, or each object of a record stream, as
+// an INSERT INTO statement of the given table. Field names become the column
+// names, with nested objects flattened by _ joiners (e.g., header_length).
+// Strings are single-quoted, byte arrays become hex blobs (X'0102'), and missing
+// optional fields and non-finite floats are NULL. Names which are not plain SQL
+// identifiers, and values without a SQL form, return an error.
+func SQLPrintResult(w io.Writer, result any, table string) error {
+	if table == "" {
+		table = DefaultSQLTable
+	}
+	if !sqlIdentifier.MatchString(table) {
+		return fmt.Errorf("invalid SQL table name %q", table)
+	}
+
+	switch r := result.(type) {
+	case *Object:
+		return writeSQLInsert(w, table, r)
+	case []any:
+		for i, rec := range r {
+			obj, ok := rec.(*Object)
+			if !ok {
+				return fmt.Errorf("sql output needs object records to name the columns, got %T at index %d", rec, i)
+			}
+			if err := writeSQLInsert(w, table, obj); err != nil {
+				return fmt.Errorf("record %d: %w", i, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported result type: %T", result)
+	}
+}
+
+// writeSQLInsert writes a single INSERT statement for the object.
+func writeSQLInsert(w io.Writer, table string, obj *Object) error {
+	var columns, values []string
+	if err := sqlColumns(&columns, &values, "", obj); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("sql output needs at least one field")
+	}
+
+	_, err := fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES (%s);\n", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+	return err
+}
+
+// sqlColumns appends the column names and SQL literals of the fields of obj.
+func sqlColumns(columns, values *[]string, prefix string, obj *Object) error {
+	for _, field := range obj.Fields {
+		name := prefix + field.Name
+		if nested, ok := field.Value.(*Object); ok {
+			if err := sqlColumns(columns, values, name+"_", nested); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !sqlIdentifier.MatchString(name) {
+			return fmt.Errorf("invalid SQL column name %q", name)
+		}
+		literal, err := sqlLiteral(field.Value)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+		*columns = append(*columns, name)
+		*values = append(*values, literal)
+	}
+	return nil
+}
+
+// sqlLiteral renders a value as a SQL literal.
+func sqlLiteral(val any) (string, error) {
+	switch v := val.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case []uint8:
+		return "X'" + hex.EncodeToString(v) + "'", nil
+	case float32:
+		return sqlFloat(float64(v), 32), nil
+	case float64:
+		return sqlFloat(v, 64), nil
+	case Mark:
+		return fmt.Sprint(v.Offset), nil
+	case int8, uint8, int16, uint16, int32, uint32, int64, uint64:
+		return fmt.Sprint(v), nil
+	case *big.Int:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("cannot render %T as a SQL value", val)
+	}
+}
+
+// sqlFloat renders a float in its shortest form, or NULL if it is not finite.
+func sqlFloat(v float64, bitSize int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "NULL"
+	}
+	return formatFloat(v, bitSize, 0)
+}
@@ -0,0 +1,101 @@
+package bq
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestSQLPrintResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  any
+		table   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "object",
+			result: &Object{Fields: []ObjectField{
+				{Name: "id", Value: uint16(7)},
+				{Name: "delta", Value: int8(-1)},
+			}},
+			want: "INSERT INTO records (id, delta) VALUES (7, -1);\n",
+		},
+		{
+			name: "record stream",
+			result: []any{
+				&Object{Fields: []ObjectField{{Name: "x", Value: uint8(1)}}},
+				&Object{Fields: []ObjectField{{Name: "x", Value: uint8(2)}}},
+			},
+			table: "points",
+			want:  "INSERT INTO points (x) VALUES (1);\nINSERT INTO points (x) VALUES (2);\n",
+		},
+		{
+			name: "quoted strings and hex blobs",
+			result: &Object{Fields: []ObjectField{
+				{Name: "name", Value: "it's"},
+				{Name: "raw", Value: []uint8{0x01, 0xAB}},
+			}},
+			want: "INSERT INTO records (name, raw) VALUES ('it''s', X'01ab');\n",
+		},
+		{
+			name: "nested object flattened",
+			result: &Object{Fields: []ObjectField{
+				{Name: "header", Value: &Object{Fields: []ObjectField{{Name: "length", Value: uint32(3)}}}},
+			}},
+			want: "INSERT INTO records (header_length) VALUES (3);\n",
+		},
+		{
+			name: "nulls",
+			result: &Object{Fields: []ObjectField{
+				{Name: "opt", Value: nil},
+				{Name: "f", Value: math.Inf(1)},
+				{Name: "g", Value: float32(0.5)},
+			}},
+			want: "INSERT INTO records (opt, f, g) VALUES (NULL, NULL, 0.5);\n",
+		},
+		{
+			name: "128-bit integers",
+			result: &Object{Fields: []ObjectField{
+				{Name: "big", Value: maxUint128},
+				{Name: "small", Value: minInt128},
+			}},
+			want: "INSERT INTO records (big, small) VALUES (340282366920938463463374607431768211455, -170141183460469231731687303715884105728);\n",
+		},
+		{
+			name:    "invalid table name",
+			result:  &Object{Fields: []ObjectField{{Name: "x", Value: uint8(1)}}},
+			table:   "x; DROP TABLE y",
+			wantErr: true,
+		},
+		{
+			name:    "invalid column name",
+			result:  &Object{Fields: []ObjectField{{Name: "a-b", Value: uint8(1)}}},
+			wantErr: true,
+		},
+		{
+			name:    "unnamed values",
+			result:  []any{uint8(1), uint8(2)},
+			wantErr: true,
+		},
+		{
+			name:    "non-byte array",
+			result:  &Object{Fields: []ObjectField{{Name: "x", Value: []uint16{1}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := SQLPrintResult(&buf, tt.result, tt.table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SQLPrintResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := buf.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("SQLPrintResult() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
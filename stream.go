@@ -0,0 +1,164 @@
+package bq
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// streamsOutput returns true if the output selected by the options can be
+// written a record at a time (see writeRecordStream). The env, SQL and template
+// outputs need the whole result, as do the hexdump and verification of the
+// consumed bytes.
+func streamsOutput(opts Options) bool {
+	if opts.WithHex || opts.Verify {
+		return false
+	}
+	switch opts.Output {
+	case "", OutputRaw, OutputJSON, OutputCSV, OutputTable:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeRecordStream reads the records of a streaming node (see streamsRecords)
+// and writes each of them in the output selected by the options as soon as it is
+// read, so no record is kept once written. The output is the same as writeResult
+// gives for the list of records. It returns the number of records written.
+func writeRecordStream(w io.Writer, node Node, r io.Reader, opts Options) (int, error) {
+	title, inner := unwrapNamed(node)
+	count := 0
+
+	var write func(rec any) error
+	var flush, end func() error
+	switch {
+	case opts.Check:
+		write = func(any) error { return nil }
+	case opts.Output == OutputRaw:
+		write = func(rec any) error {
+			return writeRawElement(w, count, rec, opts)
+		}
+	case opts.Output == OutputJSON:
+		out := &jsonStreamWriter{w: w, title: title}
+		write, end = out.write, out.end
+	case opts.Output == OutputCSV:
+		out := newCSVRowWriter(w)
+		write, flush, end = out.write, out.flush, out.flush
+	case opts.Pretty || opts.Output == OutputTable:
+		p, err := newTablePrinter(w, opts)
+		if err != nil {
+			return 0, err
+		}
+		if err := p.printTop(title); err != nil {
+			return 0, err
+		}
+		write = func(rec any) error {
+			return p.printElement(inner, nil, count, rec, 0)
+		}
+	default:
+		write = func(any) error { return nil }
+	}
+
+	err := eachRecord(r, inner, func(rec any) error {
+		if err := write(rec); err != nil {
+			return err
+		}
+		count++
+		if opts.Follow {
+			return flushRecord(w, flush)
+		}
+		return nil
+	})
+	if err == nil && end != nil {
+		err = end()
+	}
+	return count, err
+}
+
+// flusher is implemented by buffered writers, such as the output of Execute.
+type flusher interface {
+	Flush() error
+}
+
+// flushRecord flushes the output written for a record (by the inner flush, if
+// any, then by w) through to the underlying writer.
+func flushRecord(w io.Writer, flush func() error) error {
+	if flush != nil {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	if f, ok := w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// isInteractive returns true if w is a terminal (a character device), whose
+// reader waits on each record.
+func isInteractive(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// jsonStreamWriter writes a list of records as ResultToJSON does, one record at
+// a time, wrapped under the title of a named expression.
+type jsonStreamWriter struct {
+	w       io.Writer
+	title   string // title of a named expression, empty for none
+	started bool   // the opening of the list was written
+	buf     bytes.Buffer
+}
+
+// write writes a record after the ones already written.
+func (j *jsonStreamWriter) write(rec any) error {
+	j.buf.Reset()
+	if !j.started {
+		if err := j.open(); err != nil {
+			return err
+		}
+	} else {
+		j.buf.WriteByte(',')
+	}
+	if err := writeJSONValue(&j.buf, rec); err != nil {
+		return err
+	}
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
+
+// open writes the opening of the list into the buffer.
+func (j *jsonStreamWriter) open() error {
+	j.started = true
+	if j.title != "" {
+		j.buf.WriteByte('{')
+		if err := writeJSONScalar(&j.buf, j.title); err != nil {
+			return err
+		}
+		j.buf.WriteByte(':')
+	}
+	j.buf.WriteByte('[')
+	return nil
+}
+
+// end closes the list, which is empty if no record was written.
+func (j *jsonStreamWriter) end() error {
+	j.buf.Reset()
+	if !j.started {
+		if err := j.open(); err != nil {
+			return err
+		}
+	}
+	j.buf.WriteByte(']')
+	if j.title != "" {
+		j.buf.WriteByte('}')
+	}
+	j.buf.WriteByte('\n')
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
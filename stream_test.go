@@ -0,0 +1,265 @@
+package bq
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecuteRecordStream(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x00, 0x03, 0x04, 0x00, 0x05, 0x06, 0x00}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{name: "table", input: "repeat(<bH)", opts: Options{Output: OutputTable}},
+		{name: "table of objects", input: "repeat(<bH) | {0 -> a, 1 -> b}", opts: Options{Pretty: true, PrintConsumed: true}},
+		{name: "named table", input: `name("log", repeat(<bH) | {0 -> a, 1 -> b})`, opts: Options{Output: OutputTable}},
+		{name: "table without header", input: "repeat(<bH)", opts: Options{Output: OutputTable, NoHeader: true}},
+		{name: "json", input: "repeat(<bH) | {0 -> a, 1 -> b}", opts: Options{Output: OutputJSON}},
+		{name: "named json", input: `name("log", repeat(<bH))`, opts: Options{Output: OutputJSON}},
+		{name: "filtered json", input: "repeat(<bH) | where(0 > 1)", opts: Options{Output: OutputJSON}},
+		{name: "empty json", input: "repeat(<bH) | where(0 > 9)", opts: Options{Output: OutputJSON}},
+		{name: "csv", input: "repeat(<bH) | {0 -> a, 1 -> b}", opts: Options{Output: OutputCSV}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The streamed output matches the output of the collected records
+			node, err := ParseExpressionWithOptions(tt.input, tt.opts)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			counter := &countingReader{r: bytes.NewReader(data)}
+			result, err := node.Eval(counter, nil)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			var want bytes.Buffer
+			if err := writeResult(&want, node, result, tt.opts); err != nil {
+				t.Fatalf("writeResult() error = %v", err)
+			}
+			if (tt.opts.Pretty || tt.opts.Output == OutputTable) && !tt.opts.NoHeader {
+				if err := writeRecordFooter(&want, node, result, counter.Consumed()); err != nil {
+					t.Fatalf("writeRecordFooter() error = %v", err)
+				}
+			}
+			if tt.opts.PrintConsumed {
+				want.WriteString("9\n")
+			}
+
+			var got bytes.Buffer
+			if err := Execute(tt.input, bytes.NewReader(data), &got, tt.opts); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("Execute() =\n%s\nwant\n%s", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestExecuteRecordStreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		opts    Options
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "truncated record",
+			input:   "repeat(<bH) | write(\"-\")",
+			data:    []byte{0x01, 0x02, 0x00, 0x03, 0x04},
+			want:    "\x01\x02\x00",
+			wantErr: "repeat: record 1",
+		},
+		{
+			name:    "stage error names the record",
+			input:   "repeat(<B) | where(0 > 0) | {3 -> x}",
+			data:    []byte{0x01, 0x02},
+			wantErr: "repeat: record 0",
+		},
+		{
+			name:    "filter error names the record",
+			input:   "repeat(<B) | where(1 > 0)",
+			data:    []byte{0x01},
+			opts:    Options{Output: OutputJSON},
+			wantErr: "where: record 0",
+		},
+		{
+			name:    "raw records",
+			input:   "repeat(<B)",
+			data:    []byte{0x01},
+			opts:    Options{Output: OutputRaw},
+			wantErr: "nested record at index 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bytes.Buffer
+			err := Execute(tt.input, bytes.NewReader(tt.data), &got, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.want != "" && got.String() != tt.want {
+				t.Errorf("Execute() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+// signalWriter signals the first write reaching it.
+type signalWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	written chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() == 0 {
+		close(w.written)
+	}
+	return w.buf.Write(p)
+}
+
+func TestExecuteRecordStreamBeforeEOF(t *testing.T) {
+	pr, pw := io.Pipe()
+	w := &signalWriter{written: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		done <- Execute(`repeat(<H) | write("-")`, pr, w, Options{})
+	}()
+
+	// More records than the output buffer holds, with the input still open
+	record := []byte{0x01, 0x02}
+	for range 4096 {
+		if _, err := pw.Write(record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	select {
+	case <-w.written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Execute() wrote nothing before EOF")
+	}
+
+	if err := pw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if want := bytes.Repeat(record, 4096); !bytes.Equal(w.buf.Bytes(), want) {
+		t.Errorf("Execute() wrote %d bytes, want %d", w.buf.Len(), len(want))
+	}
+}
+
+// BenchmarkExecuteRecordStream measures the memory of passing a large input
+// through repeat() to write("-"), which does not grow with the input.
+func BenchmarkExecuteRecordStream(b *testing.B) {
+	data := bytes.Repeat([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, 1<<17)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if err := Execute(`repeat(<HHI) | write("-")`, bytes.NewReader(data), io.Discard, Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// flushWriter records each write reaching it, one per flush of the output.
+type flushWriter struct {
+	writes []string
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestExecuteRecordStreamFollow(t *testing.T) {
+	data := []byte{0x01, 0x00, 0x02, 0x00, 0x03, 0x00}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		want  []string
+	}{
+		{
+			name:  "write to stdout",
+			input: `repeat(<H) | write("-")`,
+			opts:  Options{Follow: true},
+			want:  []string{"\x01\x00", "\x02\x00", "\x03\x00"},
+		},
+		{
+			name:  "json",
+			input: "repeat(<H)",
+			opts:  Options{Output: OutputJSON, Follow: true},
+			want:  []string{"[[1]", ",[2]", ",[3]", "]\n"},
+		},
+		{
+			name:  "csv",
+			input: "repeat(<H) | {0 -> a}",
+			opts:  Options{Output: OutputCSV, Follow: true},
+			want:  []string{"a\n1\n", "2\n", "3\n"},
+		},
+		{
+			name:  "table",
+			input: "repeat(<H)",
+			opts:  Options{Output: OutputTable, Columns: []string{"name", "type", "value"}, Follow: true},
+			want: []string{
+				"Name       Type                    Value\n----------------------------------------\n0          record                       \n  0        uint16                      1\n",
+				"1          record                       \n  0        uint16                      2\n",
+				"2          record                       \n  0        uint16                      3\n",
+				"# 3 records, 6 bytes\n",
+			},
+		},
+		{
+			name:  "without follow",
+			input: "repeat(<H)",
+			opts:  Options{Output: OutputJSON},
+			want:  []string{"[[1],[2],[3]]\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w flushWriter
+			if err := Execute(tt.input, bytes.NewReader(data), &w, tt.opts); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(w.writes, tt.want) {
+				t.Errorf("Execute() writes = %q, want %q", w.writes, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsInteractive(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if isInteractive(f) {
+		t.Error("isInteractive(regular file) = true, want false")
+	}
+	if isInteractive(&bytes.Buffer{}) {
+		t.Error("isInteractive(buffer) = true, want false")
+	}
+}
@@ -0,0 +1,225 @@
+package bq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// fieldOffset is an offset of the input given by a field of a record, from the
+// start of the input or, if Relative, from the current position.
+type fieldOffset struct {
+	Index    int    // index of the offset field (when Name is empty)
+	Name     string // name of the offset field
+	Relative bool   // the offset is relative to the current position
+}
+
+// seek moves the input to the offset given by the field of the result, returning
+// the position to restore afterwards. An offset outside the input is an error.
+func (o fieldOffset) seek(fn string, r io.Reader, result any) (offset, pos int64, err error) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return 0, 0, fmt.Errorf("%s: input does not support seeking", fn)
+	}
+	if offset, err = intField(fn, "offset", result, o.Index, o.Name); err != nil {
+		return 0, 0, err
+	}
+
+	size, err := inputSize(seeker)
+	if err != nil {
+		return 0, 0, seekError(fn, err)
+	}
+	if pos, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", fn, err)
+	}
+	if o.Relative {
+		offset += pos
+	}
+	if offset > size {
+		return 0, 0, fmt.Errorf("%s: offset %d is outside the %d-byte input", fn, offset, size)
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("%s: failed to seek to offset %d: %w", fn, offset, err)
+	}
+	return offset, pos, nil
+}
+
+// restore moves the input back to the position saved by seek, keeping an earlier
+// error.
+func restorePosition(fn string, r io.Reader, pos int64, err *error) {
+	if _, seekErr := r.(io.Seeker).Seek(pos, io.SeekStart); seekErr != nil && *err == nil {
+		*err = fmt.Errorf("%s: failed to restore the input position: %w", fn, seekErr)
+	}
+}
+
+// intField returns the non-negative integer value of a field of the result.
+func intField(fn, what string, result any, index int, name string) (int64, error) {
+	val, err := lookupField(result, index, name)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s: %w", fn, what, err)
+	}
+	v, err := toInt64(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s: %w", fn, what, err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("%s: negative %s %d", fn, what, v)
+	}
+	return v, nil
+}
+
+// AtNode evaluates an expression at an offset given by a field of the input and
+// returns its result as is, e.g. seek(0x3c) | <I:pe | at(pe, <4s) reads the
+// signature of the PE header found by the e_lfanew field of an MS-DOS header. The
+// input must be seekable, such as a file.
+type AtNode struct {
+	Offset fieldOffset // offset of the expression
+	Inner  Node        // expression evaluated at the offset
+}
+
+// Eval evaluates the expression at the offset given by the values of the input.
+func (n *AtNode) Eval(r io.Reader, values []any) (any, error) {
+	return n.EvalResult(r, values)
+}
+
+// EvalResult seeks to the offset, evaluates the expression there and restores the
+// position of the input.
+func (n *AtNode) EvalResult(r io.Reader, result any) (res any, err error) {
+	offset, pos, err := n.Offset.seek("at", r, result)
+	if err != nil {
+		return nil, err
+	}
+	defer restorePosition("at", r, pos, &err)
+
+	if res, err = n.Inner.Eval(r, nil); err != nil {
+		return nil, fmt.Errorf("at: offset %d: %w", offset, err)
+	}
+	return res, nil
+}
+
+// TableNode reads a table of records whose offset and count are fields of the
+// input, as the section headers of an ELF file found by the e_shoff and e_shnum
+// fields of its header, e.g. <40x Q:shoff 12x H:shnum | table(shoff, shnum, <IIQ).
+// A '+' makes the offset relative to the current position, as for a table after
+// a header of variable size. The input must be seekable, such as a file.
+type TableNode struct {
+	Offset     fieldOffset // offset of the table
+	CountIndex int         // index of the count field (when CountName is empty)
+	CountName  string      // name of the count field
+	Inner      Node        // expression evaluated on each record
+}
+
+// Eval reads the table from the values of the input.
+func (n *TableNode) Eval(r io.Reader, values []any) (any, error) {
+	return n.EvalResult(r, values)
+}
+
+// EvalResult seeks to the offset of the table, reads the count of records and
+// returns the result of each record, then restores the position of the input. A
+// count above maxArrayLen is an error.
+func (n *TableNode) EvalResult(r io.Reader, result any) (records any, err error) {
+	count, err := intField("table", "count", result, n.CountIndex, n.CountName)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxArrayLen {
+		return nil, fmt.Errorf("table: count %d exceeds the limit of %d records", count, maxArrayLen)
+	}
+	offset, pos, err := n.Offset.seek("table", r, result)
+	if err != nil {
+		return nil, err
+	}
+	defer restorePosition("table", r, pos, &err)
+
+	// The count comes from the input, so the slice grows as records are read
+	list := make([]any, 0)
+	for i := int64(0); i < count; i++ {
+		rec, err := n.Inner.Eval(r, nil)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("table: record %d of %d at offset %d: %w", i, count, offset, err)
+		}
+		list = append(list, rec)
+	}
+	return list, nil
+}
+
+// parseFieldOffset parses: '+'? (NUMBER | IDENTIFIER), the offset field of at()
+// and table().
+func (p *Parser) parseFieldOffset(what string) (fieldOffset, error) {
+	var offset fieldOffset
+	if p.current.Type == TokenPlus {
+		offset.Relative = true
+		if err := p.advance(); err != nil {
+			return offset, err
+		}
+	}
+	var err error
+	offset.Index, offset.Name, err = p.parseFieldRef(what)
+	return offset, err
+}
+
+// parseAtFunc parses: 'at' '(' '+'? (NUMBER | IDENTIFIER) ',' Pipe ')'
+func (p *Parser) parseAtFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'at'"); err != nil {
+		return nil, err
+	}
+
+	offset, err := p.parseFieldOffset("at offset field")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after at offset field"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after at expression"); err != nil {
+		return nil, err
+	}
+	return &AtNode{Offset: offset, Inner: inner}, nil
+}
+
+// parseTableFunc parses: 'table' '(' '+'? (NUMBER | IDENTIFIER) ',' (NUMBER | IDENTIFIER) ',' Pipe ')'
+func (p *Parser) parseTableFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'table'"); err != nil {
+		return nil, err
+	}
+
+	offset, err := p.parseFieldOffset("table offset field")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after table offset field"); err != nil {
+		return nil, err
+	}
+	countIndex, countName, err := p.parseFieldRef("table count field")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after table count field"); err != nil {
+		return nil, err
+	}
+
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenRParen, "')' after table record"); err != nil {
+		return nil, err
+	}
+	return &TableNode{Offset: offset, CountIndex: countIndex, CountName: countName, Inner: inner}, nil
+}
@@ -0,0 +1,219 @@
+package bq
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTableNodeEval(t *testing.T) {
+	// A header of a count and an offset, then a table of two records
+	data := []byte{0x02, 0x04, 0xff, 0xff, 0x01, 0x00, 0x02, 0x00}
+
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "named fields",
+			input: "<B:count B:offset | table(offset, count, <H:v)",
+			want:  `[{"v":1},{"v":2}]`,
+		},
+		{
+			name:  "indexed fields",
+			input: "<BB | table(1, 0, <H)",
+			want:  `[[1],[2]]`,
+		},
+		{
+			name:  "relative offset",
+			input: "<B:count B:skip | table(+skip, count, <H)",
+			data:  []byte{0x02, 0x02, 0xff, 0xff, 0x01, 0x00, 0x02, 0x00},
+			want:  `[[1],[2]]`,
+		},
+		{
+			name:  "empty table",
+			input: "<BB | table(1, 0, <H)",
+			data:  []byte{0x00, 0x02},
+			want:  `[]`,
+		},
+		{
+			name:  "table of nested records",
+			input: "<BB | table(1, 0, <B | {0 -> id})",
+			want:  `[{"id":1},{"id":0}]`,
+		},
+		{
+			name:    "truncated table",
+			input:   "<BB | table(1, 0, <H)",
+			data:    []byte{0x03, 0x04, 0xff, 0xff, 0x01, 0x00, 0x02, 0x00},
+			wantErr: "table: record 2 of 3 at offset 4: unexpected EOF",
+		},
+		{
+			name:    "offset outside the input",
+			input:   "<BB | table(1, 0, <H)",
+			data:    []byte{0x01, 0x09},
+			wantErr: "offset 9 is outside the 2-byte input",
+		},
+		{
+			name:    "negative count",
+			input:   "<bB | table(1, 0, <H)",
+			data:    []byte{0xff, 0x02},
+			wantErr: "table: negative count -1",
+		},
+		{
+			name:    "count above the limit",
+			input:   "<Q | table(0, 0, B)",
+			data:    []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00},
+			wantErr: "exceeds the limit",
+		},
+		{
+			name:    "unknown field",
+			input:   "<B:count | table(offset, count, B)",
+			wantErr: "table: offset",
+		},
+		{
+			name:    "non-integer field",
+			input:   "<B s | table(1, 0, B)",
+			data:    []byte{0x01, 'h', 'i', 0x00},
+			wantErr: "table: offset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.data
+			if input == nil {
+				input = data
+			}
+			result, err := EvalBytes(tt.input, input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EvalBytes() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := ResultToJSON(&buf, result); err != nil {
+				t.Fatalf("ResultToJSON() error = %v", err)
+			}
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAtNodeEval(t *testing.T) {
+	// An offset to a header holding the count and padding of the table after it
+	data := []byte{0x02, 0xff, 0x02, 0x00, 0x01, 0x00, 0x02, 0x00}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "record at the offset",
+			input: "<B:header | at(header, <BH)",
+			want:  `[2,256]`,
+		},
+		{
+			name:  "table after a header",
+			input: "<B:header | at(header, <B:count B:skip | table(+skip, count, <H))",
+			want:  `[[1],[2]]`,
+		},
+		{
+			name:  "relative offset",
+			input: "<B | at(+0, <H)",
+			want:  `[256]`,
+		},
+		{
+			name:    "inner error names the offset",
+			input:   "<B | at(0, <4I)",
+			wantErr: "at: offset 2",
+		},
+		{
+			name:    "offset outside the input",
+			input:   "<BB | at(+1, B)",
+			wantErr: "offset 257 is outside the 8-byte input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EvalBytes() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := ResultToJSON(&buf, result); err != nil {
+				t.Fatalf("ResultToJSON() error = %v", err)
+			}
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableNodeRestoresPosition(t *testing.T) {
+	for _, input := range []string{"<BB | table(1, 0, <H)", "<BB | at(1, <H)"} {
+		node, err := ParseExpression(input)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q) error = %v", input, err)
+		}
+		r := bytes.NewReader([]byte{0x01, 0x03, 0xff, 0x01, 0x00})
+		if _, err := node.Eval(r, nil); err != nil {
+			t.Fatalf("Eval(%q) error = %v", input, err)
+		}
+		if pos, _ := r.Seek(0, io.SeekCurrent); pos != 2 {
+			t.Errorf("position after %q = %d, want 2", input, pos)
+		}
+	}
+}
+
+func TestTableNeedsSeekableInput(t *testing.T) {
+	for _, input := range []string{"<BB | table(1, 0, B)", "<B | at(0, B)"} {
+		node, err := ParseExpression(input)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q) error = %v", input, err)
+		}
+		_, err = node.Eval(io.MultiReader(bytes.NewReader([]byte{0x01, 0x02})), nil)
+		if err == nil || !strings.Contains(err.Error(), "does not support seeking") {
+			t.Errorf("Eval(%q) on a non-seekable input error = %v, want a seeking error", input, err)
+		}
+	}
+}
+
+func TestTableParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"<BB | table()",
+		"<BB | table(0)",
+		"<BB | table(0, 1)",
+		"<BB | table(0, +1, B)",
+		"<BB | table(0, 1, B",
+		"<BB | table(\"a\", 1, B)",
+		"<B | at()",
+		"<B | at(0)",
+		"<B | at(0, B",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
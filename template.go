@@ -0,0 +1,75 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// parseOutputTemplate parses the template of the tmpl output. A field missing
+// from the result is an error rather than printing "<no value>".
+func parseOutputTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, fmt.Errorf("tmpl output needs a template, e.g. --template '{{.width}}x{{.height}}'")
+	}
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// TemplatePrintResult renders the result with a Go text/template followed by a
+// newline. An object is passed to the template as a map of its fields (see
+// Object.Map), and each object of a record stream is rendered on its own line.
+// Unnamed values are passed as a slice, so {{index . 0}} picks the first value.
+func TemplatePrintResult(w io.Writer, result any, text string) error {
+	tmpl, err := parseOutputTemplate(text)
+	if err != nil {
+		return err
+	}
+
+	switch r := result.(type) {
+	case *Object:
+		return executeTemplate(w, tmpl, r.Map())
+	case []any:
+		records, ok := objectRecords(r)
+		if !ok {
+			return executeTemplate(w, tmpl, r)
+		}
+		for i, rec := range records {
+			if err := executeTemplate(w, tmpl, rec.Map()); err != nil {
+				return fmt.Errorf("record %d: %w", i, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported result type: %T", result)
+	}
+}
+
+// objectRecords returns the values as objects if every value is an object, as
+// for a record stream of objects.
+func objectRecords(values []any) ([]*Object, bool) {
+	if len(values) == 0 {
+		return nil, false
+	}
+	records := make([]*Object, len(values))
+	for i, val := range values {
+		obj, ok := val.(*Object)
+		if !ok {
+			return nil, false
+		}
+		records[i] = obj
+	}
+	return records, true
+}
+
+// executeTemplate renders a single line of the template with the data.
+func executeTemplate(w io.Writer, tmpl *template.Template, data any) error {
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute output template: %w", err)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
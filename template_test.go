@@ -0,0 +1,113 @@
+package bq
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTemplatePrintResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   any
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "object fields",
+			result: &Object{Fields: []ObjectField{
+				{Name: "width", Value: uint16(640)},
+				{Name: "height", Value: uint16(480)},
+			}},
+			template: "{{.width}}x{{.height}}",
+			want:     "640x480\n",
+		},
+		{
+			name: "nested object",
+			result: &Object{Fields: []ObjectField{
+				{Name: "header", Value: &Object{Fields: []ObjectField{{Name: "magic", Value: uint32(0xCAFE)}}}},
+			}},
+			template: "{{printf \"%#x\" .header.magic}}",
+			want:     "0xcafe\n",
+		},
+		{
+			name: "record stream",
+			result: []any{
+				&Object{Fields: []ObjectField{{Name: "x", Value: uint8(1)}}},
+				&Object{Fields: []ObjectField{{Name: "x", Value: uint8(2)}}},
+			},
+			template: "x={{.x}}",
+			want:     "x=1\nx=2\n",
+		},
+		{
+			name:     "unnamed values",
+			result:   []any{uint8(1), "two"},
+			template: "{{index . 1}}",
+			want:     "two\n",
+		},
+		{
+			name:     "missing field",
+			result:   &Object{Fields: []ObjectField{{Name: "x", Value: uint8(1)}}},
+			template: "{{.y}}",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid template",
+			result:   &Object{},
+			template: "{{.x",
+			wantErr:  true,
+		},
+		{
+			name:    "empty template",
+			result:  &Object{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := TemplatePrintResult(&buf, tt.result, tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TemplatePrintResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := buf.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("TemplatePrintResult() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectMap(t *testing.T) {
+	obj := &Object{Fields: []ObjectField{
+		{Name: "id", Value: uint8(7)},
+		{Name: "inner", Value: &Object{Fields: []ObjectField{{Name: "raw", Value: []uint8{1, 2}}}}},
+		{Name: "list", Value: []any{&Object{Fields: []ObjectField{{Name: "v", Value: int16(-1)}}}}},
+	}}
+
+	want := map[string]any{
+		"id":    uint8(7),
+		"inner": map[string]any{"raw": []uint8{1, 2}},
+		"list":  []any{map[string]any{"v": int16(-1)}},
+	}
+	if got := obj.Map(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Map() = %#v, want %#v", got, want)
+	}
+}
+
+func TestExecuteTemplateOutput(t *testing.T) {
+	var buf bytes.Buffer
+	opts := Options{Output: OutputTmpl, Template: "{{.width}}x{{.height}}"}
+	if err := Execute("<HH | {0 -> width, 1 -> height}", bytes.NewReader([]byte{0x80, 0x02, 0xE0, 0x01}), &buf, opts); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got := buf.String(); got != "640x480\n" {
+		t.Errorf("Execute() output = %q, want %q", got, "640x480\n")
+	}
+
+	opts.Template = "{{.width"
+	if err := Execute("<HH", bytes.NewReader(nil), &buf, opts); err == nil {
+		t.Error("Execute() with an invalid template succeeded, want an error")
+	}
+}
@@ -0,0 +1,103 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// deadlineSetter is implemented by readers supporting read deadlines, such as
+// pipes, fifos and sockets opened as *os.File.
+type deadlineSetter interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// timeoutInput makes each read from r fail if no data arrives within timeout (0
+// means no timeout), so a stalled stream aborts instead of hanging. Regular
+// files never stall and are returned as-is.
+func timeoutInput(r io.Reader, timeout time.Duration) io.Reader {
+	if timeout <= 0 {
+		return r
+	}
+
+	if f, ok := r.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			return r
+		}
+	}
+	if d, ok := r.(deadlineSetter); ok && d.SetReadDeadline(time.Time{}) == nil {
+		return &deadlineReader{r: r, d: d, timeout: timeout}
+	}
+	return &timeoutReader{r: r, timeout: timeout}
+}
+
+// timeoutError reports a read which got no data within the timeout.
+func timeoutError(timeout time.Duration) error {
+	return fmt.Errorf("no data read within %s: %w", timeout, os.ErrDeadlineExceeded)
+}
+
+// deadlineReader sets a fresh read deadline before each read.
+type deadlineReader struct {
+	r       io.Reader
+	d       deadlineSetter
+	timeout time.Duration
+}
+
+// Read reads from the underlying reader, failing once the deadline passes.
+func (t *deadlineReader) Read(p []byte) (int, error) {
+	if err := t.d.SetReadDeadline(time.Now().Add(t.timeout)); err != nil {
+		return 0, err
+	}
+
+	n, err := t.r.Read(p)
+	if os.IsTimeout(err) {
+		err = timeoutError(t.timeout)
+	}
+	return n, err
+}
+
+// timeoutReader reads in a goroutine for readers without deadline support. A
+// read which times out is abandoned, leaving its goroutine blocked, so the
+// reader must not be used after an error.
+type timeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+}
+
+// readResult is the outcome of a read done in a goroutine.
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// Read reads from the underlying reader, failing if it takes longer than the timeout.
+func (t *timeoutReader) Read(p []byte) (int, error) {
+	// Read into a private buffer, since an abandoned read may still complete
+	done := make(chan readResult, 1)
+	go func() {
+		buf := make([]byte, len(p))
+		n, err := t.r.Read(buf)
+		done <- readResult{data: buf[:n], err: err}
+	}()
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return copy(p, res.data), res.err
+	case <-timer.C:
+		return 0, timeoutError(t.timeout)
+	}
+}
+
+// Seek forwards to the underlying reader if it is an io.Seeker.
+func (t *deadlineReader) Seek(offset int64, whence int) (int64, error) {
+	return seekInner(t.r, offset, whence)
+}
+
+// Seek forwards to the underlying reader if it is an io.Seeker.
+func (t *timeoutReader) Seek(offset int64, whence int) (int64, error) {
+	return seekInner(t.r, offset, whence)
+}
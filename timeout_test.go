@@ -0,0 +1,94 @@
+package bq
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeoutInput(t *testing.T) {
+	// No timeout and regular files leave the reader as-is
+	data := bytes.NewReader([]byte{0x01})
+	if got := timeoutInput(data, 0); got != io.Reader(data) {
+		t.Errorf("timeoutInput(r, 0) = %T, want the reader as-is", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte{0x01}, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	if got := timeoutInput(f, time.Second); got != io.Reader(f) {
+		t.Errorf("timeoutInput(file, 1s) = %T, want the file as-is", got)
+	}
+}
+
+func TestTimeoutInputStalled(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error = %v", err)
+	}
+	defer func() { _ = pr.Close(); _ = pw.Close() }()
+
+	// A pipe supports deadlines, other readers fall back to a goroutine
+	ipr, ipw := io.Pipe()
+	defer func() { _ = ipw.Close() }()
+
+	for name, r := range map[string]io.Reader{"deadline": pr, "goroutine": ipr} {
+		t.Run(name, func(t *testing.T) {
+			_, err := evalReader(t, "<H", timeoutInput(r, 20*time.Millisecond))
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("Eval() error = %v, want a deadline exceeded error", err)
+			}
+		})
+	}
+}
+
+func TestTimeoutInputData(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error = %v", err)
+	}
+	defer func() { _ = pr.Close() }()
+
+	go func() {
+		_, _ = pw.Write([]byte{0x01, 0x02})
+		_ = pw.Close()
+	}()
+
+	ipr, ipw := io.Pipe()
+	go func() {
+		_, _ = ipw.Write([]byte{0x01, 0x02})
+		_ = ipw.Close()
+	}()
+
+	for name, r := range map[string]io.Reader{"deadline": pr, "goroutine": ipr} {
+		t.Run(name, func(t *testing.T) {
+			result, err := evalReader(t, "<H", timeoutInput(r, time.Second))
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if values := result.([]any); len(values) != 1 || values[0] != uint16(0x0201) {
+				t.Errorf("Eval() = %v, want [513]", values)
+			}
+		})
+	}
+}
+
+// evalReader parses expr and evaluates it against r.
+func evalReader(t *testing.T, expr string, r io.Reader) (any, error) {
+	t.Helper()
+	node, err := ParseExpression(expr)
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	return node.Eval(r, nil)
+}
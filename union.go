@@ -0,0 +1,100 @@
+package bq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// UnionNode decodes the same bytes several ways at once, as for a union whose
+// type is not yet known (e.g., union(<I, <2H, <4B)). The bytes of the largest
+// alternative are read once, and each alternative decodes from their start.
+type UnionNode struct {
+	Alternatives []*FormatNode // fixed-size format expressions
+	Size         int           // bytes consumed, the size of the largest alternative
+}
+
+// Eval reads the bytes and returns an object with a field per alternative, named
+// after its format expression.
+func (n *UnionNode) Eval(r io.Reader, _ []any) (any, error) {
+	buf := make([]byte, n.Size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("union: failed to read %d bytes: %w", n.Size, err)
+	}
+
+	obj := &Object{Fields: make([]ObjectField, 0, len(n.Alternatives))}
+	for _, alt := range n.Alternatives {
+		name := formatExprString(alt.Expr)
+		val, err := alt.Eval(newRecordReader(r, bytes.NewReader(buf)), nil)
+		if err != nil {
+			return nil, fmt.Errorf("union alternative %s: %w", name, err)
+		}
+		if values, ok := val.([]any); ok {
+			val = unionValue(values)
+		}
+		obj.Fields = append(obj.Fields, ObjectField{Name: name, Value: val})
+	}
+	return obj, nil
+}
+
+// unionValue unwraps an alternative holding one value, and otherwise nests its
+// values in an object named by index, as for unnamed inline fields.
+func unionValue(values []any) any {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	obj := &Object{Fields: make([]ObjectField, len(values))}
+	for i, val := range values {
+		obj.Fields[i] = ObjectField{Name: strconv.Itoa(i), Value: val}
+	}
+	return obj
+}
+
+// parseUnionFunc parses: 'union' '(' FormatExpr (',' FormatExpr)* ')'
+func (p *Parser) parseUnionFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'union'"); err != nil {
+		return nil, err
+	}
+
+	union := &UnionNode{}
+	for {
+		pos := p.current.Pos
+		node, err := p.parseFormatExpr()
+		if err != nil {
+			return nil, err
+		}
+		alt := node.(*FormatNode)
+
+		size := 0
+		for _, fc := range alt.Formats {
+			n, fixed := fc.byteSize()
+			if !fixed {
+				return nil, fmt.Errorf("union alternative at position %d must have a fixed size, got %c", pos, fc.Code)
+			}
+			size += n
+		}
+		union.Alternatives = append(union.Alternatives, alt)
+		union.Size = max(union.Size, size)
+
+		if p.current.Type != TokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRParen, "')' after union alternatives"); err != nil {
+		return nil, err
+	}
+	return union, nil
+}
@@ -0,0 +1,98 @@
+package bq
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnionNodeEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "same bytes three ways",
+			input: "union(<I, <2H, <4B)",
+			data:  []byte{0x01, 0x02, 0x03, 0x04},
+			want:  `{"<I":67305985,"<2H":[513,1027],"<4B":[1,2,3,4]}`,
+		},
+		{
+			name:  "both byte orders",
+			input: "union(<H, >H)",
+			data:  []byte{0x01, 0x02},
+			want:  `{"<H":513,">H":258}`,
+		},
+		{
+			name:  "several values nest by index",
+			input: "union(>HH, >I)",
+			data:  []byte{0x00, 0x01, 0x00, 0x02},
+			want:  `{">HH":{"0":1,"1":2},">I":65538}`,
+		},
+		{
+			name:  "shorter alternative reads a prefix",
+			input: "union(<I, B)",
+			data:  []byte{0xFF, 0x00, 0x00, 0x00},
+			want:  `{"<I":255,"@B":255}`,
+		},
+		{
+			name:  "piped into an object",
+			input: "union(<I, b) | {1 -> signed}",
+			data:  []byte{0xFF, 0x00, 0x00, 0x00},
+			want:  `{"signed":-1}`,
+		},
+		{
+			name:    "insufficient data",
+			input:   "union(<I, B)",
+			data:    []byte{0x01, 0x02},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(tt.input, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvalBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var buf bytes.Buffer
+			if err := ResultToJSON(&buf, result); err != nil {
+				t.Fatalf("ResultToJSON() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("EvalBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Only the bytes of the largest alternative are consumed
+	node, err := ParseExpression("union(<H, B)")
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	r := bytes.NewReader([]byte{0x01, 0x02, 0x03})
+	if _, err := node.Eval(r, nil); err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if r.Len() != 1 {
+		t.Errorf("Eval() left %d bytes unread, want 1", r.Len())
+	}
+}
+
+func TestUnionParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"union()",
+		"union(<I, s)",
+		"union(<I,)",
+		"union(<I",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}
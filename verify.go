@@ -0,0 +1,103 @@
+package bq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxVerifyDiffs caps the differing bytes listed by the verify report.
+const maxVerifyDiffs = 16
+
+// reencode encodes the result back into bytes with encodeValue. The values of a
+// format expression, and the fields of an object lining up with them, are laid out
+// by their format codes, while other results (reordered objects, records) use the
+// leading byte order of the expression's format, as their values no longer carry a
+// format code.
+func reencode(node Node, result any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	// An object whose fields line up with the format codes (inline names, or an
+	// object naming the values in order) is laid out by the codes like the values
+	if expr, ok := valuesFormatNode(node); ok {
+		if values, err := pipeValues(result); err == nil {
+			if err := encodeFormatted(&buf, expr.Formats, values, toBinaryOrder(expr.Order)); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}
+	}
+
+	order := nativeEndian()
+	if expr, ok := extractFormatNode(node); ok {
+		order = toBinaryOrder(expr.Order)
+	}
+	if err := encodeResult(&buf, result, order); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeResult encodes a result, descending into lists of records.
+func encodeResult(w io.Writer, result any, order binary.ByteOrder) error {
+	values, ok := result.([]any)
+	if !ok {
+		return encodeValue(w, result, order)
+	}
+	for i, val := range values {
+		if err := encodeResult(w, val, order); err != nil {
+			return fmt.Errorf("value %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// writeVerify re-encodes the result and compares it against the consumed bytes,
+// writing a report of the differences. A mismatch, which points at padding or
+// byte order mistakes in the format, is returned as an error after the report.
+func writeVerify(w io.Writer, node Node, result any, consumed []byte) error {
+	encoded, err := reencode(node, result)
+	if err != nil {
+		return fmt.Errorf("verify: cannot re-encode the result: %w", err)
+	}
+
+	match := bytes.Equal(encoded, consumed)
+	status := "OK"
+	if !match {
+		status = "MISMATCH"
+	}
+	if _, err := fmt.Fprintf(w, "\nVerify: %d consumed bytes, %d re-encoded bytes: %s\n", len(consumed), len(encoded), status); err != nil {
+		return err
+	}
+	if match {
+		return nil
+	}
+
+	diffs := 0
+	for i := 0; i < max(len(consumed), len(encoded)); i++ {
+		var want, got string = "--", "--"
+		if i < len(consumed) {
+			want = fmt.Sprintf("%02x", consumed[i])
+		}
+		if i < len(encoded) {
+			got = fmt.Sprintf("%02x", encoded[i])
+		}
+		if want == got {
+			continue
+		}
+
+		diffs++
+		if diffs <= maxVerifyDiffs {
+			if _, err := fmt.Fprintf(w, "  %08x: consumed %s, re-encoded %s\n", i, want, got); err != nil {
+				return err
+			}
+		}
+	}
+	if diffs > maxVerifyDiffs {
+		if _, err := fmt.Fprintf(w, "  ... %d more differences\n", diffs-maxVerifyDiffs); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("verify: %d of %d bytes differ after re-encoding", diffs, max(len(consumed), len(encoded)))
+}
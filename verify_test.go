@@ -0,0 +1,136 @@
+package bq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		data    []byte
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "values with mixed byte orders",
+			expr: "<H >I s",
+			data: []byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x03, 'a', 0x00},
+			want: []string{"Verify: 8 consumed bytes, 8 re-encoded bytes: OK"},
+		},
+		{
+			name: "padding re-encoded as zero bytes",
+			expr: "<B2xH",
+			data: []byte{0x07, 0x00, 0x00, 0x34, 0x12},
+			want: []string{"Verify: 5 consumed bytes, 5 re-encoded bytes: OK"},
+		},
+		{
+			name: "fixed-length string",
+			expr: "<4sB",
+			data: []byte{'a', 'b', 0x00, 0x00, 0x01},
+			want: []string{"Verify: 5 consumed bytes, 5 re-encoded bytes: OK"},
+		},
+		{
+			name: "object",
+			expr: "<BH | {0 -> tag, 1 -> len}",
+			data: []byte{0x07, 0x34, 0x12},
+			want: []string{"Verify: 3 consumed bytes, 3 re-encoded bytes: OK"},
+		},
+		{
+			name: "object with mixed byte orders",
+			expr: "<H>I | {0 -> a, 1 -> b}",
+			data: []byte{0x01, 0x80, 0x3F, 0x00, 0x01, 0x80},
+			want: []string{"Verify: 6 consumed bytes, 6 re-encoded bytes: OK"},
+		},
+		{
+			name: "object with padding",
+			expr: "<B2xH | {0 -> a, 1 -> b}",
+			data: []byte{0x07, 0x00, 0x00, 0x34, 0x12},
+			want: []string{"Verify: 5 consumed bytes, 5 re-encoded bytes: OK"},
+		},
+		{
+			name: "object of bit-fields",
+			expr: "B/4 B/4 | {0 -> hi, 1 -> lo}",
+			data: []byte{0xAB},
+			want: []string{"Verify: 1 consumed bytes, 1 re-encoded bytes: OK"},
+		},
+		{
+			name: "inline names",
+			expr: "<H:a >I:b",
+			data: []byte{0x01, 0x80, 0x3F, 0x00, 0x01, 0x80},
+			want: []string{"Verify: 6 consumed bytes, 6 re-encoded bytes: OK"},
+		},
+		{
+			name: "records",
+			expr: "split(0x0A, B)",
+			data: []byte{0x61, 0x0A, 0x62, 0x0A},
+			want: []string{
+				"Verify: 4 consumed bytes, 2 re-encoded bytes: MISMATCH",
+				"  00000001: consumed 0a, re-encoded 62",
+				"  00000002: consumed 62, re-encoded --",
+			},
+			wantErr: true,
+		},
+		{
+			name: "stride padding",
+			expr: "stride(4, <H, pad)",
+			data: []byte{0x01, 0x00, 0xAA, 0xAA},
+			want: []string{
+				"Verify: 4 consumed bytes, 2 re-encoded bytes: MISMATCH",
+				"  00000002: consumed aa, re-encoded --",
+				"  00000003: consumed aa, re-encoded --",
+			},
+			wantErr: true,
+		},
+		{
+			name: "differences are capped",
+			expr: "stride(20, B, pad)",
+			data: append([]byte{0x01}, bytes.Repeat([]byte{0xFF}, 19)...),
+			want: []string{
+				"MISMATCH",
+				"  00000010: consumed ff, re-encoded --",
+				"  ... 3 more differences",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			result, err := node.Eval(newCountingReader(bytes.NewReader(tt.data)), nil)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = writeVerify(&buf, node, result, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeVerify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("writeVerify() output missing %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteVerify(t *testing.T) {
+	var buf bytes.Buffer
+	opts := Options{Output: OutputTable, Verify: true, PrintConsumed: true}
+	err := Execute("stride(2, B, pad)", bytes.NewReader([]byte{0x01, 0xEE}), &buf, opts)
+	if err == nil {
+		t.Fatal("Execute() expected a verify error, got nil")
+	}
+	// The consumed count is still printed after a mismatch
+	if !strings.HasSuffix(buf.String(), "MISMATCH\n  00000001: consumed ee, re-encoded --\n2\n") {
+		t.Errorf("Execute() output = %q", buf.String())
+	}
+}
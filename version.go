@@ -0,0 +1,128 @@
+package bq
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// VersionNode picks how to read the rest of the input from a version value read
+// earlier, for formats written by several versions of a program, e.g.
+// <H | version(0, {1: parse(<I), 2: parse(<Iq)}).
+type VersionNode struct {
+	Index    int            // index of the integer version value
+	Branches map[int64]Node // expression read for each known version
+}
+
+// Eval reads the branch of the version from the reader and appends its values to
+// the input values, so existing indices are unchanged. A branch producing an
+// object contributes its values in field order. An unknown version is an error.
+func (n *VersionNode) Eval(r io.Reader, values []any) (any, error) {
+	if n.Index < 0 || n.Index >= len(values) {
+		return nil, fmt.Errorf("version: index %d out of range (have %d values)", n.Index, len(values))
+	}
+	version, err := toInt64(values[n.Index])
+	if err != nil {
+		return nil, fmt.Errorf("version: %w", err)
+	}
+
+	branch, ok := n.Branches[version]
+	if !ok {
+		return nil, fmt.Errorf("version: unknown version %d (known: %s)", version, n.known())
+	}
+	result, err := branch.Eval(r, nil)
+	if err != nil {
+		return nil, fmt.Errorf("version %d: %w", version, err)
+	}
+
+	out := append([]any{}, values...)
+	switch res := result.(type) {
+	case []any:
+		out = append(out, res...)
+	case *Object:
+		for _, f := range res.Fields {
+			out = append(out, f.Value)
+		}
+	default:
+		return nil, fmt.Errorf("version %d: branch must produce []any or *Object, got %T", version, result)
+	}
+	return out, nil
+}
+
+// known lists the versions with a branch in ascending order, e.g. "1, 2".
+func (n *VersionNode) known() string {
+	versions := slices.Sorted(maps.Keys(n.Branches))
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = strconv.FormatInt(v, 10)
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseVersionFunc parses: 'version' '(' NUMBER ',' '{' VersionBranch (',' VersionBranch)* '}' ')'
+// where VersionBranch is NUMBER ':' Pipe.
+func (p *Parser) parseVersionFunc() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLParen, "'(' after 'version'"); err != nil {
+		return nil, err
+	}
+
+	idx, err := p.parseInt("version value index")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenComma, "',' after version index"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenLBrace, "'{' to start the version branches"); err != nil {
+		return nil, err
+	}
+
+	node := &VersionNode{Index: idx, Branches: make(map[int64]Node)}
+	for {
+		pos := p.current.Pos
+		if p.current.Type != TokenNumber {
+			return nil, fmt.Errorf("expected a version number at position %d, got %q", pos, p.current.Value)
+		}
+		digits, base := splitNumberBase(p.current.Value)
+		version, err := strconv.ParseInt(digits, base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %w", p.current.Value, err)
+		}
+		if _, ok := node.Branches[version]; ok {
+			return nil, fmt.Errorf("duplicate version %d at position %d", version, pos)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(TokenColon, "':' after the version number"); err != nil {
+			return nil, err
+		}
+
+		branch, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		node.Branches[version] = branch
+
+		if p.current.Type != TokenComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect(TokenRBrace, "'}' after the version branches"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(TokenRParen, "')' after version branches"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
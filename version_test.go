@@ -0,0 +1,82 @@
+package bq
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVersionNodeEval(t *testing.T) {
+	const expr = "<H | version(0, {1: parse(<I), 2: <Iq, 0x10: B | {0 -> flags}})"
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []any
+		wantErr string
+	}{
+		{
+			name: "first version",
+			data: []byte{0x01, 0x00, 0x05, 0x00, 0x00, 0x00},
+			want: []any{uint16(1), uint32(5)},
+		},
+		{
+			name: "second version",
+			data: []byte{0x02, 0x00, 0x05, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			want: []any{uint16(2), uint32(5), int64(-1)},
+		},
+		{
+			name: "branch producing an object",
+			data: []byte{0x10, 0x00, 0x03},
+			want: []any{uint16(0x10), uint8(3)},
+		},
+		{
+			name:    "unknown version",
+			data:    []byte{0x03, 0x00},
+			wantErr: "unknown version 3 (known: 1, 2, 16)",
+		},
+		{
+			name:    "truncated branch",
+			data:    []byte{0x01, 0x00, 0x05},
+			wantErr: "version 1:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvalBytes(expr, tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EvalBytes() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalBytes() error = %v", err)
+			}
+			if got := result.([]any); !slices.Equal(got, tt.want) {
+				t.Errorf("EvalBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The version must be an integer value
+	if _, err := EvalBytes("s | version(0, {1: B})", []byte("a\x00\x01")); err == nil {
+		t.Error("EvalBytes() expected error for a non-integer version, got nil")
+	}
+}
+
+func TestVersionParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"<H | version(0)",
+		"<H | version(0, {})",
+		"<H | version(0, {1 <I})",
+		"<H | version(0, {1: <I, 1: <H})",
+		"<H | version(0, {x: <I})",
+		"<H | version(0, {1: <I)",
+	} {
+		if _, err := ParseExpression(input); err == nil {
+			t.Errorf("ParseExpression(%q) expected error, got nil", input)
+		}
+	}
+}